@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/alessio-palumbo/linktree-challenge/batch"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionLoginFailed    = "auth.login_failed"
+	ActionTokenIssued    = "auth.token_issued"
+	ActionTokenRefreshed = "auth.token_refreshed"
+	ActionTokenUsed      = "auth.token_used"
+	ActionTokenRejected  = "auth.token_rejected"
+	ActionTokenRevoked   = "auth.token_revoked"
+	ActionRefreshReused  = "auth.refresh_reused"
+
+	// ActionRequestPrefix is followed by the lowercase method of mutating api requests
+	ActionRequestPrefix = "api."
+)
+
+const (
+	// ColumnsPerEvent is the number of parameters each event adds to a batch insert
+	ColumnsPerEvent = 9
+	// SampleWindow is the time within which sampled events with the same key are recorded once
+	SampleWindow = 5 * time.Minute
+	// queueTimeout bounds the time an event waits for room in a full queue
+	queueTimeout = time.Second
+	// maxSampled is the number of sample keys above which the expired ones are swept
+	maxSampled = 100000
+)
+
+// Event is an entry of the audit log.
+// UserID is the account the event belongs to, ActorID the authenticated user causing it,
+// both are empty if unknown.
+type Event struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id,omitempty"`
+	ActorID   string            `json:"actor_id,omitempty"`
+	Action    string            `json:"action"`
+	Resource  string            `json:"resource,omitempty"`
+	Status    int               `json:"status,omitempty"`
+	RemoteIP  string            `json:"remote_ip,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Logger appends events to the audit_events table, which rejects updates and deletes.
+// Events are written in batches from a background goroutine, so that recording an event only
+// waits for the database when the queue is full. Events still finding it full after queueTimeout
+// are dropped and logged.
+// A nil Logger records nothing.
+type Logger struct {
+	db  *sql.DB
+	now func() time.Time
+	w   *batch.Writer
+
+	mu sync.Mutex
+	// sampled is the time each sample key was last recorded
+	sampled map[string]time.Time
+}
+
+// row is an event ready to be written
+type row struct {
+	Event
+	metadata []byte
+}
+
+// New returns a Logger writing up to batchSize events at a time, at least every interval
+// while events are queued. Close must be called to write the queued events.
+func New(db *sql.DB, batchSize int, interval time.Duration) *Logger {
+	l := &Logger{
+		db:      db,
+		now:     time.Now,
+		sampled: make(map[string]time.Time),
+	}
+	l.w = batch.New("audit events", batchSize, interval, queueTimeout, l.insert)
+
+	return l
+}
+
+// Log queues the event, with the address of the client making the request if r is not nil.
+// Failures are logged and do not affect the request.
+func (l *Logger) Log(r *http.Request, ev Event) {
+	if l == nil {
+		return
+	}
+
+	if r != nil && ev.RemoteIP == "" {
+		ev.RemoteIP = RemoteIP(r)
+	}
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = l.now().UTC()
+	}
+	ev.ID = uuid.New().String()
+
+	rw := row{Event: ev}
+	if len(ev.Metadata) > 0 {
+		data, err := json.Marshal(ev.Metadata)
+		if err != nil {
+			log.Printf("Failed to record audit event %s: %v", ev.Action, err)
+			return
+		}
+		rw.metadata = data
+	}
+
+	if err := l.w.Add(rw); err != nil {
+		log.Printf("Dropped audit event %s %s %s: %v", ev.ID, ev.Action, ev.Resource, err)
+	}
+}
+
+// LogSampled queues the event like Log, unless an event with the same key was recorded within
+// the SampleWindow. It records frequent events, such as the use of a token, once per window.
+func (l *Logger) LogSampled(r *http.Request, key string, ev Event) {
+	if l == nil {
+		return
+	}
+
+	now := l.now()
+
+	l.mu.Lock()
+	if last, ok := l.sampled[key]; ok && now.Sub(last) < SampleWindow {
+		l.mu.Unlock()
+		return
+	}
+	if len(l.sampled) >= maxSampled {
+		l.sweep(now)
+	}
+	l.sampled[key] = now
+	l.mu.Unlock()
+
+	l.Log(r, ev)
+}
+
+// sweep forgets the sample keys recorded before the window, or all of them if none is
+func (l *Logger) sweep(now time.Time) {
+	for key, last := range l.sampled {
+		if now.Sub(last) >= SampleWindow {
+			delete(l.sampled, key)
+		}
+	}
+
+	if len(l.sampled) >= maxSampled {
+		l.sampled = make(map[string]time.Time)
+	}
+}
+
+// Close writes the queued events and stops the Logger. Events logged after Close are dropped.
+func (l *Logger) Close() {
+	if l == nil {
+		return
+	}
+
+	l.w.Close()
+}
+
+// insert writes the batch of rows with a single multi-row statement
+func (l *Logger) insert(ctx context.Context, rows []interface{}) error {
+	args := make([]interface{}, 0, len(rows)*ColumnsPerEvent)
+	for _, r := range rows {
+		rw := r.(row)
+
+		var metadata interface{}
+		if rw.metadata != nil {
+			metadata = rw.metadata
+		}
+		args = append(args, rw.ID, nullable(rw.UserID), nullable(rw.ActorID), rw.Action, nullable(rw.Resource),
+			nullableInt(rw.Status), nullable(rw.RemoteIP), metadata, rw.CreatedAt)
+	}
+
+	_, err := l.db.ExecContext(ctx, `
+		INSERT INTO audit_events (id, user_id, actor_id, action, resource, status, remote_ip, metadata, created_at)
+		VALUES `+batch.Values(len(rows), ColumnsPerEvent), args...)
+
+	return err
+}
+
+// RemoteIP returns the address of the client connected to the server
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func nullableInt(i int) *int {
+	if i == 0 {
+		return nil
+	}
+	return &i
+}
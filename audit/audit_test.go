@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLogger_Log(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	userID := "fac90185-d243-46f5-8797-e57ac9c2c293"
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// A full batch, the last event is written on close
+	mock.ExpectExec("INSERT INTO audit_events (.+) VALUES \\(\\$1, (.+)\\), \\(\\$10, (.+)\\)$").
+		WithArgs(sqlmock.AnyArg(), userID, userID, ActionTokenIssued, nil, nil, "192.0.2.1", []byte(`{"session_id":"s1"}`), now,
+			sqlmock.AnyArg(), nil, nil, ActionTokenRejected, "/api/links", 401, "192.0.2.1", nil, now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO audit_events (.+) VALUES \\(\\$1, [^(]+\\)$").
+		WithArgs(sqlmock.AnyArg(), userID, userID, ActionTokenUsed, "/api/links", nil, "192.0.2.1", nil, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("POST", "https://linktree.com/auth/token", nil)
+	l := New(db, 2, time.Hour)
+	l.now = func() time.Time { return now }
+
+	l.Log(req, Event{UserID: userID, ActorID: userID, Action: ActionTokenIssued, Metadata: map[string]string{"session_id": "s1"}})
+	l.Log(req, Event{Action: ActionTokenRejected, Resource: "/api/links", Status: 401})
+
+	// Sampled events are recorded once per window
+	used := Event{UserID: userID, ActorID: userID, Action: ActionTokenUsed, Resource: "/api/links"}
+	l.LogSampled(req, "token1", used)
+	now = now.Add(SampleWindow - time.Second)
+	l.LogSampled(req, "token1", used)
+	l.Close()
+
+	// Events logged after Close are dropped
+	l.Log(req, Event{Action: ActionTokenRevoked})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// A nil Logger records nothing
+	var disabled *Logger
+	disabled.Log(req, Event{Action: ActionTokenUsed})
+	disabled.LogSampled(req, "token1", Event{Action: ActionTokenUsed})
+	disabled.Close()
+}
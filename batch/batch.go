@@ -0,0 +1,169 @@
+// Package batch writes rows to the database in batches from a background goroutine,
+// so that recording a row does not wait for the database.
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writeTimeout bounds the time spent writing a batch
+const writeTimeout = 10 * time.Second
+
+// Errors of rows which could not be queued
+var (
+	ErrFull   = errors.New("batch: queue full")
+	ErrClosed = errors.New("batch: writer closed")
+)
+
+// InsertFunc writes a batch of rows
+type InsertFunc func(ctx context.Context, rows []interface{}) error
+
+// Writer queues rows and writes up to batchSize of them at a time, at least every interval
+// while rows are queued. The queue holds up to 10 batches.
+type Writer struct {
+	name      string
+	batchSize int
+	interval  time.Duration
+	wait      time.Duration
+	insert    InsertFunc
+
+	queue chan interface{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New returns a Writer inserting the rows called name in logs. Rows finding the queue full
+// wait up to wait for room, or are dropped right away if wait is 0.
+// Close must be called to write the queued rows.
+func New(name string, batchSize int, interval, wait time.Duration, insert InsertFunc) *Writer {
+	w := &Writer{
+		name:      name,
+		batchSize: batchSize,
+		interval:  interval,
+		wait:      wait,
+		insert:    insert,
+		queue:     make(chan interface{}, 10*batchSize),
+		done:      make(chan struct{}),
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w
+}
+
+// Add queues the row. It returns ErrFull if the queue stayed full for the wait of the Writer,
+// or ErrClosed after Close, and the row is dropped.
+func (w *Writer) Add(row interface{}) error {
+	select {
+	case <-w.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case w.queue <- row:
+		return nil
+	default:
+	}
+
+	if w.wait <= 0 {
+		return ErrFull
+	}
+
+	timer := time.NewTimer(w.wait)
+	defer timer.Stop()
+
+	select {
+	case w.queue <- row:
+		return nil
+	case <-w.done:
+		return ErrClosed
+	case <-timer.C:
+		return ErrFull
+	}
+}
+
+// Close writes the queued rows and stops the Writer
+func (w *Writer) Close() {
+	close(w.done)
+	w.wg.Wait()
+}
+
+func (w *Writer) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	rows := make([]interface{}, 0, w.batchSize)
+	flush := func() {
+		if len(rows) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		defer cancel()
+
+		if err := w.insert(ctx, rows); err != nil {
+			log.Printf("Failed to write %d %s: %v", len(rows), w.name, err)
+		}
+		rows = rows[:0]
+	}
+
+	add := func(row interface{}) {
+		rows = append(rows, row)
+		if len(rows) >= w.batchSize {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case row := <-w.queue:
+			add(row)
+		case <-ticker.C:
+			flush()
+		case <-w.done:
+			// The queue is never closed, so that rows added late are dropped rather than panicking
+			for {
+				select {
+				case row := <-w.queue:
+					add(row)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Values returns the VALUES list of a multi-row insert of n rows with the given number of columns,
+// e.g. ($1, $2), ($3, $4)
+func Values(n, columns int) string {
+	var b strings.Builder
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteByte('(')
+		for c := 1; c <= columns; c++ {
+			if c > 1 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", i*columns+c)
+		}
+		b.WriteByte(')')
+	}
+
+	return b.String()
+}
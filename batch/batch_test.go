@@ -0,0 +1,81 @@
+package batch
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriter_Close(t *testing.T) {
+	var batches [][]interface{}
+	insert := func(ctx context.Context, rows []interface{}) error {
+		batches = append(batches, append([]interface{}(nil), rows...))
+		return nil
+	}
+
+	// Two full batches, the last row is written on close
+	w := New("rows", 2, time.Hour, 0, insert)
+	for i := 1; i <= 5; i++ {
+		if err := w.Add(i); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	w.Close()
+
+	want := [][]interface{}{{1, 2}, {3, 4}, {5}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("got batches %v, want %v", batches, want)
+	}
+
+	if err := w.Add(6); err != ErrClosed {
+		t.Errorf("got error %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestWriter_Add(t *testing.T) {
+	// A writer whose queue is never read
+	full := func(wait time.Duration) *Writer {
+		return &Writer{wait: wait, queue: make(chan interface{}), done: make(chan struct{})}
+	}
+
+	if err := full(0).Add(1); err != ErrFull {
+		t.Errorf("got error %v, want %v", err, ErrFull)
+	}
+
+	if err := full(10 * time.Millisecond).Add(1); err != ErrFull {
+		t.Errorf("got error %v, want %v", err, ErrFull)
+	}
+
+	// Rows wait for room in a full queue
+	w := full(time.Second)
+	received := make(chan interface{}, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		received <- <-w.queue
+	}()
+
+	if err := w.Add(1); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if got := <-received; got != 1 {
+		t.Errorf("got row %v, want 1", got)
+	}
+}
+
+func TestValues(t *testing.T) {
+	var testCases = []struct {
+		n, columns int
+		want       string
+	}{
+		{1, 1, "($1)"},
+		{1, 3, "($1, $2, $3)"},
+		{2, 2, "($1, $2), ($3, $4)"},
+	}
+
+	for _, tc := range testCases {
+		if got := Values(tc.n, tc.columns); got != tc.want {
+			t.Errorf("got %s for %d rows of %d columns, want %s", got, tc.n, tc.columns, tc.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package clicks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/batch"
+)
+
+// Device classes of the user agents making clicks
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+const (
+	// ColumnsPerClick is the number of parameters each click adds to a batch insert
+	ColumnsPerClick = 8
+	// maxHeaderLen is the length referrers and user agents are truncated to
+	maxHeaderLen = 512
+)
+
+// Click is a visit to a link, or to one of its sublinks if SublinkID is set.
+// UserID is the owner of the link, Visitor identifies the client making the click.
+type Click struct {
+	LinkID    string
+	SublinkID string
+	UserID    string
+	Visitor   []byte
+	Referrer  string
+	UserAgent string
+	Device    string
+	CreatedAt time.Time
+}
+
+// Recorder writes clicks to the click_events table in batches from a background goroutine,
+// so that recording a click never waits for the database. Clicks are dropped if the queue is full.
+// A nil Recorder records nothing.
+type Recorder struct {
+	db  *sql.DB
+	now func() time.Time
+	w   *batch.Writer
+}
+
+// NewRecorder returns a Recorder writing up to batchSize clicks at a time, at least every interval
+// while clicks are queued. Close must be called to write the queued clicks.
+func NewRecorder(db *sql.DB, batchSize int, interval time.Duration) *Recorder {
+	rec := &Recorder{db: db, now: time.Now}
+	rec.w = batch.New("clicks", batchSize, interval, 0, rec.insert)
+
+	return rec
+}
+
+// Record queues the click, setting its time and device class if missing
+func (rec *Recorder) Record(c Click) {
+	if rec == nil {
+		return
+	}
+
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = rec.now().UTC()
+	}
+	if c.Device == "" {
+		c.Device = DeviceClass(c.UserAgent)
+	}
+	c.Referrer = sanitize(c.Referrer)
+	c.UserAgent = sanitize(c.UserAgent)
+
+	if err := rec.w.Add(c); err != nil {
+		log.Printf("Dropped click on link %s: %v", c.LinkID, err)
+	}
+}
+
+// Close writes the queued clicks and stops the Recorder. Clicks recorded after Close are dropped.
+func (rec *Recorder) Close() {
+	if rec == nil {
+		return
+	}
+
+	rec.w.Close()
+}
+
+// insert writes the batch of clicks with a single multi-row statement
+func (rec *Recorder) insert(ctx context.Context, rows []interface{}) error {
+	args := make([]interface{}, 0, len(rows)*ColumnsPerClick)
+	for _, r := range rows {
+		c := r.(Click)
+
+		var visitor interface{}
+		if len(c.Visitor) > 0 {
+			visitor = c.Visitor
+		}
+		args = append(args, c.LinkID, nullable(c.SublinkID), c.UserID, visitor, nullable(c.Referrer),
+			nullable(c.UserAgent), c.Device, c.CreatedAt)
+	}
+
+	_, err := rec.db.ExecContext(ctx, `
+		INSERT INTO click_events (link_id, sublink_id, user_id, visitor, referrer, user_agent, device, created_at)
+		VALUES `+batch.Values(len(rows), ColumnsPerClick), args...)
+
+	return err
+}
+
+// DeviceClass returns the coarse class of device of a user agent
+func DeviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	switch {
+	case ua == "":
+		return DeviceUnknown
+	case containsAny(ua, "bot", "crawler", "spider", "slurp", "facebookexternalhit", "preview"):
+		return DeviceBot
+	case containsAny(ua, "ipad", "tablet", "kindle", "silk/") ||
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case containsAny(ua, "mobi", "iphone", "ipod", "android", "windows phone"):
+		return DeviceMobile
+	}
+
+	return DeviceDesktop
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitize truncates a header value and replaces the bytes postgres rejects in text,
+// which would fail the whole batch
+func sanitize(s string) string {
+	if len(s) > maxHeaderLen {
+		s = s[:maxHeaderLen]
+	}
+
+	// Invalid UTF-8 is mapped to utf8.RuneError
+	return strings.Map(func(r rune) rune {
+		if r == 0 {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
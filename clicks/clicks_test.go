@@ -0,0 +1,81 @@
+package clicks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var (
+	link1ID    = "5b8a0a6e-2d76-4c1a-9d1e-1d0f3c7a9b11"
+	sublink1ID = "0a4e6f5d-7c3b-4b2a-8e1f-6d5c4b3a2f10"
+	user1ID    = "fac90185-d243-46f5-8797-e57ac9c2c293"
+)
+
+func TestDeviceClass(t *testing.T) {
+	var testCases = []struct {
+		userAgent string
+		want      string
+	}{
+		{"", DeviceUnknown},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_4) AppleWebKit/605.1.15 Version/13.1 Safari/605.1.15", DeviceDesktop},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 13_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", DeviceMobile},
+		{"Mozilla/5.0 (Linux; Android 10; Pixel 3) AppleWebKit/537.36 Chrome/81.0 Mobile Safari/537.36", DeviceMobile},
+		{"Mozilla/5.0 (iPad; CPU OS 13_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", DeviceTablet},
+		{"Mozilla/5.0 (Linux; Android 9; SM-T720) AppleWebKit/537.36 Chrome/81.0 Safari/537.36", DeviceTablet},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", DeviceBot},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", DeviceBot},
+	}
+
+	for _, tc := range testCases {
+		if got := DeviceClass(tc.userAgent); got != tc.want {
+			t.Errorf("got device %s for %q, want %s", got, tc.userAgent, tc.want)
+		}
+	}
+}
+
+func TestRecorder_Close(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2020, 5, 2, 10, 0, 0, 0, time.UTC)
+	longUA := strings.Repeat("a", maxHeaderLen+10)
+	visitor := []byte("0123456789abcdef")
+
+	// Two full batches, the last click is written on close
+	mock.ExpectExec("INSERT INTO click_events (.+) VALUES \\(\\$1, (.+)\\), \\(\\$9, (.+)\\)$").
+		WithArgs(link1ID, nil, user1ID, visitor, "https://instagram.com/", nil, DeviceUnknown, now,
+			link1ID, sublink1ID, user1ID, nil, nil, strings.Repeat("a", maxHeaderLen), DeviceDesktop, now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO click_events (.+) VALUES \\(\\$1, (.+)\\), \\(\\$9, (.+)\\)$").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO click_events (.+) VALUES \\(\\$1, [^(]+\\)$").
+		WithArgs(link1ID, nil, user1ID, nil, nil, "bad�ua", DeviceDesktop, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := NewRecorder(db, 2, time.Hour)
+	rec.now = func() time.Time { return now }
+
+	rec.Record(Click{LinkID: link1ID, UserID: user1ID, Visitor: visitor, Referrer: "https://instagram.com/"})
+	rec.Record(Click{LinkID: link1ID, SublinkID: sublink1ID, UserID: user1ID, UserAgent: longUA})
+	rec.Record(Click{LinkID: link1ID, UserID: user1ID})
+	rec.Record(Click{LinkID: link1ID, UserID: user1ID})
+	rec.Record(Click{LinkID: link1ID, UserID: user1ID, UserAgent: "bad\xffua\x00"})
+	rec.Close()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var rec *Recorder
+
+	rec.Record(Click{LinkID: link1ID})
+	rec.Close()
+}
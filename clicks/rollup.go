@@ -0,0 +1,193 @@
+package clicks
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/hll"
+)
+
+// NilSublinkID is the sublink id of the clicks on a link itself in the rollups
+const NilSublinkID = "00000000-0000-0000-0000-000000000000"
+
+// sketchKey identifies the visitor sketch of a link, or one of its sublinks, in a day
+type sketchKey struct {
+	userID    string
+	linkID    string
+	sublinkID string
+	day       string
+}
+
+// RollUp adds the clicks made since the last run, and more than lag ago, to the hourly rollups
+// and their visitors to the daily sketches.
+// The lag must exceed the time clicks take to be written, later clicks are never rolled up.
+// Concurrent runs wait for each other, so it can run on every server.
+// Clicks are rolled up a day (UTC) at a time, each in its own transaction, so that a run catching
+// up after a pause holds the sketches of a single day.
+// It returns the time up to which clicks are rolled up.
+func RollUp(ctx context.Context, db *sql.DB, lag time.Duration) (time.Time, error) {
+	return rollUp(ctx, db, time.Now().Add(-lag))
+}
+
+// rollUp rolls up the clicks made before to, one day at a time
+func rollUp(ctx context.Context, db *sql.DB, to time.Time) (time.Time, error) {
+	for {
+		upTo, err := rollUpDay(ctx, db, to)
+		if err != nil || !upTo.Before(to) {
+			return upTo, err
+		}
+	}
+}
+
+// rollUpDay rolls up the clicks made since the last run until the end of its day, or until to
+func rollUpDay(ctx context.Context, db *sql.DB, to time.Time) (time.Time, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	var from time.Time
+	err = tx.QueryRowContext(ctx, "SELECT rolled_up_to FROM click_rollup_state FOR UPDATE").Scan(&from)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !to.After(from) {
+		return from, nil
+	}
+
+	if end := from.UTC().Truncate(day).Add(day); end.Before(to) {
+		to = end
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO click_rollups (user_id, link_id, sublink_id, hour, device, referrer, clicks)
+		SELECT user_id,
+		       link_id,
+		       COALESCE(sublink_id, '`+NilSublinkID+`'),
+		       date_trunc('hour', created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+		       device,
+		       COALESCE(lower(substring(referrer FROM '^[A-Za-z][A-Za-z0-9+.-]*://([^/:?#]+)')), ''),
+		       COUNT(*)
+		  FROM click_events
+		 WHERE created_at >= $1 AND created_at < $2
+		 GROUP BY 1, 2, 3, 4, 5, 6
+		    ON CONFLICT (link_id, sublink_id, hour, device, referrer)
+		    DO UPDATE SET clicks = click_rollups.clicks + EXCLUDED.clicks
+		`, from, to)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := addVisitors(ctx, tx, from, to); err != nil {
+		return time.Time{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE click_rollup_state SET rolled_up_to = $1", to); err != nil {
+		return time.Time{}, err
+	}
+
+	return to, tx.Commit()
+}
+
+// addVisitors adds the visitors of the clicks made between from and to to the daily sketches
+func addVisitors(ctx context.Context, tx *sql.Tx, from, to time.Time) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT user_id,
+		       link_id,
+		       COALESCE(sublink_id, '`+NilSublinkID+`'),
+		       to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'),
+		       visitor
+		  FROM click_events
+		 WHERE created_at >= $1 AND created_at < $2 AND visitor IS NOT NULL
+		`, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	sketches := make(map[sketchKey]*hll.Sketch)
+	for rows.Next() {
+		var (
+			k       sketchKey
+			visitor []byte
+		)
+
+		if err := rows.Scan(&k.userID, &k.linkID, &k.sublinkID, &k.day, &visitor); err != nil {
+			return err
+		}
+		if len(visitor) < 8 {
+			continue
+		}
+
+		s, ok := sketches[k]
+		if !ok {
+			s = hll.New()
+			sketches[k] = s
+		}
+		s.Add(visitorHash(visitor))
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keys := make([]sketchKey, 0, len(sketches))
+	for k := range sketches {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.day != b.day {
+			return a.day < b.day
+		}
+		if a.linkID != b.linkID {
+			return a.linkID < b.linkID
+		}
+		return a.sublinkID < b.sublinkID
+	})
+
+	for _, k := range keys {
+		if err := mergeSketch(ctx, tx, k, sketches[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeSketch merges s into the stored sketch of k. Rollups hold the state lock, so the sketch
+// cannot change in the meantime.
+func mergeSketch(ctx context.Context, tx *sql.Tx, k sketchKey, s *hll.Sketch) error {
+	var stored []byte
+	err := tx.QueryRowContext(ctx, `
+		SELECT sketch FROM visitor_sketches WHERE link_id = $1 AND sublink_id = $2 AND day = $3
+		`, k.linkID, k.sublinkID, k.day).Scan(&stored)
+	switch err {
+	case nil:
+		prev := hll.New()
+		if err := prev.UnmarshalBinary(stored); err != nil {
+			return err
+		}
+		s.Merge(prev)
+	case sql.ErrNoRows:
+	default:
+		return err
+	}
+
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO visitor_sketches (user_id, link_id, sublink_id, day, sketch)
+		VALUES ($1, $2, $3, $4, $5)
+		    ON CONFLICT (link_id, sublink_id, day) DO UPDATE SET sketch = EXCLUDED.sketch
+		`, k.userID, k.linkID, k.sublinkID, k.day, data)
+
+	return err
+}
@@ -0,0 +1,128 @@
+package clicks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/hll"
+)
+
+// sketchOf returns the encoded sketch of the visitors
+func sketchOf(visitors ...[]byte) []byte {
+	s := hll.New()
+	for _, v := range visitors {
+		s.Add(visitorHash(v))
+	}
+
+	data, _ := s.MarshalBinary()
+	return data
+}
+
+func TestRollUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	midnight := time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	visitor1 := []byte("0123456789abcdef")
+	visitor2 := []byte("fedcba9876543210")
+	visitor3 := []byte("a very old visit")
+	stored := sketchOf(visitor3)
+
+	rolledUpTo := func(t time.Time) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT rolled_up_to FROM click_rollup_state FOR UPDATE").
+			WillReturnRows(sqlmock.NewRows([]string{"rolled_up_to"}).AddRow(t))
+	}
+
+	noClicks := func(from, to time.Time) {
+		mock.ExpectExec("INSERT INTO click_rollups").WithArgs(from, to).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("FROM click_events").WithArgs(from, to).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "link_id", "sublink_id", "day", "visitor"}))
+		mock.ExpectExec("UPDATE click_rollup_state SET rolled_up_to").WithArgs(to).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	var testCases = []struct {
+		name string
+		to   time.Time
+		dbTx func()
+	}{
+		{
+			name: "Clicks to roll up",
+			to:   from.Add(time.Hour),
+			dbTx: func() {
+				rolledUpTo(from)
+				mock.ExpectExec("INSERT INTO click_rollups (.+) ON CONFLICT").WithArgs(from, from.Add(time.Hour)).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectQuery("SELECT (.+) FROM click_events WHERE (.+) visitor IS NOT NULL").WithArgs(from, from.Add(time.Hour)).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "link_id", "sublink_id", "day", "visitor"}).
+						AddRow(user1ID, link1ID, sublink1ID, "2020-05-01", visitor1).
+						AddRow(user1ID, link1ID, NilSublinkID, "2020-05-01", visitor1).
+						AddRow(user1ID, link1ID, NilSublinkID, "2020-05-01", visitor2))
+
+				// Sketches are merged with the stored ones, by day, link and sublink
+				mock.ExpectQuery("SELECT sketch FROM visitor_sketches").WithArgs(link1ID, NilSublinkID, "2020-05-01").
+					WillReturnRows(sqlmock.NewRows([]string{"sketch"}).AddRow(stored))
+				mock.ExpectExec("INSERT INTO visitor_sketches (.+) ON CONFLICT").
+					WithArgs(user1ID, link1ID, NilSublinkID, "2020-05-01", sketchOf(visitor1, visitor2, visitor3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("SELECT sketch FROM visitor_sketches").WithArgs(link1ID, sublink1ID, "2020-05-01").
+					WillReturnRows(sqlmock.NewRows([]string{"sketch"}))
+				mock.ExpectExec("INSERT INTO visitor_sketches (.+) ON CONFLICT").
+					WithArgs(user1ID, link1ID, sublink1ID, "2020-05-01", sketchOf(visitor1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE click_rollup_state SET rolled_up_to").WithArgs(from.Add(time.Hour)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Catching up a day at a time",
+			to:   midnight.Add(day + time.Hour),
+			dbTx: func() {
+				rolledUpTo(from)
+				noClicks(from, midnight)
+				rolledUpTo(midnight)
+				noClicks(midnight, midnight.Add(day))
+				rolledUpTo(midnight.Add(day))
+				noClicks(midnight.Add(day), midnight.Add(day+time.Hour))
+			},
+		},
+		{
+			name: "Rolled up within the lag",
+			to:   from,
+			dbTx: func() {
+				rolledUpTo(from)
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.dbTx()
+
+			to, err := rollUp(context.Background(), db, tc.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !to.Equal(tc.to) {
+				t.Errorf("got rolled up to %v, want %v", to, tc.to)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package clicks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const (
+	// visitorLen is the length of visitor ids
+	visitorLen = 16
+	saltLen    = 32
+	day        = 24 * time.Hour
+)
+
+// Visitors identifies the clients making clicks with a keyed hash of their address and user agent.
+// The key is a random salt shared by all servers through the visitor_salts table, replaced every day
+// and deleted afterwards, so that ids cannot be traced back to an address nor linked across days.
+// A nil Visitors identifies no one.
+type Visitors struct {
+	db  *sql.DB
+	now func() time.Time
+
+	mu   sync.Mutex
+	day  time.Time
+	salt []byte
+}
+
+// NewVisitors returns Visitors keeping the salts in db
+func NewVisitors(db *sql.DB) *Visitors {
+	return &Visitors{db: db, now: time.Now}
+}
+
+// ID returns the id of the client with the given address and user agent for the current day (UTC)
+func (v *Visitors) ID(ctx context.Context, remoteIP, userAgent string) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	salt, err := v.currentSalt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(remoteIP + "\n" + userAgent))
+
+	return mac.Sum(nil)[:visitorLen], nil
+}
+
+// currentSalt returns the salt of the day, creating it if this is the first server to need it.
+// The salt is fetched outside of the lock, so that clicks never queue behind the db.
+func (v *Visitors) currentSalt(ctx context.Context) ([]byte, error) {
+	today := v.now().UTC().Truncate(day)
+
+	v.mu.Lock()
+	if today.Equal(v.day) {
+		salt := v.salt
+		v.mu.Unlock()
+		return salt, nil
+	}
+	v.mu.Unlock()
+
+	salt, err := v.fetchSalt(ctx, today)
+	if err != nil {
+		return nil, err
+	}
+
+	// A concurrent fetch may have stored the salt of a later day meanwhile
+	v.mu.Lock()
+	if today.After(v.day) {
+		v.day, v.salt = today, salt
+	}
+	v.mu.Unlock()
+
+	return salt, nil
+}
+
+// fetchSalt returns the salt of the day from the db, creating it if missing and deleting the older ones
+func (v *Visitors) fetchSalt(ctx context.Context, today time.Time) ([]byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO visitor_salts (day, salt) VALUES ($1, $2)
+		    ON CONFLICT (day) DO NOTHING
+		`, today, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.QueryRowContext(ctx, "SELECT salt FROM visitor_salts WHERE day = $1", today).Scan(&salt); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM visitor_salts WHERE day < $1", today); err != nil {
+		return nil, err
+	}
+
+	return salt, tx.Commit()
+}
+
+// visitorHash returns the 64-bit hash of a visitor id added to sketches
+func visitorHash(id []byte) uint64 {
+	return binary.BigEndian.Uint64(id)
+}
@@ -0,0 +1,78 @@
+package clicks
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestVisitors_ID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	ua := "Mozilla/5.0 (iPhone; CPU iPhone OS 13_4 like Mac OS X)"
+	day1 := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.Add(day)
+
+	expectSalt := func(d time.Time, salt string) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO visitor_salts").WithArgs(d, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT salt FROM visitor_salts").WithArgs(d).
+			WillReturnRows(sqlmock.NewRows([]string{"salt"}).AddRow([]byte(salt)))
+		mock.ExpectExec("DELETE FROM visitor_salts WHERE day <").WithArgs(d).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	v := NewVisitors(db)
+	now := day1.Add(10 * time.Hour)
+	v.now = func() time.Time { return now }
+
+	// The salt is loaded once a day
+	expectSalt(day1, "salt of the first day")
+	first, err := v.ID(ctx, "10.0.0.1", ua)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != visitorLen {
+		t.Errorf("got visitor id of %d bytes, want %d", len(first), visitorLen)
+	}
+
+	now = now.Add(time.Hour)
+	same, _ := v.ID(ctx, "10.0.0.1", ua)
+	other, _ := v.ID(ctx, "10.0.0.2", ua)
+	if !bytes.Equal(first, same) {
+		t.Error("got different ids for the same visitor in a day")
+	}
+	if bytes.Equal(first, other) {
+		t.Error("got the same id for different addresses")
+	}
+
+	expectSalt(day2, "salt of the second day")
+	now = day2.Add(time.Minute)
+	next, err := v.ID(ctx, "10.0.0.1", ua)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, next) {
+		t.Error("got the same id for a visitor on different days")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVisitors_Nil(t *testing.T) {
+	var v *Visitors
+
+	if id, err := v.ID(context.Background(), "10.0.0.1", ""); id != nil || err != nil {
+		t.Errorf("got id %v and error %v, want none", id, err)
+	}
+}
@@ -1,33 +1,89 @@
 package errors
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/alessio-palumbo/linktree-challenge/validator"
 )
 
-// TODO define an error response struct and methods
+// Machine readable codes shared across handlers
+const (
+	CodeMalformedBody    = "malformed_body"
+	CodeValidationFailed = "validation_failed"
 
-// WriteError prints an json error with the given status and message
+	problemContentType = "application/problem+json"
+)
+
+// Error is an error with a machine readable code which is reported to the client
+type Error struct {
+	Code    string
+	Message string
+}
+
+// New returns a new Error with the given code and message
+func New(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Problem is the error envelope returned by the api, following RFC 7807 (problem details)
+type Problem struct {
+	Type   string                 `json:"type"`
+	Title  string                 `json:"title"`
+	Status int                    `json:"status"`
+	Code   string                 `json:"code"`
+	Detail string                 `json:"detail,omitempty"`
+	Errors []validator.FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the Problem for the given status and error.
+// The code defaults to the snake cased status text unless the error carries its own.
+func NewProblem(status int, err interface{}) Problem {
+	p := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   strings.ToLower(strings.Replace(http.StatusText(status), " ", "_", -1)),
+		Detail: fmt.Sprint(err),
+	}
+
+	switch t := err.(type) {
+	case *Error:
+		p.Code = t.Code
+	case validator.Errors:
+		p.Code = CodeValidationFailed
+		p.Errors = t
+	}
+
+	return p
+}
+
+// WriteError prints a problem json error with the given status and error
 func WriteError(w http.ResponseWriter, status int, err interface{}) {
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", problemContentType)
 	w.WriteHeader(status)
-	fmt.Fprint(w, JSONError(err))
+	fmt.Fprint(w, JSONError(status, err))
 }
 
-// JSONError formats an error to a json response
-func JSONError(err interface{}) string {
-	return fmt.Sprintf(`{"error":%q}`, err)
+// JSONError formats an error to a problem json response
+func JSONError(status int, err interface{}) string {
+	b, _ := json.Marshal(NewProblem(status, err))
+	return string(b)
 }
 
 // CheckValid is a helper that checks the error coming from an unmarshalling and
-// validate the interface through the custom validator
-func CheckValid(err error, i interface{}, cv *validator.CustomValidator) error {
+// validate the interface through the custom validator, translating errors in the given locales
+func CheckValid(err error, i interface{}, cv *validator.CustomValidator, locales ...string) error {
 	if err != nil {
-		return err
+		return New(CodeMalformedBody, err.Error())
 	}
 
-	err = cv.Validate(i)
+	err = cv.Validate(i, locales...)
 	return err
 }
@@ -0,0 +1,125 @@
+package analytics
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+)
+
+const (
+	codeInvalidQuery = "invalid_query"
+
+	// defaultRange is the period counted when from is not given
+	defaultRange = 7 * 24 * time.Hour
+	// maxBuckets bounds the number of intervals in the requested period
+	maxBuckets = 1000
+)
+
+// intervals are the lengths of the buckets clicks can be counted in, weeks start on Monday
+var intervals = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// query selects the clicks on the links of a user, or on one of them, between from and to,
+// in whole hours since clicks are rolled up by the hour. Interval is only set to count clicks by bucket.
+type query struct {
+	userID   string
+	linkID   string
+	from     time.Time
+	to       time.Time
+	interval string
+}
+
+// parseQuery reads the query from the request parameters, counting clicks by day unless
+// another interval is given
+func parseQuery(r *http.Request, userID string) (query, error) {
+	q, err := parsePeriod(r, userID)
+	if err != nil {
+		return q, err
+	}
+
+	q.interval = r.FormValue("interval")
+	if q.interval == "" {
+		q.interval = "day"
+	}
+	length, ok := intervals[q.interval]
+	if !ok {
+		return q, e.New(codeInvalidQuery, "interval must be hour, day or week")
+	}
+
+	if q.to.Sub(q.from)/length > maxBuckets {
+		return q, e.New(codeInvalidQuery, fmt.Sprintf("the period cannot span more than %d intervals", maxBuckets))
+	}
+
+	return q, nil
+}
+
+// parsePeriod reads the link and the period of the query from the request parameters.
+// The period defaults to the last 7 days.
+func parsePeriod(r *http.Request, userID string) (query, error) {
+	q := query{
+		userID: userID,
+		linkID: r.FormValue("link_id"),
+		to:     time.Now(),
+	}
+
+	if q.linkID != "" {
+		if _, err := uuid.Parse(q.linkID); err != nil {
+			return q, e.New(codeInvalidQuery, "link_id must be a uuid")
+		}
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &q.from}, {"to", &q.to}} {
+		v := r.FormValue(t.name)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, e.New(codeInvalidQuery, t.name+" must be an RFC 3339 time")
+		}
+		*t.dst = parsed
+	}
+
+	if q.from.IsZero() {
+		q.from = q.to.Add(-defaultRange)
+	}
+
+	// Round the period out to whole hours
+	q.from = q.from.UTC().Truncate(time.Hour)
+	if to := q.to.UTC().Truncate(time.Hour); to.Before(q.to) {
+		q.to = to.Add(time.Hour)
+	} else {
+		q.to = to
+	}
+
+	if !q.from.Before(q.to) {
+		return q, e.New(codeInvalidQuery, "from must be before to")
+	}
+
+	return q, nil
+}
+
+// where returns the conditions of the query on a table with the given time column and their arguments
+func (q query) where(timeColumn string) (string, []interface{}) {
+	conds := []string{"user_id = $1", timeColumn + " >= $2", timeColumn + " < $3"}
+	args := []interface{}{q.userID, q.from, q.to}
+
+	if q.linkID != "" {
+		args = append(args, q.linkID)
+		conds = append(conds, fmt.Sprintf("link_id = $%d", len(args)))
+	}
+
+	return strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,315 @@
+package analytics
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/hll"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+// flushEvery is the number of rows written between flushes of the response
+const flushEvery = 500
+
+// record is a row of an export, written as CSV fields or as JSON
+type record interface {
+	fields() []string
+}
+
+// clickRecord is a click in the exports
+type clickRecord struct {
+	Time      time.Time `json:"time"`
+	LinkID    string    `json:"link_id"`
+	SublinkID string    `json:"sublink_id,omitempty"`
+	Device    string    `json:"device"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+var clickHeader = []string{"time", "link_id", "sublink_id", "device", "referrer", "user_agent"}
+
+func (c clickRecord) fields() []string {
+	return []string{c.Time.UTC().Format(time.RFC3339), c.LinkID, c.SublinkID, c.Device, c.Referrer, c.UserAgent}
+}
+
+// dailyRecord counts the clicks on a link, or on one of its sublinks, in a day
+type dailyRecord struct {
+	Date      string `json:"date"`
+	LinkID    string `json:"link_id"`
+	SublinkID string `json:"sublink_id,omitempty"`
+	Clicks    int64  `json:"clicks"`
+	Visitors  int64  `json:"visitors"`
+}
+
+var dailyHeader = []string{"date", "link_id", "sublink_id", "clicks", "visitors"}
+
+func (d dailyRecord) fields() []string {
+	return []string{d.Date, d.LinkID, d.SublinkID, strconv.FormatInt(d.Clicks, 10), strconv.FormatInt(d.Visitors, 10)}
+}
+
+// ExportHandler streams the clicks on the links of the profile over a period, or their daily counts,
+// as CSV or JSON Lines. Rows are written as they are read from the db, and complete exports end
+// with the handlers.StreamCompleteTrailer.
+type ExportHandler handlers.Group
+
+func (h ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	ctx := r.Context()
+	q, err := parsePeriod(r, middleware.CtxProfileUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		e.WriteError(w, http.StatusBadRequest, e.New(codeInvalidQuery, "format must be csv or ndjson"))
+		return
+	}
+
+	var (
+		name   = r.FormValue("data")
+		header []string
+		stmt   string
+		args   []interface{}
+		scan   func(*sql.Rows) (record, error)
+	)
+
+	switch name {
+	case "", "clicks":
+		name, header, scan = "clicks", clickHeader, scanClick
+		stmt, args = clicksQuery(q)
+	case "daily":
+		header, scan = dailyHeader, scanDaily
+		stmt, args = dailyQuery(q)
+	default:
+		e.WriteError(w, http.StatusBadRequest, e.New(codeInvalidQuery, "data must be clicks or daily"))
+		return
+	}
+
+	// The deadline of the server covers the query, chunks are given their own afterwards
+	stream, err := handlers.NewStream(w)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	var enc encoder
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		enc = newCSVEncoder(w, header)
+	default:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.jsonl"`, name))
+		enc = jsonEncoder{json.NewEncoder(w)}
+	}
+
+	flush := func() error {
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		return stream.Flush()
+	}
+
+	// The status is sent with the first rows, so later errors truncate the export
+	var n int
+	err = func() error {
+		for rows.Next() {
+			rec, err := scan(rows)
+			if err != nil {
+				return err
+			}
+
+			if err := enc.encode(rec); err != nil {
+				return err
+			}
+
+			if n++; n%flushEvery == 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		return stream.Complete()
+	}()
+	if err != nil {
+		log.Printf("Analytics export interrupted after %d rows: %v", n, err)
+	}
+}
+
+// clicksQuery selects the clicks of the query, oldest first
+func clicksQuery(q query) (string, []interface{}) {
+	where, args := q.where("created_at")
+
+	return `
+		SELECT created_at, link_id, sublink_id, device, referrer, user_agent
+		  FROM click_events
+		 WHERE ` + where + `
+		 ORDER BY created_at, id`, args
+}
+
+func scanClick(rows *sql.Rows) (record, error) {
+	var (
+		c                              clickRecord
+		sublinkID, referrer, userAgent *string
+	)
+
+	err := rows.Scan(&c.Time, &c.LinkID, &sublinkID, &c.Device, &referrer, &userAgent)
+	if err != nil {
+		return nil, err
+	}
+	c.SublinkID, c.Referrer, c.UserAgent = value(sublinkID), value(referrer), value(userAgent)
+
+	return c, nil
+}
+
+// dailyQuery selects the rolled up clicks of the query by day, link and sublink, with the visitor
+// sketch of the whole day
+func dailyQuery(q query) (string, []interface{}) {
+	where, args := q.where("hour")
+
+	return `
+		SELECT to_char(c.day, 'YYYY-MM-DD'), c.link_id, c.sublink_id, c.clicks, s.sketch
+		  FROM (SELECT date_trunc('day', hour AT TIME ZONE 'UTC') AS day, link_id, sublink_id,
+		               SUM(clicks)::bigint AS clicks
+		          FROM click_rollups
+		         WHERE ` + where + `
+		         GROUP BY 1, 2, 3) c
+		  LEFT JOIN visitor_sketches s
+		    ON s.link_id = c.link_id AND s.sublink_id = c.sublink_id AND s.day = c.day::date
+		 ORDER BY c.day, c.link_id, c.sublink_id`, args
+}
+
+func scanDaily(rows *sql.Rows) (record, error) {
+	var (
+		d      dailyRecord
+		sketch []byte
+	)
+
+	if err := rows.Scan(&d.Date, &d.LinkID, &d.SublinkID, &d.Clicks, &sketch); err != nil {
+		return nil, err
+	}
+	if d.SublinkID == clicks.NilSublinkID {
+		d.SublinkID = ""
+	}
+
+	if sketch != nil {
+		s := hll.New()
+		if err := s.UnmarshalBinary(sketch); err != nil {
+			return nil, err
+		}
+		d.Visitors = int64(s.Count())
+	}
+
+	return d, nil
+}
+
+// encoder writes the records of an export in a format
+type encoder interface {
+	encode(record) error
+	flush() error
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header []string
+}
+
+// newCSVEncoder returns an encoder writing the header before the first record
+func newCSVEncoder(w io.Writer, header []string) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w), header: header}
+}
+
+func (c *csvEncoder) encode(rec record) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	fields := rec.fields()
+	for i, f := range fields {
+		fields[i] = escapeFormula(f)
+	}
+
+	return c.w.Write(fields)
+}
+
+func (c *csvEncoder) flush() error {
+	// Empty exports still have the header
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvEncoder) writeHeader() error {
+	if c.header == nil {
+		return nil
+	}
+
+	err := c.w.Write(c.header)
+	c.header = nil
+	return err
+}
+
+type jsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (j jsonEncoder) encode(rec record) error {
+	return j.enc.Encode(rec)
+}
+
+func (j jsonEncoder) flush() error {
+	return nil
+}
+
+// escapeFormula prefixes values that spreadsheets would evaluate as formulas, such as
+// referrers and user agents sent by visitors
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,159 @@
+package analytics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+func TestExportHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 5, 3, 0, 0, 0, 0, time.UTC)
+	period := "from=2020-05-01T00:00:00Z&to=2020-05-03T00:00:00Z"
+
+	clickRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"created_at", "link_id", "sublink_id", "device", "referrer", "user_agent"}).
+			AddRow(from.Add(time.Hour), link1ID, nil, "mobile", "https://instagram.com/", "Mozilla/5.0 (iPhone)").
+			AddRow(from.Add(2*time.Hour), link1ID, sublink1ID, "unknown", nil, "=HYPERLINK(\"http://evil.com\")")
+	}
+
+	var testCases = []struct {
+		name            string
+		query           string
+		dbQuery         func()
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+		wantComplete    bool
+	}{
+		{
+			name:  "Clicks as CSV",
+			query: "?" + period,
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT created_at, (.+) FROM click_events WHERE user_id = \\$1 AND created_at >= \\$2 "+
+					"AND created_at < \\$3 ORDER BY created_at, id").
+					WithArgs(user1ID, from, to).WillReturnRows(clickRows())
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody: "time,link_id,sublink_id,device,referrer,user_agent\n" +
+				"2020-05-01T01:00:00Z," + link1ID + ",,mobile,https://instagram.com/,Mozilla/5.0 (iPhone)\n" +
+				"2020-05-01T02:00:00Z," + link1ID + "," + sublink1ID + ",unknown,,\"'=HYPERLINK(\"\"http://evil.com\"\")\"\n",
+			wantComplete: true,
+		},
+		{
+			name:  "Clicks of a link as JSON Lines",
+			query: "?format=ndjson&link_id=" + link1ID + "&" + period,
+			dbQuery: func() {
+				mock.ExpectQuery("FROM click_events WHERE (.+) AND link_id = \\$4").
+					WithArgs(user1ID, from, to, link1ID).WillReturnRows(clickRows())
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			wantBody: `{"time":"2020-05-01T01:00:00Z","link_id":"` + link1ID + `","device":"mobile",` +
+				`"referrer":"https://instagram.com/","user_agent":"Mozilla/5.0 (iPhone)"}` + "\n" +
+				`{"time":"2020-05-01T02:00:00Z","link_id":"` + link1ID + `","sublink_id":"` + sublink1ID + `",` +
+				`"device":"unknown","user_agent":"=HYPERLINK(\"http://evil.com\")"}` + "\n",
+			wantComplete: true,
+		},
+		{
+			name:  "Daily counts as CSV",
+			query: "?data=daily&" + period,
+			dbQuery: func() {
+				mock.ExpectQuery("FROM click_rollups (.+) LEFT JOIN visitor_sketches").
+					WithArgs(user1ID, from, to).
+					WillReturnRows(sqlmock.NewRows([]string{"day", "link_id", "sublink_id", "clicks", "sketch"}).
+						AddRow("2020-05-01", link1ID, clicks.NilSublinkID, 1, sketchOf(1)).
+						AddRow("2020-05-01", link1ID, sublink1ID, 1, nil))
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody: "date,link_id,sublink_id,clicks,visitors\n" +
+				"2020-05-01," + link1ID + ",,1,1\n" +
+				"2020-05-01," + link1ID + "," + sublink1ID + ",1,0\n",
+			wantComplete: true,
+		},
+		{
+			name:  "No clicks",
+			query: "?data=daily&" + period,
+			dbQuery: func() {
+				mock.ExpectQuery("FROM click_rollups").WithArgs(user1ID, from, to).
+					WillReturnRows(sqlmock.NewRows([]string{"day", "link_id", "sublink_id", "clicks", "sketch"}))
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        "date,link_id,sublink_id,clicks,visitors\n",
+			wantComplete:    true,
+		},
+		{
+			name:  "Interrupted export",
+			query: "?" + period,
+			dbQuery: func() {
+				mock.ExpectQuery("FROM click_events").WithArgs(user1ID, from, to).
+					WillReturnRows(clickRows().RowError(1, errors.New("connection reset")))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Invalid format",
+			query:      "?format=xlsx",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid data",
+			query:      "?data=hourly",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbQuery != nil {
+				tc.dbQuery()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/links/analytics/export"+tc.query, nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			recorder := httptest.NewRecorder()
+
+			ExportHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if tc.wantBody != "" {
+				if got := recorder.Header().Get("Content-Type"); got != tc.wantContentType {
+					t.Errorf("got Content-Type %s, want %s", got, tc.wantContentType)
+				}
+
+				if got := recorder.Body.String(); got != tc.wantBody {
+					t.Errorf("got body %s, want %s", got, tc.wantBody)
+				}
+			}
+
+			complete := recorder.Result().Trailer.Get(handlers.StreamCompleteTrailer) == "true"
+			if complete != tc.wantComplete {
+				t.Errorf("got complete %t, want %t", complete, tc.wantComplete)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
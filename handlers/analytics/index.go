@@ -0,0 +1,328 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/hll"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+// topReferrers is the number of referrers returned, by number of clicks
+const topReferrers = 10
+
+// IndexHandler returns the clicks and visitors of each link and sublink of the profile over a period,
+// with the top referrers and the clicks by device.
+type IndexHandler handlers.Group
+
+func (h IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	ctx := r.Context()
+	q, err := parseQuery(r, middleware.CtxProfileUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	res := models.Analytics{From: q.from, To: q.to, Interval: q.interval}
+
+	res.Links, err = linkCounts(ctx, h.DB, q)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res.Referrers, err = breakdown(ctx, h.DB, q, "referrer", topReferrers)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res.Devices, err = breakdown(ctx, h.DB, q, "device", 0)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, res)
+}
+
+// countKey identifies a count of the clicks on a link, or one of its sublinks if set,
+// in a bucket, or over the whole period if total is set
+type countKey struct {
+	linkID    string
+	sublinkID string
+	bucket    time.Time
+	total     bool
+}
+
+// linkCounts returns the counts of each link, most clicked first
+func linkCounts(ctx context.Context, db *sql.DB, q query) ([]models.LinkAnalytics, error) {
+	counts := make(map[countKey]*models.ClickCount)
+	count := func(k countKey) *models.ClickCount {
+		c, ok := counts[k]
+		if !ok {
+			c = &models.ClickCount{}
+			counts[k] = c
+		}
+		return c
+	}
+
+	err := clickCounts(ctx, db, q, func(k countKey, n int64) {
+		count(k).Clicks = n
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = visitorCounts(ctx, db, q, func(k countKey, n int64) {
+		count(k).Visitors = n
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	links := make(map[string]*models.LinkAnalytics)
+	sublinks := make(map[[2]string]*models.SublinkAnalytics)
+	for k, c := range counts {
+		l, ok := links[k.linkID]
+		if !ok {
+			l = &models.LinkAnalytics{LinkID: k.linkID, Buckets: []models.ClickBucket{}}
+			links[k.linkID] = l
+		}
+
+		switch {
+		case k.sublinkID == "" && k.total:
+			l.ClickCount = *c
+		case k.sublinkID == "":
+			l.Buckets = append(l.Buckets, models.ClickBucket{Start: k.bucket, ClickCount: *c})
+		case k.sublinkID == clicks.NilSublinkID:
+			// Clicks on the link itself are only part of the link counts
+		default:
+			sk := [2]string{k.linkID, k.sublinkID}
+			sl, ok := sublinks[sk]
+			if !ok {
+				sl = &models.SublinkAnalytics{SublinkID: k.sublinkID, Buckets: []models.ClickBucket{}}
+				sublinks[sk] = sl
+			}
+
+			if k.total {
+				sl.ClickCount = *c
+			} else {
+				sl.Buckets = append(sl.Buckets, models.ClickBucket{Start: k.bucket, ClickCount: *c})
+			}
+		}
+	}
+
+	for sk, sl := range sublinks {
+		sortBuckets(sl.Buckets)
+		l := links[sk[0]]
+		l.Sublinks = append(l.Sublinks, *sl)
+	}
+
+	res := make([]models.LinkAnalytics, 0, len(links))
+	for _, l := range links {
+		sortBuckets(l.Buckets)
+		sort.Slice(l.Sublinks, func(i, j int) bool {
+			return mostClicked(l.Sublinks[i].ClickCount, l.Sublinks[j].ClickCount,
+				l.Sublinks[i].SublinkID, l.Sublinks[j].SublinkID)
+		})
+		res = append(res, *l)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return mostClicked(res[i].ClickCount, res[j].ClickCount, res[i].LinkID, res[j].LinkID)
+	})
+
+	return res, nil
+}
+
+// clickCounts calls fn with the clicks of each link and sublink, by bucket and over the whole period.
+// Counts of a link and all its sublinks have no sublink id.
+func clickCounts(ctx context.Context, db *sql.DB, q query, fn func(countKey, int64)) error {
+	where, args := q.where("hour")
+	args = append(args, q.interval)
+
+	stmt := fmt.Sprintf(`
+		SELECT link_id, sublink_id, bucket, SUM(clicks)::bigint
+		  FROM (SELECT link_id,
+		               sublink_id,
+		               date_trunc($%d, hour AT TIME ZONE 'UTC') AS bucket,
+		               clicks
+		          FROM click_rollups
+		         WHERE %s) c
+		 GROUP BY GROUPING SETS ((link_id, sublink_id, bucket), (link_id, sublink_id), (link_id, bucket), (link_id))
+		`, len(args), where)
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			k         countKey
+			sublinkID *string
+			bucket    *time.Time
+			n         int64
+		)
+
+		if err := rows.Scan(&k.linkID, &sublinkID, &bucket, &n); err != nil {
+			return err
+		}
+
+		if sublinkID != nil {
+			k.sublinkID = *sublinkID
+		}
+		if bucket != nil {
+			k.bucket = bucket.UTC()
+		} else {
+			k.total = true
+		}
+
+		fn(k, n)
+	}
+
+	return rows.Err()
+}
+
+// visitorCounts calls fn with the distinct visitors of each link and sublink, by bucket and over
+// the whole period, merging the daily sketches of the days overlapping it. Visitors are identified
+// by day, so they are not counted by hour.
+func visitorCounts(ctx context.Context, db *sql.DB, q query, fn func(countKey, int64)) error {
+	days := q
+	days.from = q.from.Truncate(24 * time.Hour)
+	if days.to = q.to.Truncate(24 * time.Hour); days.to.Before(q.to) {
+		days.to = days.to.Add(24 * time.Hour)
+	}
+	where, args := days.where("day")
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT link_id, sublink_id, day, sketch
+		  FROM visitor_sketches
+		 WHERE `+where, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	sketches := make(map[countKey]*hll.Sketch)
+	merge := func(k countKey, s *hll.Sketch) {
+		merged, ok := sketches[k]
+		if !ok {
+			merged = hll.New()
+			sketches[k] = merged
+		}
+		merged.Merge(s)
+	}
+
+	for rows.Next() {
+		var (
+			linkID, sublinkID string
+			day               time.Time
+			data              []byte
+		)
+
+		if err := rows.Scan(&linkID, &sublinkID, &day, &data); err != nil {
+			return err
+		}
+
+		s := hll.New()
+		if err := s.UnmarshalBinary(data); err != nil {
+			return err
+		}
+
+		keys := []countKey{
+			{linkID: linkID, sublinkID: sublinkID, total: true},
+			{linkID: linkID, total: true},
+		}
+		if q.interval != "hour" {
+			bucket := bucketStart(day, q.interval)
+			keys = append(keys,
+				countKey{linkID: linkID, sublinkID: sublinkID, bucket: bucket},
+				countKey{linkID: linkID, bucket: bucket})
+		}
+
+		for _, k := range keys {
+			merge(k, s)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for k, s := range sketches {
+		fn(k, int64(s.Count()))
+	}
+
+	return nil
+}
+
+// bucketStart returns the start of the day or week (starting on Monday) of a day
+func bucketStart(day time.Time, interval string) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if interval == "week" {
+		day = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+
+	return day
+}
+
+// breakdown returns the clicks by value of the rollup column, most frequent first.
+// Empty values are left out, all values are returned if limit is zero.
+func breakdown(ctx context.Context, db *sql.DB, q query, column string, limit int) ([]models.Breakdown, error) {
+	where, args := q.where("hour")
+
+	stmt := fmt.Sprintf(`
+		SELECT %[1]s, SUM(clicks)::bigint
+		  FROM click_rollups
+		 WHERE %[2]s AND %[1]s <> ''
+		 GROUP BY %[1]s
+		 ORDER BY 2 DESC, 1`, column, where)
+	if limit > 0 {
+		args = append(args, limit)
+		stmt += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []models.Breakdown{}
+	for rows.Next() {
+		var b models.Breakdown
+		if err := rows.Scan(&b.Value, &b.Clicks); err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+
+	return res, rows.Err()
+}
+
+func sortBuckets(b []models.ClickBucket) {
+	sort.Slice(b, func(i, j int) bool { return b[i].Start.Before(b[j].Start) })
+}
+
+// mostClicked orders counts by clicks, then by id so that the order is stable
+func mostClicked(a, b models.ClickCount, idA, idB string) bool {
+	if a.Clicks != b.Clicks {
+		return a.Clicks > b.Clicks
+	}
+	return idA < idB
+}
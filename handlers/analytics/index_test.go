@@ -0,0 +1,159 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/hll"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+var (
+	user1ID    = "fac90185-d243-46f5-8797-e57ac9c2c293"
+	link1ID    = "b626168a-6c34-44cb-bf94-667c76235a26"
+	sublink1ID = "e9c5f2b8-6a3e-4b1f-9d2c-7f8a1b3c4d5e"
+)
+
+// sketchOf returns the encoded sketch of the visitors with the given hashes
+func sketchOf(visitors ...uint64) []byte {
+	s := hll.New()
+	for _, v := range visitors {
+		// Spread the hashes over the registers
+		s.Add(v * 0x9e3779b97f4a7c15)
+	}
+
+	data, _ := s.MarshalBinary()
+	return data
+}
+
+func TestIndexHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 5, 3, 0, 0, 0, 0, time.UTC)
+	day1 := from
+	day2 := from.Add(24 * time.Hour)
+
+	var testCases = []struct {
+		name       string
+		query      string
+		dbQuery    func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:  "Clicks by day",
+			query: "?from=2020-05-01T00:00:00Z&to=2020-05-02T23:30:00Z",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT link_id, sublink_id, bucket, SUM\\(clicks\\)(.+)FROM click_rollups "+
+					"WHERE user_id = \\$1 AND hour >= \\$2 AND hour < \\$3\\) c GROUP BY GROUPING SETS").
+					WithArgs(user1ID, from, to, "day").
+					WillReturnRows(sqlmock.NewRows([]string{"link_id", "sublink_id", "bucket", "sum"}).
+						AddRow(link1ID, sublink1ID, day1, 3).
+						AddRow(link1ID, clicks.NilSublinkID, day1, 1).
+						AddRow(link1ID, clicks.NilSublinkID, day2, 1).
+						AddRow(link1ID, sublink1ID, nil, 3).
+						AddRow(link1ID, clicks.NilSublinkID, nil, 2).
+						AddRow(link1ID, nil, day2, 1).
+						AddRow(link1ID, nil, day1, 4).
+						AddRow(link1ID, nil, nil, 5))
+				mock.ExpectQuery("SELECT link_id, sublink_id, day, sketch FROM visitor_sketches "+
+					"WHERE user_id = \\$1 AND day >= \\$2 AND day < \\$3$").
+					WithArgs(user1ID, from, to).
+					WillReturnRows(sqlmock.NewRows([]string{"link_id", "sublink_id", "day", "sketch"}).
+						AddRow(link1ID, sublink1ID, day1, sketchOf(1, 2)).
+						AddRow(link1ID, clicks.NilSublinkID, day1, sketchOf(1, 3)).
+						AddRow(link1ID, clicks.NilSublinkID, day2, sketchOf(4)))
+				mock.ExpectQuery("SELECT referrer, SUM\\(clicks\\)(.+)referrer <> '' GROUP BY referrer ORDER BY 2 DESC, 1 LIMIT \\$4").
+					WithArgs(user1ID, from, to, topReferrers).
+					WillReturnRows(sqlmock.NewRows([]string{"referrer", "sum"}).AddRow("instagram.com", 4))
+				mock.ExpectQuery("SELECT device, SUM\\(clicks\\)(.+)GROUP BY device ORDER BY 2 DESC, 1$").
+					WithArgs(user1ID, from, to).
+					WillReturnRows(sqlmock.NewRows([]string{"device", "sum"}).AddRow("mobile", 4).AddRow("desktop", 1))
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `{"from":"2020-05-01T00:00:00Z","to":"2020-05-03T00:00:00Z","interval":"day",` +
+				`"links":[{"link_id":"` + link1ID + `","clicks":5,"visitors":4,` +
+				`"buckets":[{"start":"2020-05-01T00:00:00Z","clicks":4,"visitors":3},{"start":"2020-05-02T00:00:00Z","clicks":1,"visitors":1}],` +
+				`"sublinks":[{"sublink_id":"` + sublink1ID + `","clicks":3,"visitors":2,` +
+				`"buckets":[{"start":"2020-05-01T00:00:00Z","clicks":3,"visitors":2}]}]}],` +
+				`"referrers":[{"value":"instagram.com","clicks":4}],` +
+				`"devices":[{"value":"mobile","clicks":4},{"value":"desktop","clicks":1}]}`,
+		},
+		{
+			name:  "Single link by hour",
+			query: "?from=2020-05-01T00:00:00Z&to=2020-05-03T00:00:00Z&interval=hour&link_id=" + link1ID,
+			dbQuery: func() {
+				mock.ExpectQuery("FROM click_rollups WHERE user_id = \\$1 AND hour >= \\$2 AND hour < \\$3 AND link_id = \\$4\\)").
+					WithArgs(user1ID, from, to, link1ID, "hour").
+					WillReturnRows(sqlmock.NewRows([]string{"link_id", "sublink_id", "bucket", "sum"}))
+				mock.ExpectQuery("FROM visitor_sketches").WithArgs(user1ID, from, to, link1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"link_id", "sublink_id", "day", "sketch"}).
+						AddRow(link1ID, sublink1ID, day1, sketchOf(1, 2)))
+				mock.ExpectQuery("SELECT referrer").WithArgs(user1ID, from, to, link1ID, topReferrers).
+					WillReturnRows(sqlmock.NewRows([]string{"referrer", "sum"}))
+				mock.ExpectQuery("SELECT device").WithArgs(user1ID, from, to, link1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"device", "sum"}))
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `{"from":"2020-05-01T00:00:00Z","to":"2020-05-03T00:00:00Z","interval":"hour",` +
+				`"links":[{"link_id":"` + link1ID + `","clicks":0,"visitors":2,"buckets":[],` +
+				`"sublinks":[{"sublink_id":"` + sublink1ID + `","clicks":0,"visitors":2,"buckets":[]}]}],` +
+				`"referrers":[],"devices":[]}`,
+		},
+		{
+			name:       "Invalid interval",
+			query:      "?interval=month",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Too many buckets",
+			query:      "?from=2020-01-01T00:00:00Z&to=2020-05-01T00:00:00Z&interval=hour",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "From after to",
+			query:      "?from=2020-05-02T00:00:00Z&to=2020-05-01T00:00:00Z",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbQuery != nil {
+				tc.dbQuery()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/links/analytics"+tc.query, nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			recorder := httptest.NewRecorder()
+
+			IndexHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if tc.wantBody != "" {
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t); diff != "" {
+					t.Error(diff)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var errExpiryInPast = e.New("invalid_expiry", "expire_at must be in the future")
+
+// PostHandler creates a new API key for the authenticated user. The key is only returned once.
+type PostHandler handlers.Group
+
+func (h PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var p models.APIKeyPayload
+	err = json.Unmarshal(body, &p)
+	if err := e.CheckValid(err, p, h.Validator, validator.Locales(r.Header.Get("Accept-Language"))...); err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if p.ExpireAt != nil && !p.ExpireAt.After(time.Now()) {
+		e.WriteError(w, http.StatusBadRequest, errExpiryInPast)
+		return
+	}
+
+	key, prefix, err := tokens.NewAPIKey()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	id, idStr := models.GenerateUUIDPair()
+	k := models.APIKey{
+		ID:         idStr,
+		Name:       p.Name,
+		Key:        key,
+		Prefix:     prefix,
+		Scopes:     dedup(p.Scopes),
+		AllowedIPs: networks(p.AllowedIPs),
+		ExpireAt:   p.ExpireAt,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	scopes, _ := json.Marshal(k.Scopes)
+	allowedIPs, _ := json.Marshal(k.AllowedIPs)
+
+	_, err = h.DB.ExecContext(r.Context(), `
+		INSERT INTO api_keys (id, user_id, name, prefix, token_hash, scopes, allowed_ips, expire_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, id, middleware.CtxReqUserID(r.Context()), k.Name, k.Prefix, h.Hasher.Hash(key),
+		string(scopes), string(allowedIPs), k.ExpireAt, k.CreatedAt)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusCreated, k)
+}
+
+func dedup(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := []string{}
+	for _, s := range scopes {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+
+	return unique
+}
+
+// networks converts single addresses to networks, so that the allow-list only holds CIDRs
+func networks(addrs []string) []string {
+	cidrs := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if _, network, err := net.ParseCIDR(a); err == nil {
+			cidrs = append(cidrs, network.String())
+			continue
+		}
+
+		ip := net.ParseIP(a)
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		cidrs = append(cidrs, (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String())
+	}
+
+	return cidrs
+}
@@ -0,0 +1,115 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var (
+	user1ID = "fac90185-d243-46f5-8797-e57ac9c2c293"
+	key1ID  = "6a1f4e8c-2b3d-4c5e-9f70-8a9b0c1d2e3f"
+
+	hasher, _ = tokens.NewHasher([]byte("0123456789abcdef0123456789abcdef"))
+)
+
+func TestPostHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		payload    string
+		dbTx       func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:    "Valid key",
+			payload: `{"name":"ci","scopes":["links:read","links:read"],"allowed_ips":["203.0.113.7","198.51.100.0/24"]}`,
+			dbTx: func() {
+				mock.ExpectExec("INSERT INTO api_keys").
+					WithArgs(sqlmock.AnyArg(), user1ID, "ci", sqlmock.AnyArg(), sqlmock.AnyArg(),
+						`["links:read"]`, `["203.0.113.7/32","198.51.100.0/24"]`, nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantStatus: http.StatusCreated,
+			wantBody: `{"name":"ci","scopes":["links:read"],"allowed_ips":["203.0.113.7/32","198.51.100.0/24"],` +
+				`"expire_at":null}`,
+		},
+		{
+			name:       "Unknown scope",
+			payload:    `{"name":"ci","scopes":["links:delete"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Missing scopes",
+			payload:    `{"name":"ci","scopes":[]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid allowed address",
+			payload:    `{"name":"ci","scopes":["links:read"],"allowed_ips":["203.0.113"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Expiry in the past",
+			payload:    `{"name":"ci","scopes":["links:read"],"expire_at":"2020-01-01T00:00:00Z"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,` +
+				`"code":"invalid_expiry","detail":"expire_at must be in the future"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("POST", "https://linktree.com/api/keys", strings.NewReader(tc.payload))
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			recorder := httptest.NewRecorder()
+
+			PostHandler(handlers.Group{DB: db, Validator: validator.New(), Hasher: hasher}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				ignoreFields := []string{"id", "key", "prefix", "created_at"}
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t, ignoreFields...); diff != "" {
+					t.Error(diff)
+				}
+			}
+
+			if tc.wantStatus == http.StatusCreated {
+				var k models.APIKey
+				json.Unmarshal(recorder.Body.Bytes(), &k)
+
+				if !tokens.IsAPIKey(k.Key) || !strings.HasPrefix(k.Key, k.Prefix) {
+					t.Errorf("got key %q with prefix %q, want an api key starting with its prefix", k.Key, k.Prefix)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package apikeys
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+var errKeyNotFound = e.New("api_key_not_found", "api key not found")
+
+// DeleteHandler revokes an API key of the authenticated user.
+type DeleteHandler handlers.Group
+
+func (h DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	keyID := mux.Vars(r)["key_id"]
+	if _, err := uuid.Parse(keyID); err != nil {
+		e.WriteError(w, http.StatusNotFound, errKeyNotFound)
+		return
+	}
+
+	res, err := h.DB.ExecContext(r.Context(), `
+		DELETE FROM api_keys
+		 WHERE id = $1
+		   AND user_id = $2
+		`, keyID, middleware.CtxReqUserID(r.Context()))
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if n == 0 {
+		e.WriteError(w, http.StatusNotFound, errKeyNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
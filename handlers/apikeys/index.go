@@ -0,0 +1,72 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+// IndexHandler lists the API keys of the authenticated user, without their secret.
+type IndexHandler handlers.Group
+
+func (h IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := middleware.CtxReqUserID(ctx)
+
+	keys, err := getUserKeys(ctx, h.DB, userID)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, keys)
+}
+
+func getUserKeys(ctx context.Context, db *sql.DB, userID string) ([]models.APIKey, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id,
+		       name,
+		       prefix,
+		       scopes,
+		       allowed_ips,
+		       expire_at,
+		       created_at
+		  FROM api_keys
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var (
+			k                  models.APIKey
+			scopes, allowedIPs []byte
+		)
+
+		err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &allowedIPs, &k.ExpireAt, &k.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(scopes, &k.Scopes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(allowedIPs, &k.AllowedIPs); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
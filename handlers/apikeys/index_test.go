@@ -0,0 +1,91 @@
+package apikeys
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+func TestIndexHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	createdAt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "name", "prefix", "scopes", "allowed_ips", "expire_at", "created_at"}).
+		AddRow(key1ID, "ci", "lk_4tV0n2YQ", []byte(`["links:read"]`), []byte(`[]`), nil, createdAt)
+	mock.ExpectQuery("SELECT id").WithArgs(user1ID).WillReturnRows(rows)
+
+	req := httptest.NewRequest("GET", "https://linktree.com/api/keys", nil)
+	req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+	recorder := httptest.NewRecorder()
+
+	IndexHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	wantBody := `[{"id":"` + key1ID + `","name":"ci","prefix":"lk_4tV0n2YQ","scopes":["links:read"],` +
+		`"allowed_ips":[],"expire_at":null,"created_at":"2020-05-01T10:00:00Z"}]`
+	if diff := test.CompareJSON(recorder.Body.String(), wantBody, t); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDeleteHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		keyID      string
+		deleted    int64
+		wantStatus int
+	}{
+		{name: "Key deleted", keyID: key1ID, deleted: 1, wantStatus: http.StatusNoContent},
+		{name: "Key of another user", keyID: key1ID, deleted: 0, wantStatus: http.StatusNotFound},
+		{name: "Invalid key id", keyID: "not-a-key", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.keyID == key1ID {
+				mock.ExpectExec("DELETE FROM api_keys").WithArgs(key1ID, user1ID).
+					WillReturnResult(sqlmock.NewResult(0, tc.deleted))
+			}
+
+			req := httptest.NewRequest("DELETE", "https://linktree.com/api/keys/"+tc.keyID, nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			req = mux.SetURLVars(req, map[string]string{"key_id": tc.keyID})
+			recorder := httptest.NewRecorder()
+
+			DeleteHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package auditlog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+)
+
+const codeInvalidFilter = "invalid_filter"
+
+// filter selects the events of an account by actor, action, resource and time range
+type filter struct {
+	userID   string
+	actorID  string
+	action   string
+	resource string
+	from     time.Time
+	to       time.Time
+}
+
+// parseFilter reads the filter from the query parameters. Actions ending with a dot match
+// any action with that prefix, resources match any path below them.
+func parseFilter(r *http.Request, userID string) (filter, error) {
+	f := filter{
+		userID:   userID,
+		actorID:  r.FormValue("actor_id"),
+		action:   r.FormValue("action"),
+		resource: r.FormValue("resource"),
+	}
+
+	if f.actorID != "" {
+		if _, err := uuid.Parse(f.actorID); err != nil {
+			return f, e.New(codeInvalidFilter, "actor_id must be a uuid")
+		}
+	}
+
+	for _, t := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &f.from}, {"to", &f.to}} {
+		v := r.FormValue(t.name)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, e.New(codeInvalidFilter, t.name+" must be an RFC 3339 time")
+		}
+		*t.dst = parsed
+	}
+
+	return f, nil
+}
+
+// where returns the conditions of the filter and their arguments
+func (f filter) where() (string, []interface{}) {
+	conds := []string{"user_id = $1"}
+	args := []interface{}{f.userID}
+
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.actorID != "" {
+		add("actor_id = $%d", f.actorID)
+	}
+
+	switch {
+	case strings.HasSuffix(f.action, "."):
+		add("left(action, length($%[1]d)) = $%[1]d", f.action)
+	case f.action != "":
+		add("action = $%d", f.action)
+	}
+
+	if f.resource != "" {
+		add("(resource = $%[1]d OR left(resource, length($%[1]d) + 1) = $%[1]d || '/')", strings.TrimSuffix(f.resource, "/"))
+	}
+
+	if !f.from.IsZero() {
+		add("created_at >= $%d", f.from)
+	}
+
+	if !f.to.IsZero() {
+		add("created_at < $%d", f.to)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// queryEvents calls fn with every event matching the filter, most recent first.
+// All the events are returned if limit is zero.
+func queryEvents(ctx context.Context, db *sql.DB, f filter, limit int, fn func(audit.Event) error) error {
+	where, args := f.where()
+
+	stmt := `
+		SELECT id, user_id, actor_id, action, resource, status, remote_ip, metadata, created_at
+		  FROM audit_events
+		 WHERE ` + where + `
+		 ORDER BY created_at DESC, id`
+	if limit > 0 {
+		args = append(args, limit)
+		stmt += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			ev                                  audit.Event
+			userID, actorID, resource, remoteIP *string
+			status                              *int
+			metadata                            []byte
+		)
+
+		err := rows.Scan(&ev.ID, &userID, &actorID, &ev.Action, &resource, &status, &remoteIP, &metadata, &ev.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		ev.UserID, ev.ActorID, ev.Resource, ev.RemoteIP = value(userID), value(actorID), value(resource), value(remoteIP)
+		if status != nil {
+			ev.Status = *status
+		}
+		if metadata != nil {
+			if err := json.Unmarshal(metadata, &ev.Metadata); err != nil {
+				return err
+			}
+		}
+
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,65 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+// flushEvery is the number of events written between flushes of the response
+const flushEvery = 500
+
+// ExportHandler streams all the audit events of the profile matching the filter as JSON Lines.
+// Complete exports end with the handlers.StreamCompleteTrailer.
+type ExportHandler handlers.Group
+
+func (h ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleOwner) {
+		return
+	}
+
+	ctx := r.Context()
+	f, err := parseFilter(r, middleware.CtxProfileUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+
+	stream, err := handlers.NewStream(w)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	enc := json.NewEncoder(w)
+
+	var n int
+	err = queryEvents(ctx, h.DB, f, 0, func(ev audit.Event) error {
+		n++
+		if n%flushEvery == 0 {
+			if err := stream.Flush(); err != nil {
+				return err
+			}
+		}
+		return enc.Encode(ev)
+	})
+	if err == nil {
+		err = stream.Complete()
+	}
+
+	// Errors can only be reported before the first line, the export is truncated otherwise
+	if err != nil {
+		if n == 0 {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		log.Printf("Audit export interrupted after %d events: %v", n, err)
+	}
+}
@@ -0,0 +1,53 @@
+package auditlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+func TestExportHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM audit_events WHERE user_id = \\$1 AND action = \\$2 ORDER BY created_at DESC, id$").
+		WithArgs(user1ID, "token_issued").WillReturnRows(eventRows())
+
+	req := httptest.NewRequest("GET", "https://linktree.com/api/audit/export?action=token_issued", nil)
+	req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+	recorder := httptest.NewRecorder()
+
+	ExportHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	if got, want := recorder.Header().Get("Content-Type"), "application/x-ndjson"; got != want {
+		t.Errorf("got Content-Type %s, want %s", got, want)
+	}
+
+	wantBody := `{"id":"` + event2ID + `","user_id":"` + user1ID + `","actor_id":"` + actor1ID + `","action":"api.delete",` +
+		`"resource":"/api/links/1","status":204,"remote_ip":"10.0.0.1","created_at":"2020-05-02T10:00:00Z"}` + "\n" +
+		`{"id":"` + event1ID + `","user_id":"` + user1ID + `","action":"token_issued","remote_ip":"10.0.0.1",` +
+		`"metadata":{"session_id":"s1"},"created_at":"2020-05-02T09:00:00Z"}` + "\n"
+	if got := recorder.Body.String(); got != wantBody {
+		t.Errorf("got body %s, want %s", got, wantBody)
+	}
+
+	if got := recorder.Result().Trailer.Get(handlers.StreamCompleteTrailer); got != "true" {
+		t.Errorf("got %s trailer %s, want true", handlers.StreamCompleteTrailer, got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
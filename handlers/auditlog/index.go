@@ -0,0 +1,53 @@
+package auditlog
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// IndexHandler lists the audit events of the profile, most recent first. Only owners can read them.
+type IndexHandler handlers.Group
+
+func (h IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleOwner) {
+		return
+	}
+
+	ctx := r.Context()
+	f, err := parseFilter(r, middleware.CtxProfileUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := defaultLimit
+	if v := r.FormValue("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			e.WriteError(w, http.StatusBadRequest, e.New(codeInvalidFilter, "limit must be between 1 and 1000"))
+			return
+		}
+	}
+
+	events := []audit.Event{}
+	err = queryEvents(ctx, h.DB, f, limit, func(ev audit.Event) error {
+		events = append(events, ev)
+		return nil
+	})
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, events)
+}
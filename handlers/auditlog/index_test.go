@@ -0,0 +1,119 @@
+package auditlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+var (
+	user1ID  = "fac90185-d243-46f5-8797-e57ac9c2c293"
+	actor1ID = "a2f5f7a9-1c8e-4a4d-9b1f-3e0c6c0e3f11"
+	event1ID = "0d9c4b1e-5f2a-4c3b-8e7d-6a5b4c3d2e1f"
+	event2ID = "7b6a5c4d-3e2f-4a1b-9c8d-7e6f5a4b3c2d"
+)
+
+func eventRows() *sqlmock.Rows {
+	createdAt := time.Date(2020, 5, 2, 10, 0, 0, 0, time.UTC)
+	return sqlmock.NewRows([]string{"id", "user_id", "actor_id", "action", "resource", "status", "remote_ip",
+		"metadata", "created_at"}).
+		AddRow(event2ID, user1ID, actor1ID, "api.delete", "/api/links/1", 204, "10.0.0.1", nil, createdAt).
+		AddRow(event1ID, user1ID, nil, "token_issued", nil, nil, "10.0.0.1", []byte(`{"session_id":"s1"}`),
+			createdAt.Add(-time.Hour))
+}
+
+func TestIndexHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		query      string
+		role       string
+		dbQuery    func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "All events",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT (.+) FROM audit_events WHERE user_id = \\$1 ORDER BY (.+) LIMIT \\$2").
+					WithArgs(user1ID, defaultLimit).WillReturnRows(eventRows())
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `[{"id":"` + event2ID + `","user_id":"` + user1ID + `","actor_id":"` + actor1ID + `","action":"api.delete",` +
+				`"resource":"/api/links/1","status":204,"remote_ip":"10.0.0.1","created_at":"2020-05-02T10:00:00Z"},` +
+				`{"id":"` + event1ID + `","user_id":"` + user1ID + `","action":"token_issued","remote_ip":"10.0.0.1",` +
+				`"metadata":{"session_id":"s1"},"created_at":"2020-05-02T09:00:00Z"}]`,
+		},
+		{
+			name:  "Filtered events",
+			query: "?actor_id=" + actor1ID + "&action=api.&resource=/api/links/&from=2020-05-01T00:00:00Z&limit=10",
+			dbQuery: func() {
+				mock.ExpectQuery("WHERE user_id = \\$1 AND actor_id = \\$2 AND left\\(action, length\\(\\$3\\)\\) = \\$3 "+
+					"AND \\(resource = \\$4 OR (.+)\\) AND created_at >= \\$5 ORDER BY (.+) LIMIT \\$6").
+					WithArgs(user1ID, actor1ID, "api.", "/api/links", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), 10).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "actor_id", "action", "resource", "status",
+						"remote_ip", "metadata", "created_at"}))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `[]`,
+		},
+		{
+			name:       "Invalid time",
+			query:      "?from=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Limit out of range",
+			query:      "?limit=5000",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Editor of a shared profile",
+			role:       middleware.RoleEditor,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbQuery != nil {
+				tc.dbQuery()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/audit"+tc.query, nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			if tc.role != "" {
+				req = middleware.CtxSetProfile(req.Context(), req, user1ID, tc.role)
+			}
+			recorder := httptest.NewRecorder()
+
+			IndexHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if tc.wantBody != "" {
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t); diff != "" {
+					t.Error(diff)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
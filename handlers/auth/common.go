@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
+	"github.com/google/uuid"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	tokenType = "Bearer"
+)
+
+// issueTokens stores a new access and refresh token pair for the session within the transaction.
+// Only the keyed hash of the tokens is stored. The access token is a JWT whose jti is the stored
+// token id when keys are configured.
+func issueTokens(ctx context.Context, tx *sql.Tx, g handlers.Group, userID string, sessionID uuid.UUID) (*models.Tokens, error) {
+	now := time.Now()
+	accessID := uuid.New()
+	_, access := models.GenerateUUIDPair()
+	_, refresh := models.GenerateUUIDPair()
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO user_tokens (id, token_hash, user_id, session_id, expire_at)
+		VALUES ($1, $2, $3, $4, $5)
+		`, accessID, g.Hasher.Hash(access), userID, sessionID, now.Add(accessTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (id, token_hash, user_id, session_id, expire_at)
+		VALUES ($1, $2, $3, $4, $5)
+		`, uuid.New(), g.Hasher.Hash(refresh), userID, sessionID, now.Add(refreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	if g.Keys != nil {
+		access, err = g.Keys.Sign(tokens.Claims{
+			Subject:   userID,
+			ID:        accessID.String(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(accessTokenTTL).Unix(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.Tokens{
+		AccessToken:  access,
+		TokenType:    tokenType,
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		RefreshToken: refresh,
+		SessionID:    sessionID.String(),
+	}, nil
+}
+
+// revokeSessions revokes the session of the user with the given id, or all of them if sessionID
+// is nil, deleting their tokens. It returns the number of sessions revoked.
+func revokeSessions(ctx context.Context, db *sql.DB, userID string, sessionID *string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE sessions
+		   SET revoked_at = NOW()
+		 WHERE user_id = $1
+		   AND ($2::uuid IS NULL OR id = $2)
+		   AND revoked_at IS NULL
+		`, userID, sessionID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// Tokens issued outside a session are revoked along with all sessions
+	for _, stmt := range []string{
+		`DELETE FROM user_tokens WHERE user_id = $1 AND ($2::uuid IS NULL OR session_id = $2)`,
+		`DELETE FROM refresh_tokens WHERE user_id = $1 AND ($2::uuid IS NULL OR session_id = $2)`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, userID, sessionID); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	return n, tx.Commit()
+}
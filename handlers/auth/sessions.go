@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+var errSessionNotFound = e.New("session_not_found", "session not found")
+
+// SessionsHandler lists the active sessions of the authenticated user.
+type SessionsHandler handlers.Group
+
+func (h SessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := middleware.CtxReqUserID(ctx)
+
+	sessions, err := getUserSessions(ctx, h.DB, userID)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, sessions)
+}
+
+func getUserSessions(ctx context.Context, db *sql.DB, userID string) ([]models.Session, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.id,
+		       s.user_agent,
+		       s.created_at,
+		       s.last_refreshed_at
+		  FROM sessions s
+		 WHERE s.user_id = $1
+		   AND s.revoked_at IS NULL
+		   AND EXISTS (SELECT 1
+		                 FROM refresh_tokens rt
+		                WHERE rt.session_id = s.id
+		                  AND rt.used_at IS NULL
+		                  AND rt.expire_at > NOW())
+		 ORDER BY s.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.CreatedAt, &s.LastRefreshedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeHandler revokes the session in the path, or all sessions of the authenticated user.
+type RevokeHandler handlers.Group
+
+func (h RevokeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := middleware.CtxReqUserID(ctx)
+
+	var sessionID *string
+	if id, ok := mux.Vars(r)["session_id"]; ok {
+		if _, err := uuid.Parse(id); err != nil {
+			e.WriteError(w, http.StatusNotFound, errSessionNotFound)
+			return
+		}
+		sessionID = &id
+	}
+
+	n, err := revokeSessions(ctx, h.DB, userID, sessionID)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if sessionID != nil && n == 0 {
+		e.WriteError(w, http.StatusNotFound, errSessionNotFound)
+		return
+	}
+
+	revoked := "all"
+	if sessionID != nil {
+		revoked = *sessionID
+	}
+	h.Audit.Log(r, audit.Event{
+		UserID:   userID,
+		ActorID:  userID,
+		Action:   audit.ActionTokenRevoked,
+		Metadata: map[string]string{"session_id": revoked},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
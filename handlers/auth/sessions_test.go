@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+func TestSessionsHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	createdAt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "user_agent", "created_at", "last_refreshed_at"}).
+		AddRow(session1ID, "curl/7.64.1", createdAt, nil)
+	mock.ExpectQuery("SELECT s.id").WithArgs(user1ID).WillReturnRows(rows)
+
+	req := httptest.NewRequest("GET", "https://linktree.com/api/sessions", nil)
+	req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+	recorder := httptest.NewRecorder()
+
+	SessionsHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	wantBody := `[{"id":"` + session1ID + `","user_agent":"curl/7.64.1","created_at":"2020-05-01T10:00:00Z",` +
+		`"last_refreshed_at":null}]`
+	if diff := test.CompareJSON(recorder.Body.String(), wantBody, t); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRevokeHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	revoked := func(sessionID interface{}, n int64) func() {
+		return func() {
+			mock.ExpectBegin()
+			mock.ExpectExec("UPDATE sessions").WithArgs(user1ID, sessionID).WillReturnResult(sqlmock.NewResult(0, n))
+			mock.ExpectExec("DELETE FROM user_tokens").WithArgs(user1ID, sessionID).WillReturnResult(sqlmock.NewResult(0, n))
+			mock.ExpectExec("DELETE FROM refresh_tokens").WithArgs(user1ID, sessionID).WillReturnResult(sqlmock.NewResult(0, n))
+			mock.ExpectCommit()
+		}
+	}
+
+	var testCases = []struct {
+		name       string
+		vars       map[string]string
+		dbTx       func()
+		wantStatus int
+	}{
+		{
+			name:       "Revoke all sessions",
+			dbTx:       revoked(nil, 2),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "Revoke one session",
+			vars:       map[string]string{"session_id": session1ID},
+			dbTx:       revoked(session1ID, 1),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "Unknown session",
+			vars:       map[string]string{"session_id": session1ID},
+			dbTx:       revoked(session1ID, 0),
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid session id",
+			vars:       map[string]string{"session_id": "not-a-session"},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("DELETE", "https://linktree.com/api/sessions", nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			if tc.vars != nil {
+				req = mux.SetURLVars(req, tc.vars)
+			}
+			recorder := httptest.NewRecorder()
+
+			RevokeHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+	"github.com/google/uuid"
+)
+
+var (
+	errInvalidCredentials = e.New("invalid_credentials", "email or password is incorrect")
+	errInvalidRefresh     = e.New("invalid_refresh_token", "refresh token is invalid or expired")
+	errRefreshReused      = e.New("refresh_token_reused", "refresh token was already used")
+)
+
+// dummyHash is compared against when the email is unknown, so that response times
+// do not reveal which emails are registered
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("linktree-dummy-password"), bcrypt.DefaultCost)
+
+// TokenHandler authenticates a user with email and password and starts a new session.
+type TokenHandler handlers.Group
+
+func (h TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var p models.CredentialsPayload
+	err = json.Unmarshal(body, &p)
+	if err := e.CheckValid(err, p, h.Validator, validator.Locales(r.Header.Get("Accept-Language"))...); err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID, err := h.checkCredentials(ctx, p.Email, p.Password)
+	if err != nil {
+		switch err {
+		case errInvalidCredentials:
+			h.Audit.Log(r, audit.Event{UserID: userID, Action: audit.ActionLoginFailed, Status: http.StatusUnauthorized})
+			e.WriteError(w, http.StatusUnauthorized, err)
+		default:
+			e.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	t, err := h.startSession(ctx, userID, r.UserAgent())
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.Audit.Log(r, audit.Event{
+		UserID:   userID,
+		ActorID:  userID,
+		Action:   audit.ActionTokenIssued,
+		Metadata: map[string]string{"session_id": t.SessionID},
+	})
+	handlers.WriteResponse(w, http.StatusCreated, t)
+}
+
+// checkCredentials returns the ID of the user matching email and password.
+// The ID of the user matching email is returned along with errInvalidCredentials for a wrong password.
+func (h TokenHandler) checkCredentials(ctx context.Context, email, password string) (string, error) {
+	var userID string
+	var hash []byte
+
+	err := h.DB.QueryRowContext(ctx, `
+		SELECT user_id,
+		       password_hash
+		  FROM user_credentials
+		 WHERE email = lower($1)
+		`, email).Scan(&userID, &hash)
+
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return "", errInvalidCredentials
+	default:
+		return "", err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return userID, errInvalidCredentials
+	}
+
+	return userID, nil
+}
+
+func (h TokenHandler) startSession(ctx context.Context, userID, userAgent string) (*models.Tokens, error) {
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID := uuid.New()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, user_agent)
+		VALUES ($1, $2, $3)
+		`, sessionID, userID, userAgent)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	t, err := issueTokens(ctx, tx, handlers.Group(h), userID, sessionID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return t, tx.Commit()
+}
+
+// RefreshHandler exchanges a refresh token for a new token pair. Refresh tokens can be used once,
+// reusing one revokes its whole session as it is likely to have been stolen.
+type RefreshHandler handlers.Group
+
+func (h RefreshHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var p models.RefreshPayload
+	err = json.Unmarshal(body, &p)
+	if err := e.CheckValid(err, p, h.Validator, validator.Locales(r.Header.Get("Accept-Language"))...); err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	t, userID, err := h.rotate(r.Context(), p.RefreshToken)
+	if err != nil {
+		switch err {
+		case errInvalidRefresh:
+			e.WriteError(w, http.StatusUnauthorized, err)
+		case errRefreshReused:
+			h.Audit.Log(r, audit.Event{UserID: userID, Action: audit.ActionRefreshReused, Status: http.StatusUnauthorized})
+			e.WriteError(w, http.StatusUnauthorized, errInvalidRefresh)
+		default:
+			e.WriteError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	h.Audit.Log(r, audit.Event{
+		UserID:   userID,
+		ActorID:  userID,
+		Action:   audit.ActionTokenRefreshed,
+		Metadata: map[string]string{"session_id": t.SessionID},
+	})
+	handlers.WriteResponse(w, http.StatusOK, t)
+}
+
+// rotate returns a new token pair and its owner. errRefreshReused is returned, with the owner,
+// if the refresh token was already used.
+func (h RefreshHandler) rotate(ctx context.Context, refreshToken string) (*models.Tokens, string, error) {
+	id, err := uuid.Parse(refreshToken)
+	if err != nil {
+		return nil, "", errInvalidRefresh
+	}
+	refreshToken = id.String()
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var (
+		userID    string
+		sessionID uuid.UUID
+		hash      []byte
+	)
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE refresh_tokens rt
+		   SET used_at = NOW()
+		  FROM sessions s
+		 WHERE rt.token_hash = $1
+		   AND rt.used_at IS NULL
+		   AND rt.expire_at > NOW()
+		   AND s.id = rt.session_id
+		   AND s.revoked_at IS NULL
+		RETURNING rt.user_id, rt.session_id, rt.token_hash
+		`, h.Hasher.Hash(refreshToken)).Scan(&userID, &sessionID, &hash)
+
+	switch err {
+	case nil:
+		if !h.Hasher.Equal(refreshToken, hash) {
+			tx.Rollback()
+			return nil, "", errInvalidRefresh
+		}
+	case sql.ErrNoRows:
+		tx.Rollback()
+		userID, err := h.detectReuse(ctx, refreshToken)
+		return nil, userID, err
+	default:
+		tx.Rollback()
+		return nil, "", err
+	}
+
+	// Access tokens issued before the refresh are replaced by the new one
+	for _, stmt := range []string{
+		`DELETE FROM user_tokens WHERE session_id = $1`,
+		`UPDATE sessions SET last_refreshed_at = NOW() WHERE id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, sessionID); err != nil {
+			tx.Rollback()
+			return nil, "", err
+		}
+	}
+
+	t, err := issueTokens(ctx, tx, handlers.Group(h), userID, sessionID)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+
+	return t, userID, tx.Commit()
+}
+
+// detectReuse revokes the session of a refresh token which was already used, returning its owner
+func (h RefreshHandler) detectReuse(ctx context.Context, refreshToken string) (string, error) {
+	var userID, sessionID string
+
+	err := h.DB.QueryRowContext(ctx, `
+		SELECT user_id,
+		       session_id
+		  FROM refresh_tokens
+		 WHERE token_hash = $1
+		   AND used_at IS NOT NULL
+		`, h.Hasher.Hash(refreshToken)).Scan(&userID, &sessionID)
+
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		return "", errInvalidRefresh
+	default:
+		return "", err
+	}
+
+	if _, err := revokeSessions(ctx, h.DB, userID, &sessionID); err != nil {
+		return "", err
+	}
+
+	return userID, errRefreshReused
+}
@@ -0,0 +1,287 @@
+package auth
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var (
+	user1ID      = "fac90185-d243-46f5-8797-e57ac9c2c293"
+	session1ID   = "0b8cbfb1-5e3f-4d8c-a3a5-1f3c1b8f4a47"
+	refreshToken = "3f0f6b5e-8d1a-4f0e-9a8b-7c2d3e4f5a6b"
+
+	hasher, _   = tokens.NewHasher([]byte("0123456789abcdef0123456789abcdef"))
+	refreshHash = hasher.Hash(refreshToken)
+)
+
+// capture is a sqlmock argument matcher recording the hash stored for a token
+type capture struct {
+	hash []byte
+}
+
+func (c *capture) Match(v driver.Value) bool {
+	c.hash, _ = v.([]byte)
+	return c.hash != nil
+}
+
+func TestTokenHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := tokens.NewHMACKey("hs", []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := tokens.NewKeySet("hs", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialsFound := func() {
+		rows := sqlmock.NewRows([]string{"user_id", "password_hash"}).AddRow(user1ID, hash)
+		mock.ExpectQuery("SELECT user_id").WithArgs("alessio@linktr.ee").WillReturnRows(rows)
+	}
+
+	var storedAccess, storedRefresh capture
+	sessionStarted := func() {
+		credentialsFound()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO sessions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO user_tokens").
+			WithArgs(sqlmock.AnyArg(), &storedAccess, user1ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO refresh_tokens").
+			WithArgs(sqlmock.AnyArg(), &storedRefresh, user1ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+
+	var testCases = []struct {
+		name       string
+		payload    string
+		keys       *tokens.KeySet
+		dbTx       func()
+		wantStatus int
+		wantBody   string
+		wantJWT    bool
+		wantHashed bool
+	}{
+		{
+			name:       "Missing password",
+			payload:    `{"email":"alessio@linktr.ee"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Unknown email",
+			payload: `{"email":"unknown@linktr.ee","password":"correct horse"}`,
+			dbTx: func() {
+				mock.ExpectQuery("SELECT user_id").WillReturnRows(sqlmock.NewRows([]string{"user_id", "password_hash"}))
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody: `{"type":"about:blank","title":"Unauthorized","status":401,` +
+				`"code":"invalid_credentials","detail":"email or password is incorrect"}`,
+		},
+		{
+			name:       "Wrong password",
+			payload:    `{"email":"alessio@linktr.ee","password":"battery staple"}`,
+			dbTx:       credentialsFound,
+			wantStatus: http.StatusUnauthorized,
+			wantBody: `{"type":"about:blank","title":"Unauthorized","status":401,` +
+				`"code":"invalid_credentials","detail":"email or password is incorrect"}`,
+		},
+		{
+			name:       "Valid credentials",
+			payload:    `{"email":"alessio@linktr.ee","password":"correct horse"}`,
+			dbTx:       sessionStarted,
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"token_type":"Bearer","expires_in":900}`,
+			wantHashed: true,
+		},
+		{
+			name:       "Valid credentials with JWT keys",
+			payload:    `{"email":"alessio@linktr.ee","password":"correct horse"}`,
+			keys:       keys,
+			dbTx:       sessionStarted,
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"token_type":"Bearer","expires_in":900}`,
+			wantJWT:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("POST", "https://linktree.com/auth/token", strings.NewReader(tc.payload))
+			recorder := httptest.NewRecorder()
+
+			g := handlers.Group{DB: db, Validator: validator.New(), Keys: tc.keys, Hasher: hasher}
+			TokenHandler(g).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				ignoreFields := []string{"access_token", "refresh_token", "session_id"}
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t, ignoreFields...); diff != "" {
+					t.Error(diff)
+				}
+			}
+
+			var resp models.Tokens
+			json.Unmarshal(recorder.Body.Bytes(), &resp)
+
+			if tc.wantHashed {
+				if !hasher.Equal(resp.AccessToken, storedAccess.hash) || !hasher.Equal(resp.RefreshToken, storedRefresh.hash) {
+					t.Errorf("got stored hashes %x and %x, want the hashes of the issued tokens", storedAccess.hash, storedRefresh.hash)
+				}
+			}
+
+			if tc.wantJWT {
+				claims, err := keys.Verify(resp.AccessToken, time.Now())
+				if err != nil || claims.Subject != user1ID || claims.ID == "" {
+					t.Errorf("got claims %+v and error %v, want a valid token for %s", claims, err, user1ID)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	invalidRefresh := `{"type":"about:blank","title":"Unauthorized","status":401,` +
+		`"code":"invalid_refresh_token","detail":"refresh token is invalid or expired"}`
+
+	notRotated := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE refresh_tokens").WithArgs(refreshHash).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "session_id", "token_hash"}))
+		mock.ExpectRollback()
+	}
+
+	var testCases = []struct {
+		name       string
+		payload    string
+		dbTx       func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Missing refresh token",
+			payload:    `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Malformed refresh token",
+			payload:    `{"refresh_token":"not-a-token"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   invalidRefresh,
+		},
+		{
+			name:    "Valid refresh token",
+			payload: `{"refresh_token":"` + refreshToken + `"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("UPDATE refresh_tokens").WithArgs(refreshHash).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "session_id", "token_hash"}).AddRow(user1ID, session1ID, refreshHash))
+				mock.ExpectExec("DELETE FROM user_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE sessions").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO user_tokens").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO refresh_tokens").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"token_type":"Bearer","expires_in":900,"session_id":"` + session1ID + `"}`,
+		},
+		{
+			name:    "Unknown refresh token",
+			payload: `{"refresh_token":"` + refreshToken + `"}`,
+			dbTx: func() {
+				notRotated()
+				mock.ExpectQuery("SELECT user_id").WithArgs(refreshHash).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "session_id"}))
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   invalidRefresh,
+		},
+		{
+			name:    "Reused refresh token revokes the session",
+			payload: `{"refresh_token":"` + refreshToken + `"}`,
+			dbTx: func() {
+				notRotated()
+				mock.ExpectQuery("SELECT user_id").WithArgs(refreshHash).
+					WillReturnRows(sqlmock.NewRows([]string{"user_id", "session_id"}).AddRow(user1ID, session1ID))
+				mock.ExpectBegin()
+				mock.ExpectExec("UPDATE sessions").WithArgs(user1ID, session1ID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM user_tokens").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("DELETE FROM refresh_tokens").WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   invalidRefresh,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("POST", "https://linktree.com/auth/refresh", strings.NewReader(tc.payload))
+			recorder := httptest.NewRecorder()
+
+			RefreshHandler(handlers.Group{DB: db, Validator: validator.New(), Hasher: hasher}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				ignoreFields := []string{"access_token", "refresh_token"}
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t, ignoreFields...); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
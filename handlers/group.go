@@ -3,7 +3,14 @@ package handlers
 import (
 	"database/sql"
 
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
+	"github.com/alessio-palumbo/linktree-challenge/linkcache"
 	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/ratelimit"
+	"github.com/alessio-palumbo/linktree-challenge/resolver"
+	"github.com/alessio-palumbo/linktree-challenge/screening"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
 	"github.com/alessio-palumbo/linktree-challenge/validator"
 )
 
@@ -12,4 +19,24 @@ type Group struct {
 	Auth      middleware.Auth
 	DB        *sql.DB
 	Validator *validator.CustomValidator
+	Resolver  resolver.Resolver
+	Screener  screening.Screener
+	// Keys signs access tokens as JWTs if set, opaque tokens are issued otherwise
+	Keys *tokens.KeySet
+	// Hasher hashes issued tokens before they are stored
+	Hasher tokens.Hasher
+	// RateStore keeps the rate limit buckets, in memory if nil
+	RateStore ratelimit.Store
+	// RequireIfMatch rejects link updates and deletes without an If-Match header
+	RequireIfMatch bool
+	// LinksCache keeps the rendered links index of each user, nothing is cached if nil
+	LinksCache *linkcache.Cache
+	// Audit records authentication events, nothing is recorded if nil
+	Audit *audit.Logger
+	// Clicks records the clicks on redirected links, nothing is recorded if nil
+	Clicks *clicks.Recorder
+	// Visitors identifies the clients making clicks, they are not identified if nil
+	Visitors *clicks.Visitors
+	// PublicURL is the base url of the public profiles and redirects, without a trailing slash
+	PublicURL string
 }
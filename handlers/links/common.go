@@ -1,9 +1,27 @@
 package links
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
 	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var (
+	errLinkNotFound       = e.New("link_not_found", "link not found")
+	errIfMatchRequired    = e.New("if_match_required", "If-Match header with the link ETag is required")
+	errPreconditionFailed = e.New("precondition_failed", "link was modified since it was read")
+	errProfileNotFound    = e.New("profile_not_found", "profile not found")
 )
 
 // addSublink unmarshal the given metadata in the correct sublink model and append it to the Link object.
@@ -15,22 +33,24 @@ func addSublink(l *models.Link, subID string, metadata json.RawMessage) (interfa
 	// TODO this could be improved to reduce code duplication using reflection
 	switch l.Type {
 	case models.LinkMusic:
-		sb := models.Platform{ID: subID}
+		sb := models.Platform{}
 
 		err := json.Unmarshal(metadata, &sb)
 		if err != nil {
 			return nil, err
 		}
+		sb.ID = subID
 
 		l.SubLinks = append(l.SubLinks, sb)
 		return sb, nil
 	case models.LinkShows:
-		sb := models.Show{ID: subID}
+		sb := models.Show{}
 
 		err := json.Unmarshal(metadata, &sb)
 		if err != nil {
 			return nil, err
 		}
+		sb.ID = subID
 
 		l.SubLinks = append(l.SubLinks, sb)
 		return sb, nil
@@ -38,3 +58,124 @@ func addSublink(l *models.Link, subID string, metadata json.RawMessage) (interfa
 
 	return nil, nil
 }
+
+// sublinkIDs returns the ids of the sublinks of a link
+func sublinkIDs(l *models.Link) map[string]bool {
+	ids := make(map[string]bool, len(l.SubLinks))
+	for _, s := range l.SubLinks {
+		switch sb := s.(type) {
+		case models.Platform:
+			ids[sb.ID] = true
+		case models.Show:
+			ids[sb.ID] = true
+		}
+	}
+	return ids
+}
+
+// sublinkID returns the id given in the sublink metadata if it is one of the existing ids, so that
+// redirects to updated sublinks keep working, or a new one. Each existing id is given out once.
+func sublinkID(metadata json.RawMessage, existing map[string]bool) (uuid.UUID, string) {
+	var sb struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(metadata, &sb) == nil {
+		if subID, err := uuid.Parse(sb.ID); err == nil && existing[subID.String()] {
+			delete(existing, subID.String())
+			return subID, subID.String()
+		}
+	}
+
+	return models.GenerateUUIDPair()
+}
+
+// normalizeURL replaces a valid url with its canonical form
+func normalizeURL(u *string) {
+	if u == nil {
+		return
+	}
+
+	if n, err := validator.NormalizeURL(*u); err == nil {
+		*u = n
+	}
+}
+
+// normalizeSublinkURL returns the given sublink with its url in canonical form
+func normalizeSublinkURL(sl interface{}) interface{} {
+	switch sb := sl.(type) {
+	case models.Platform:
+		normalizeURL(&sb.URL)
+		return sb
+	case models.Show:
+		normalizeURL(&sb.URL)
+		return sb
+	}
+
+	return sl
+}
+
+// linkETag returns the entity tag of a link, which changes with every update
+func linkETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// indexETag returns the entity tag of a list of links, which changes when any of them does
+func indexETag(links []models.Link) string {
+	h := sha256.New()
+	for _, l := range links {
+		fmt.Fprintf(h, "%s:%d;", l.ID, l.Version)
+	}
+
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)[:16])
+}
+
+// matchesETag reports whether the If-Match header value matches the current version of a link
+func matchesETag(ifMatch string, version int) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == linkETag(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified reports whether the conditional headers of a GET request match the current
+// representation. If-Modified-Since is only evaluated without If-None-Match.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+
+	// Header dates have a resolution of one second
+	return !modified.Truncate(time.Second).After(ims)
+}
+
+// linkIDVar returns the link id in the path, writing a not found error if invalid
+func linkIDVar(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["link_id"])
+	if err != nil {
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return uuid.UUID{}, false
+	}
+
+	return id, true
+}
+
+// touchLinks records a write to the links of the user, moving the Last-Modified of the links index
+func touchLinks(ctx context.Context, db execer, userID string) error {
+	_, err := db.ExecContext(ctx, "UPDATE users SET links_updated_at = NOW() WHERE id = $1", userID)
+	return err
+}
@@ -3,6 +3,7 @@ package links
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -12,13 +13,24 @@ import (
 	"github.com/alessio-palumbo/linktree-challenge/handlers"
 	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
 	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/resolver"
+	"github.com/alessio-palumbo/linktree-challenge/screening"
 	"github.com/alessio-palumbo/linktree-challenge/validator"
+	"github.com/google/uuid"
+)
+
+var (
+	errResolveUnavailable = errors.New("link resolution is not available")
+	errResolveNotMusic    = errors.New("only music links with a url can be resolved")
 )
 
 // PostHandler list all the links for a given user.
 type PostHandler handlers.Group
 
 func (h PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleEditor) {
+		return
+	}
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -26,13 +38,46 @@ func (h PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	link, sublinks, err := prepareDbObject(body, h.Validator)
+	locales := validator.Locales(r.Header.Get("Accept-Language"))
+	link, sublinks, err := prepareDbObject(body, h.Validator, locales, nil)
 	if err != nil {
 		e.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	err = h.insertLinks(r.Context(), link, sublinks)
+	if r.FormValue("resolve") == "true" {
+		sublinks, err = h.resolveSublinks(r.Context(), link, sublinks)
+		if err != nil {
+			switch err {
+			case resolver.ErrNotFound, errResolveNotMusic:
+				e.WriteError(w, http.StatusBadRequest, err)
+			case errResolveUnavailable:
+				e.WriteError(w, http.StatusNotImplemented, err)
+			default:
+				e.WriteError(w, http.StatusBadGateway, err)
+			}
+			return
+		}
+	}
+
+	verdict, err := screenLink(r.Context(), h.Screener, link)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if verdict.Action == screening.ActionReject {
+		userID := middleware.CtxProfileUserID(r.Context())
+		if err := recordScreening(r.Context(), h.DB, userID, nil, verdict); err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		e.WriteError(w, http.StatusBadRequest, e.New(codeURLBlocked, verdict.Reason))
+		return
+	}
+
+	err = h.insertLinks(r.Context(), link, sublinks, verdict)
 	if err != nil {
 		e.WriteError(w, http.StatusInternalServerError, err)
 		return
@@ -41,11 +86,13 @@ func (h PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	handlers.WriteResponse(w, http.StatusCreated, *link)
 }
 
-func prepareDbObject(body []byte, validator *validator.CustomValidator) (*models.Link, []models.Sublink, error) {
+// prepareDbObject validates the link payload, returning the link and its sublinks to store.
+// Sublinks keep their ids if found in existing, the others are given new ids.
+func prepareDbObject(body []byte, cv *validator.CustomValidator, locales []string, existing map[string]bool) (*models.Link, []models.Sublink, error) {
 
 	var l models.LinkPayload
 	err := json.Unmarshal(body, &l)
-	if err := e.CheckValid(err, l, validator); err != nil {
+	if err := e.CheckValid(err, l, cv, locales...); err != nil {
 		return nil, nil, err
 	}
 
@@ -55,18 +102,25 @@ func prepareDbObject(body []byte, validator *validator.CustomValidator) (*models
 		Thumbnail: l.Thumbnail,
 		URL:       l.URL,
 	}
+	normalizeURL(link.URL)
 
 	if len(l.SubLinks) > 0 {
 		dbSubs := make([]models.Sublink, 0, len(l.SubLinks))
 
-		for _, s := range l.SubLinks {
-			subID, ID := models.GenerateUUIDPair()
+		for i, s := range l.SubLinks {
+			subID, ID := sublinkID(s, existing)
 
 			sl, err := addSublink(link, ID, s)
-			if err := e.CheckValid(err, sl, validator); err != nil {
+			if err := e.CheckValid(err, sl, cv, locales...); err != nil {
+				if vErrs, ok := err.(validator.Errors); ok {
+					return nil, nil, vErrs.WithPrefix(fmt.Sprintf("sublinks[%d]", i))
+				}
 				return nil, nil, err
 			}
 
+			sl = normalizeSublinkURL(sl)
+			link.SubLinks[i] = sl
+
 			data, err := json.Marshal(sl)
 			if err != nil {
 				return nil, nil, err
@@ -82,8 +136,54 @@ func prepareDbObject(body []byte, validator *validator.CustomValidator) (*models
 	return link, nil, nil
 }
 
-func (h *PostHandler) insertLinks(ctx context.Context, l *models.Link, sl []models.Sublink) error {
-	userID := middleware.CtxReqUserID(ctx)
+// resolveSublinks queries the resolver for the platforms the music track is available on
+// and adds the ones which are not already part of the link
+func (h *PostHandler) resolveSublinks(ctx context.Context, l *models.Link, sl []models.Sublink) ([]models.Sublink, error) {
+	if h.Resolver == nil {
+		return nil, errResolveUnavailable
+	}
+
+	if l.Type != models.LinkMusic || l.URL == nil {
+		return nil, errResolveNotMusic
+	}
+
+	platforms, err := h.Resolver.Resolve(ctx, *l.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]bool, len(l.SubLinks))
+	for _, s := range l.SubLinks {
+		if p, ok := s.(models.Platform); ok {
+			existing[strings.ToLower(p.Name)] = true
+		}
+	}
+
+	for _, p := range platforms {
+		// Skip platforms provided by the client and incomplete results
+		if existing[strings.ToLower(p.Name)] || h.Validator.Validate(p) != nil {
+			continue
+		}
+
+		subID, ID := models.GenerateUUIDPair()
+		p.ID = ID
+		normalizeURL(&p.URL)
+
+		data, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+
+		l.SubLinks = append(l.SubLinks, p)
+		sl = append(sl, models.Sublink{ID: subID, Metadata: data})
+	}
+
+	return sl, nil
+}
+
+// insertLinks stores the link with its sublinks, quarantining it if required by the screening verdict
+func (h *PostHandler) insertLinks(ctx context.Context, l *models.Link, sl []models.Sublink, v screening.Verdict) error {
+	userID := middleware.CtxProfileUserID(ctx)
 
 	tx, err := h.DB.Begin()
 	if err != nil {
@@ -91,19 +191,29 @@ func (h *PostHandler) insertLinks(ctx context.Context, l *models.Link, sl []mode
 	}
 
 	l.UUID, l.ID = models.GenerateUUIDPair()
+	l.Quarantined = v.Action == screening.ActionQuarantine
 
+	// New links are added after the others of the user
 	_, err = tx.ExecContext(ctx, `
-		INSERT INTO links (id, user_id, type, title, url, thumbnail)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		`, l.UUID, userID, l.Type, l.Title, l.URL, l.Thumbnail)
+		INSERT INTO links (id, user_id, type, title, url, thumbnail, quarantined, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7,
+		        (SELECT COALESCE(MAX(position), 0) + 1 FROM links WHERE user_id = $2))
+		`, l.UUID, userID, l.Type, l.Title, l.URL, l.Thumbnail, l.Quarantined)
 
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
+	if l.Quarantined {
+		if err := recordScreening(ctx, tx, userID, &l.UUID, v); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	if len(sl) > 0 {
-		stmt, values := generateBulkInsert(sl)
+		stmt, values := generateBulkInsert(l.UUID, sl)
 
 		_, err = tx.ExecContext(ctx, stmt, values...)
 		if err != nil {
@@ -112,20 +222,39 @@ func (h *PostHandler) insertLinks(ctx context.Context, l *models.Link, sl []mode
 		}
 	}
 
-	return tx.Commit()
+	l.Version = 1
+	if err := recordRevision(ctx, tx, revisionCreate, nil, l); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := touchLinks(ctx, tx, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	h.LinksCache.Invalidate(userID)
+	return nil
 }
 
-func generateBulkInsert(sl []models.Sublink) (string, []interface{}) {
+func generateBulkInsert(linkID uuid.UUID, sl []models.Sublink) (string, []interface{}) {
 
 	cols := 3
 	values := make([]interface{}, 0, len(sl)*cols)
-	stmt := strings.TrimRight(fmt.Sprintf(`
-		INSERT INTO sublinks (id, link_id, metadata) VALUES %s`,
-		strings.Repeat(" ($1, $2, $3),", len(sl))), ",")
+	placeholders := make([]string, 0, len(sl))
 
-	for _, s := range sl {
-		values = append(values, s.ID, s.UserID, s.Metadata)
+	for i, s := range sl {
+		n := i * cols
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d)", n+1, n+2, n+3))
+		values = append(values, s.ID, linkID, s.Metadata)
 	}
 
+	stmt := fmt.Sprintf(`
+		INSERT INTO sublinks (id, link_id, metadata) VALUES %s`, strings.Join(placeholders, ", "))
+
 	return stmt, values
 }
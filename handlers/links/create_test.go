@@ -1,6 +1,7 @@
 package links
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,11 +10,34 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
 	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/resolver"
+	"github.com/alessio-palumbo/linktree-challenge/screening"
 	"github.com/alessio-palumbo/linktree-challenge/test"
 	"github.com/alessio-palumbo/linktree-challenge/validator"
 )
 
+type stubResolver map[string][]models.Platform
+
+func (r stubResolver) Resolve(ctx context.Context, trackURL string) ([]models.Platform, error) {
+	if platforms, ok := r[trackURL]; ok {
+		return platforms, nil
+	}
+	return nil, resolver.ErrNotFound
+}
+
+type stubScreener map[string]screening.Action
+
+func (s stubScreener) Screen(ctx context.Context, urls []string) (screening.Verdict, error) {
+	for _, u := range urls {
+		if action, ok := s[u]; ok {
+			return screening.Verdict{Action: action, URL: u, MatchedURL: u, Reason: "url is blocklisted"}, nil
+		}
+	}
+	return screening.Verdict{Action: screening.ActionAllow}, nil
+}
+
 func TestPostHandler_ServeHTTP(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -26,13 +50,35 @@ func TestPostHandler_ServeHTTP(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT INTO links").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectExec("INSERT INTO sublinks").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO link_revisions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE users SET links_updated_at").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
 
 	}
 
+	txQuarantined := func() {
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO links").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO link_screenings").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO sublinks").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO link_revisions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE users SET links_updated_at").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+	}
+
+	rejectRecorded := func() {
+		mock.ExpectExec("INSERT INTO link_screenings").WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
 	var testCases = []struct {
 		name       string
 		userID     string
+		profileID  string
+		role       string
+		query      string
+		language   string
 		payload    string
 		wantStatus int
 		wantBody   string
@@ -43,21 +89,73 @@ func TestPostHandler_ServeHTTP(t *testing.T) {
 			userID:     user1ID,
 			payload:    `{"title":"first link"}`,
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"validation errors: Type is required"}`,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: Type is required",` +
+				`"errors":[{"field":"type","tag":"required","message":"Type is required"}]}`,
+		},
+		{
+			name:       "Malformed payload",
+			userID:     user1ID,
+			payload:    `{"type":`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"malformed_body",` +
+				`"detail":"unexpected end of JSON input"}`,
+		},
+		{
+			name:       "Invalid payload, localised errors",
+			userID:     user1ID,
+			language:   "it-IT,it;q=0.9,en;q=0.8",
+			payload:    `{"title":"first link"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: Type è obbligatorio",` +
+				`"errors":[{"field":"type","tag":"required","message":"Type è obbligatorio"}]}`,
 		},
 		{
 			name:       "Invalid payload, title is over 144 characters",
 			userID:     user1ID,
 			payload:    fmt.Sprintf(`{"type":"classic","title":"%s"}`, strings.Repeat("a", 145)),
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"validation errors: Title is longer than 144 characters"}`,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: Title is longer than 144 characters",` +
+				`"errors":[{"field":"title","tag":"max","param":"144","message":"Title is longer than 144 characters"}]}`,
 		},
 		{
 			name:       "Invalid type",
 			userID:     user1ID,
 			payload:    fmt.Sprintf(`{"type":"classic","title":"%s"}`, strings.Repeat("a", 145)),
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"validation errors: Title is longer than 144 characters"}`,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: Title is longer than 144 characters",` +
+				`"errors":[{"field":"title","tag":"max","param":"144","message":"Title is longer than 144 characters"}]}`,
+		},
+		{
+			name:       "Viewer of a shared profile",
+			userID:     user2ID,
+			profileID:  user1ID,
+			role:       middleware.RoleViewer,
+			payload:    `{"type":"classic","title":"first link"}`,
+			wantStatus: http.StatusForbidden,
+			wantBody: `{"type":"about:blank","title":"Forbidden","status":403,"code":"role_forbidden",` +
+				`"detail":"role on this profile does not allow the request"}`,
+		},
+		{
+			name:       "Editor of a shared profile",
+			userID:     user2ID,
+			profileID:  user1ID,
+			role:       middleware.RoleEditor,
+			payload:    `{"type":"classic","title":"first link"}`,
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"type":"classic","title":"first link","url":null}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO links").
+					WithArgs(sqlmock.AnyArg(), user1ID, "classic", "first link", nil, nil, false).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO link_revisions").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("UPDATE users SET links_updated_at").WithArgs(user1ID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
 		},
 		{
 			name:       "Music link with sublinks",
@@ -84,7 +182,9 @@ func TestPostHandler_ServeHTTP(t *testing.T) {
 			userID:     user1ID,
 			payload:    `{"type":"music","sublinks":[{"name":"Spotify"}]}`,
 			wantStatus: http.StatusBadRequest,
-			wantBody:   `{"error":"validation errors: URL is required"}`,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: URL is required",` +
+				`"errors":[{"field":"sublinks[0].url","tag":"required","message":"URL is required"}]}`,
 		},
 		{
 			name:   "Show link with valid sublink fields",
@@ -104,16 +204,141 @@ func TestPostHandler_ServeHTTP(t *testing.T) {
 			payload: `{"type":"shows","sublinks":[{"date":"Apr 31 2019","name":"Cats",` +
 				`"status": "coming-soon","url":"https://cats.com.au"}]}`,
 			wantStatus: http.StatusBadRequest,
-			wantBody: `{"error":"validation errors: Date is invalid, Venue is required ` +
-				`in absence of Location, Location is required in absence of Venue, Status is invalid"}`,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: Date must be a date in the format Jan 02 2006, Venue is required in absence of Location, ` +
+				`Location is required in absence of Venue, Status must be one of on-sale, sold-out, not-on-sale",` +
+				`"errors":[{"field":"sublinks[0].date","tag":"lkDate","message":"Date must be a date in the format Jan 02 2006"},` +
+				`{"field":"sublinks[0].venue","tag":"required_without","param":"Location",` +
+				`"message":"Venue is required in absence of Location"},` +
+				`{"field":"sublinks[0].location","tag":"required_without","param":"Venue",` +
+				`"message":"Location is required in absence of Venue"},` +
+				`{"field":"sublinks[0].status","tag":"oneof","param":"on-sale sold-out not-on-sale",` +
+				`"message":"Status must be one of on-sale, sold-out, not-on-sale"}]}`,
+		},
+		{
+			name:       "Classic link with javascript url",
+			userID:     user1ID,
+			payload:    `{"type":"classic","title":"Click me","url":"javascript:alert(1)"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: URL must be a valid url with scheme http, https",` +
+				`"errors":[{"field":"url","tag":"lkURL","param":"500","message":"URL must be a valid url with scheme http, https"}]}`,
+		},
+		{
+			name:       "Classic link with credentials in the url",
+			userID:     user1ID,
+			payload:    `{"type":"classic","title":"Click me","url":"https://paypal.com@evil.example/login"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: URL must not contain credentials",` +
+				`"errors":[{"field":"url","tag":"lkURL","param":"500","message":"URL must not contain credentials"}]}`,
+		},
+		{
+			name:       "Music link with invalid sublink url",
+			userID:     user1ID,
+			payload:    `{"type":"music","sublinks":[{"name":"Spotify","url":"ftp://music-link.com/all-of-me"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: URL must be a valid url with scheme http, https",` +
+				`"errors":[{"field":"sublinks[0].url","tag":"lkURL",` +
+				`"message":"URL must be a valid url with scheme http, https"}]}`,
+		},
+		{
+			name:   "Music link with too many sublinks",
+			userID: user1ID,
+			payload: `{"type":"music","sublinks":[` +
+				strings.Repeat(`{"name":"Spotify","url":"https://open.spotify.com/track/1"},`, 50) +
+				`{"name":"Spotify","url":"https://open.spotify.com/track/1"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"validation_failed",` +
+				`"detail":"validation errors: SubLinks is greater than 50",` +
+				`"errors":[{"field":"sublinks","tag":"max","param":"50","message":"SubLinks is greater than 50"}]}`,
+		},
+		{
+			name:   "Music link with normalised urls",
+			userID: user1ID,
+			payload: `{"type":"music","url":"HTTPS://Music-Link.com:443/all-of-me",` +
+				`"sublinks":[{"name":"Spotify","url":"http://OPEN.spotify.com:80/track/1"}]}`,
+			wantStatus: http.StatusCreated,
+			wantBody: `{"type":"music","title":null,"url":"https://music-link.com/all-of-me","sublinks":[{` +
+				`"name":"Spotify","url":"http://open.spotify.com/track/1"}]}`,
+			dbTx: txSucceeded,
+		},
+		{
+			name:       "Classic link with blocklisted url",
+			userID:     user1ID,
+			payload:    `{"type":"classic","title":"Login","url":"https://phishing.example/login"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"url_blocked",` +
+				`"detail":"url is blocklisted"}`,
+			dbTx: rejectRecorded,
+		},
+		{
+			name:   "Music link with quarantined sublink url",
+			userID: user1ID,
+			payload: `{"type":"music","sublinks":[{"name":"Spotify","url":"http://music-link.com/all-of-me"},` +
+				`{"name":"Gift","url":"https://short.example/gift"}]}`,
+			wantStatus: http.StatusCreated,
+			wantBody: `{"type":"music","title":null,"url":null,"quarantined":true,"sublinks":[` +
+				`{"name":"Spotify","url":"http://music-link.com/all-of-me"},` +
+				`{"name":"Gift","url":"https://short.example/gift"}]}`,
+			dbTx: txQuarantined,
+		},
+		{
+			name:   "Music link with resolved sublinks",
+			userID: user1ID,
+			query:  "?resolve=true",
+			payload: `{"type":"music","url":"https://open.spotify.com/track/all-of-me",` +
+				`"sublinks":[{"name":"spotify","url":"https://open.spotify.com/track/all-of-me"}]}`,
+			wantStatus: http.StatusCreated,
+			wantBody: `{"type":"music","title":null,"url":"https://open.spotify.com/track/all-of-me",` +
+				`"sublinks":[{"name":"spotify","url":"https://open.spotify.com/track/all-of-me"},` +
+				`{"name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-3"}]}`,
+			dbTx: txSucceeded,
+		},
+		{
+			name:       "Music link with unknown track",
+			userID:     user1ID,
+			query:      "?resolve=true",
+			payload:    `{"type":"music","url":"https://open.spotify.com/track/unknown"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"bad_request",` +
+				`"detail":"track url could not be resolved"}`,
+		},
+		{
+			name:       "Resolve classic link",
+			userID:     user1ID,
+			query:      "?resolve=true",
+			payload:    `{"type":"classic","url":"https://open.spotify.com/track/all-of-me"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"bad_request",` +
+				`"detail":"only music links with a url can be resolved"}`,
+		},
+	}
+
+	scr := stubScreener{
+		"https://phishing.example/login": screening.ActionReject,
+		"https://short.example/gift":     screening.ActionQuarantine,
+	}
+
+	res := stubResolver{
+		"https://open.spotify.com/track/all-of-me": []models.Platform{
+			{Name: "Spotify", URL: "https://open.spotify.com/track/all-of-me"},
+			{Name: "SoundCloud", URL: "https://soundcloud.com/johnlegend/all-of-me-3"},
+			{Name: "Deezer"},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 
-			req := httptest.NewRequest("POST", "https://linktree.com/api/links", strings.NewReader(tc.payload))
+			url := "https://linktree.com/api/links" + tc.query
+			req := httptest.NewRequest("POST", url, strings.NewReader(tc.payload))
+			req.Header.Set("Accept-Language", tc.language)
 			req = middleware.CtxSetUserID(req.Context(), req, tc.userID)
+			if tc.profileID != "" {
+				req = middleware.CtxSetProfile(req.Context(), req, tc.profileID, tc.role)
+			}
 
 			recorder := httptest.NewRecorder()
 
@@ -121,12 +346,17 @@ func TestPostHandler_ServeHTTP(t *testing.T) {
 				tc.dbTx()
 			}
 
-			PostHandler(handlers.Group{DB: db, Validator: validator.New()}).ServeHTTP(recorder, req)
+			g := handlers.Group{DB: db, Validator: validator.New(), Resolver: res, Screener: scr}
+			PostHandler(g).ServeHTTP(recorder, req)
 
 			if got := recorder.Code; got != tc.wantStatus {
 				t.Errorf("got status %d, want %d", got, tc.wantStatus)
 			}
 
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
 			if tc.wantBody != "" {
 				ignoreFields := []string{"id"}
 				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t, ignoreFields...); diff != "" {
@@ -0,0 +1,85 @@
+package links
+
+import (
+	"database/sql"
+	"net/http"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+// DeleteHandler moves a link and its sublinks to the trash if the If-Match header matches its current version.
+type DeleteHandler handlers.Group
+
+func (h DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleEditor) {
+		return
+	}
+
+	linkID, ok := linkIDVar(w, r)
+	if !ok {
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && h.RequireIfMatch {
+		e.WriteError(w, http.StatusPreconditionRequired, errIfMatchRequired)
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.CtxProfileUserID(ctx)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := getLink(ctx, tx, linkID, userID, true)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if ifMatch != "" && !matchesETag(ifMatch, current.Version) {
+		e.WriteError(w, http.StatusPreconditionFailed, errPreconditionFailed)
+		return
+	}
+
+	deleted := *current
+	err = tx.QueryRowContext(ctx, `
+		UPDATE links SET deleted_at = NOW(), version = version + 1, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING deleted_at, version
+		`, linkID).Scan(&deleted.DeletedAt, &deleted.Version)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordRevision(ctx, tx, revisionDelete, current, &deleted); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := touchLinks(ctx, tx, userID); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.LinksCache.Invalidate(userID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,101 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+func TestDeleteHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	linkLocked := func(rows *sqlmock.Rows) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT l.id(.+)FOR UPDATE OF l").WithArgs(link1ID, user1ID).WillReturnRows(rows)
+	}
+
+	var testCases = []struct {
+		name           string
+		ifMatch        string
+		requireIfMatch bool
+		dbTx           func()
+		wantStatus     int
+	}{
+		{
+			name:           "Missing If-Match",
+			requireIfMatch: true,
+			wantStatus:     http.StatusPreconditionRequired,
+		},
+		{
+			name:    "Stale If-Match",
+			ifMatch: `"2"`,
+			dbTx: func() {
+				linkLocked(musicLinkRows(3))
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "Unknown link",
+			ifMatch: `"3"`,
+			dbTx: func() {
+				linkLocked(sqlmock.NewRows(nil))
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Matching If-Match moves the link to the trash",
+			ifMatch:        `"3"`,
+			requireIfMatch: true,
+			dbTx: func() {
+				linkLocked(musicLinkRows(3))
+				mock.ExpectQuery("UPDATE links SET deleted_at = NOW()").WithArgs(link1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "version"}).AddRow(time.Now(), 4))
+				mock.ExpectExec("INSERT INTO link_revisions").
+					WithArgs(sqlmock.AnyArg(), link1ID, user1ID, user1ID, "delete", 4, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("UPDATE users SET links_updated_at").WithArgs(user1ID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("DELETE", "https://linktree.com/api/links/"+link1ID, nil)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			req = mux.SetURLVars(req, map[string]string{"link_id": link1ID})
+			recorder := httptest.NewRecorder()
+
+			DeleteHandler(handlers.Group{DB: db, RequireIfMatch: tc.requireIfMatch}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -1,16 +1,19 @@
 package links
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	e "github.com/alessio-palumbo/linktree-challenge/errors"
 	"github.com/alessio-palumbo/linktree-challenge/handlers"
 	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/linkcache"
 	"github.com/alessio-palumbo/linktree-challenge/middleware"
 	"github.com/google/uuid"
 )
@@ -27,19 +30,73 @@ var validOrderKeys = map[string]bool{
 type IndexHandler handlers.Group
 
 func (h IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
 	ctx := r.Context()
-	userID := middleware.CtxReqUserID(ctx)
+	userID := middleware.CtxProfileUserID(ctx)
+
+	// Only the order changes the links, so equivalent orders share an entry and other
+	// query parameters cannot add entries
+	orderBy := sortByClause(r.FormValue("sort_by"))
+	key := "sort_by=" + orderBy
+	entry, gen, ok := h.LinksCache.Get(userID, key)
+	if !ok {
+		links, err := getUserLinks(ctx, h.DB, userID, orderBy)
+		if err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
 
-	links, err := getUserLinks(ctx, h.DB, userID, r.FormValue("sort_by"))
-	if err != nil {
-		e.WriteError(w, http.StatusInternalServerError, err)
+		modified, err := linksModifiedAt(ctx, h.DB, userID)
+		if err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		var body bytes.Buffer
+		if err := json.NewEncoder(&body).Encode(links); err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		entry = linkcache.Entry{Body: body.Bytes(), ETag: indexETag(links), LastModified: modified}
+		h.LinksCache.Set(userID, key, gen, entry)
+	}
+
+	w.Header().Set("ETag", entry.ETag)
+	if !entry.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", entry.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, entry.ETag, entry.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	handlers.WriteResponse(w, http.StatusOK, links)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.Body)
+}
+
+// linksModifiedAt returns the time of the last write to the links of the user, zero if unknown
+func linksModifiedAt(ctx context.Context, db *sql.DB, userID string) (time.Time, error) {
+	var modified *time.Time
+	err := db.QueryRowContext(ctx, "SELECT links_updated_at FROM users WHERE id = $1", userID).Scan(&modified)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, err
+	}
+
+	if modified == nil {
+		return time.Time{}, nil
+	}
+
+	return *modified, nil
 }
 
-func getUserLinks(ctx context.Context, db *sql.DB, userID, sortBy string) ([]models.Link, error) {
+// getUserLinks returns the links of the user, ordered by the clause returned by sortByClause
+func getUserLinks(ctx context.Context, db *sql.DB, userID, orderBy string) ([]models.Link, error) {
 
 	stmt := `
 		SELECT l.id,
@@ -47,19 +104,22 @@ func getUserLinks(ctx context.Context, db *sql.DB, userID, sortBy string) ([]mod
 		       l.title,
 		       l.url,
 		       l.thumbnail,
+		       l.quarantined,
+		       l.version,
 		       l.created_at,
 
 		       sl.id,
 		       sl.metadata
 		  FROM links l
 		  LEFT JOIN sublinks sl ON sl.link_id = l.id
-		 WHERE l.user_id = $1
+		 WHERE l.user_id = $1 AND l.deleted_at IS NULL
 	`
 
-	// TODO add default ordering based on a orderID, to be controlled by a different api/table
-	if orderBy := sortByClause(sortBy); orderBy != "" {
-		stmt += fmt.Sprintf(" ORDER BY %s ", orderBy)
+	// Links are listed in position order unless sorted otherwise
+	if orderBy == "" {
+		orderBy = "l.position, l.created_at"
 	}
+	stmt += fmt.Sprintf(" ORDER BY %s ", orderBy)
 
 	rows, err := db.QueryContext(ctx, stmt, userID)
 	if err != nil {
@@ -76,7 +136,7 @@ func getUserLinks(ctx context.Context, db *sql.DB, userID, sortBy string) ([]mod
 		)
 
 		err := rows.Scan(&l.ID, &l.Type, &l.Title, &l.URL,
-			&l.Thumbnail, &l.CreatedAt, &subID, &metadata)
+			&l.Thumbnail, &l.Quarantined, &l.Version, &l.CreatedAt, &subID, &metadata)
 		if err != nil {
 			return nil, err
 		}
@@ -95,6 +155,7 @@ func getUserLinks(ctx context.Context, db *sql.DB, userID, sortBy string) ([]mod
 	return links, rows.Err()
 }
 
+// sortByClause returns the order by clause of the sort_by parameter, skipping unknown and repeated columns
 func sortByClause(sortBy string) string {
 	if sortBy == "" {
 		return sortBy
@@ -102,10 +163,12 @@ func sortByClause(sortBy string) string {
 
 	clauses := strings.Split(sortBy, ",")
 	var sortClauses []string
+	seen := make(map[string]bool, len(validOrderKeys))
 
 	for _, c := range clauses {
 		col := strings.Split(c, ":")
-		if _, valid := validOrderKeys[col[0]]; valid {
+		if _, valid := validOrderKeys[col[0]]; valid && !seen[col[0]] {
+			seen[col[0]] = true
 			order := defaultOrder
 			if len(col) > 1 && (col[1] == "asc" || col[1] == "desc") {
 				order = col[1]
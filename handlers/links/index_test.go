@@ -10,6 +10,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/linkcache"
 	"github.com/alessio-palumbo/linktree-challenge/middleware"
 )
 
@@ -77,6 +78,10 @@ func TestIndexHandler_ServeHTTP(t *testing.T) {
 				t.Errorf("got status %d, want %d", got, tc.wantStatus)
 			}
 
+			if recorder.Header().Get("ETag") == "" {
+				t.Errorf("got no ETag, want the ETag of the links")
+			}
+
 			if tc.wantBody != nil {
 				if got := recorder.Body.String(); got != *tc.wantBody {
 					t.Errorf("got body %s, want %s", got, *tc.wantBody)
@@ -86,6 +91,121 @@ func TestIndexHandler_ServeHTTP(t *testing.T) {
 	}
 }
 
+func TestIndexHandler_ConditionalGET(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	modified := time.Date(2020, 5, 1, 10, 0, 0, 500, time.UTC)
+	linksRead := func() {
+		rows := sqlmock.NewRows([]string{"l.id", "l.type", "l.title", "l.url", "l.thumbnail", "l.quarantined",
+			"l.version", "l.created_at", "sl.id", "sl.metadata"}).
+			AddRow("6e3060f3-4c99-41c7-a97b-a287399f3dd1", "classic", "First Link", "http://firstlink.com/1",
+				nil, false, 2, modified, nil, nil)
+		mock.ExpectQuery("SELECT l.id").WithArgs(user1ID).WillReturnRows(rows)
+		mock.ExpectQuery("SELECT links_updated_at").WithArgs(user1ID).
+			WillReturnRows(sqlmock.NewRows([]string{"links_updated_at"}).AddRow(modified))
+	}
+
+	cache := linkcache.New(time.Minute)
+	g := handlers.Group{DB: db, LinksCache: cache}
+
+	var etag string
+	var testCases = []struct {
+		name       string
+		query      string
+		header     func(h http.Header)
+		dbTx       func()
+		wantStatus int
+	}{
+		{
+			name:       "Cache miss",
+			header:     func(h http.Header) {},
+			dbTx:       linksRead,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Cached links",
+			header:     func(h http.Header) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Other query parameters share the entry",
+			query:      "?page=2&sort_by=unknown",
+			header:     func(h http.Header) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Matching If-None-Match",
+			header:     func(h http.Header) { h.Set("If-None-Match", `"other", `+etag) },
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name: "Stale If-None-Match takes precedence over If-Modified-Since",
+			header: func(h http.Header) {
+				h.Set("If-None-Match", `"other"`)
+				h.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Not modified since",
+			header:     func(h http.Header) { h.Set("If-Modified-Since", modified.Format(http.TimeFormat)) },
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "Modified since",
+			header:     func(h http.Header) { h.Set("If-Modified-Since", modified.Add(-time.Second).Format(http.TimeFormat)) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Links written",
+			header: func(h http.Header) {
+				h.Set("If-None-Match", etag)
+				cache.Invalidate(user1ID)
+			},
+			dbTx:       linksRead,
+			wantStatus: http.StatusNotModified,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/links"+tc.query, nil)
+			tc.header(req.Header)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			recorder := httptest.NewRecorder()
+
+			IndexHandler(g).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if got, want := recorder.Header().Get("Last-Modified"), "Fri, 01 May 2020 10:00:00 GMT"; got != want {
+				t.Errorf("got Last-Modified %s, want %s", got, want)
+			}
+
+			if etag == "" {
+				etag = recorder.Header().Get("ETag")
+			}
+			if got := recorder.Header().Get("ETag"); got != etag {
+				t.Errorf("got ETag %s, want %s", got, etag)
+			}
+		})
+	}
+}
+
 func populateMockDB(mock sqlmock.Sqlmock) {
 
 	fields := []string{
@@ -94,6 +214,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 		"l.title",
 		"l.url",
 		"l.thumbnail",
+		"l.quarantined",
+		"l.version",
 		"l.created_at",
 		"sl.id",
 		"sl.metadata",
@@ -107,6 +229,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"First Link",
 			"http://firstlink.com/1",
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-24 * time.Hour),
 			nil,
 			nil,
@@ -117,6 +241,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"Second Link",
 			"http://secondlink.com/2",
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-8 * time.Hour),
 			nil,
 			nil,
@@ -129,9 +255,11 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 	}
 
 	mock.ExpectQuery("SELECT l.id").WithArgs(user1ID).WillReturnRows(user1Rows)
+	mock.ExpectQuery("SELECT links_updated_at").WithArgs(user1ID).WillReturnRows(sqlmock.NewRows([]string{"links_updated_at"}).AddRow(nil))
 
 	// Set user2 mock DB. No data
 	mock.ExpectQuery("SELECT l.id").WithArgs(user2ID).WillReturnRows(sqlmock.NewRows(fields))
+	mock.ExpectQuery("SELECT links_updated_at").WithArgs(user2ID).WillReturnRows(sqlmock.NewRows([]string{"links_updated_at"}).AddRow(nil))
 
 	// Set user3 mock DB. All types of links
 	user3Data := [][]driver.Value{
@@ -141,6 +269,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"My Classic Link",
 			"http://myclassiclink.com/classic",
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-4 * time.Hour),
 			nil,
 			nil,
@@ -151,6 +281,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"My Shows Link",
 			nil,
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-8 * time.Hour),
 			"04e3c439-be86-4f19-ae1e-3f2bce732a41",
 			[]byte(`{"id":"0ba388db-0a52-4979-97a2-f3c648e355e3","date":"Apr 01 2019",
@@ -162,6 +294,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"My Shows Link",
 			nil,
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-8 * time.Hour),
 			"fb4ea9a5-8446-4201-a20b-818c944e3e09",
 			[]byte(`{"id":"bff093b1-1857-4b74-94f1-d75fe8b44d41","date":"Sep 03 2020",
@@ -173,6 +307,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"Music Link",
 			"http://music-link.com/all-of-me",
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-2 * time.Hour),
 			"fbd19ca9-8006-448f-a2f0-52817ad7e9e1",
 			[]byte(`{"name":"Spotify","url":"https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs"}`),
@@ -183,6 +319,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"Music Link",
 			"http://music-link.com/all-of-me",
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-2 * time.Hour),
 			"2cbc2043-d67e-45fc-a687-7e147def358f",
 			[]byte(`{"name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-3"}`),
@@ -195,6 +333,7 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 	}
 
 	mock.ExpectQuery("SELECT l.id").WithArgs(user3ID).WillReturnRows(user3Rows)
+	mock.ExpectQuery("SELECT links_updated_at").WithArgs(user3ID).WillReturnRows(sqlmock.NewRows([]string{"links_updated_at"}).AddRow(nil))
 
 	// Set user4 mock DB. Only classic links
 	user4Data := [][]driver.Value{
@@ -204,6 +343,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"First Link",
 			"http://firstlink.com/1",
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-24 * time.Hour),
 			nil,
 			nil,
@@ -214,6 +355,8 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 			"Second Link",
 			"http://secondlink.com/2",
 			nil,
+			false,
+			1,
 			time.Now().UTC().Add(-21 * time.Hour),
 			nil,
 			nil,
@@ -226,6 +369,7 @@ func populateMockDB(mock sqlmock.Sqlmock) {
 	}
 
 	mock.ExpectQuery("SELECT l.id").WithArgs(user4ID).WillReturnRows(user4Rows)
+	mock.ExpectQuery("SELECT links_updated_at").WithArgs(user4ID).WillReturnRows(sqlmock.NewRows([]string{"links_updated_at"}).AddRow(nil))
 }
 
 func Test_sortByClause(t *testing.T) {
@@ -273,6 +417,11 @@ func Test_sortByClause(t *testing.T) {
 			sortBy: "created_at:descending,order:asc",
 			want:   "created_at asc",
 		},
+		{
+			name:   "Repeated column",
+			sortBy: "title:desc,type,title:asc",
+			want:   "title desc, type asc",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
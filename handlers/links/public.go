@@ -0,0 +1,220 @@
+package links
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/linkcache"
+	"github.com/alessio-palumbo/linktree-challenge/pages"
+)
+
+const (
+	// publicCacheKey and publicPageCacheKey key the json and html profiles in the links cache,
+	// apart from the index queries
+	publicCacheKey     = "/public"
+	publicPageCacheKey = "/public.html"
+	// publicCacheControl lets clients and shared caches keep profiles for a minute, and serve
+	// them stale while revalidating for longer
+	publicCacheControl = "public, max-age=60, stale-while-revalidate=600"
+)
+
+// usernamePattern matches the usernames users can be given
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_.]{3,30}$`)
+
+// PublicHandler returns the profile of a user by username, with the links visitors can see.
+// Browsers get the profile page, other clients get it as json.
+// It needs no authentication, so quarantined links and links in the trash are left out.
+type PublicHandler handlers.Group
+
+func (h PublicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Usernames are stored in lowercase
+	username := strings.ToLower(mux.Vars(r)["username"])
+	if !usernamePattern.MatchString(username) {
+		e.WriteError(w, http.StatusNotFound, errProfileNotFound)
+		return
+	}
+
+	ctx := r.Context()
+	profile, userID, modified, err := getProfile(ctx, h.DB, username)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errProfileNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	key, contentType := publicCacheKey, "application/json"
+	if acceptsHTML(r) {
+		key, contentType = publicPageCacheKey, "text/html; charset=utf-8"
+	}
+
+	entry, gen, ok := h.LinksCache.Get(userID, key)
+	if !ok {
+		profile.Links, err = getVisibleLinks(ctx, h.DB, userID)
+		if err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		var body bytes.Buffer
+		if key == publicPageCacheKey {
+			err = pages.RenderProfile(&body, profile, h.PublicURL)
+		} else {
+			err = json.NewEncoder(&body).Encode(profile)
+		}
+		if err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Versions are not public, so the tag is derived from the body
+		entry = linkcache.Entry{Body: body.Bytes(), ETag: bodyETag(body.Bytes()), LastModified: modified}
+		h.LinksCache.Set(userID, key, gen, entry)
+	}
+
+	w.Header().Set("Cache-Control", publicCacheControl)
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("ETag", entry.ETag)
+	if !entry.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", entry.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, entry.ETag, entry.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.Body)
+}
+
+// acceptsHTML reports whether the request comes from a browser, which lists html in its Accept header
+func acceptsHTML(r *http.Request) bool {
+	for _, t := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt := strings.TrimSpace(strings.Split(t, ";")[0]); mt == "text/html" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getProfile returns the profile of the user with the given username without its links, the id of the
+// user and the time of the last write to their links or profile, zero if unknown
+func getProfile(ctx context.Context, db *sql.DB, username string) (models.Profile, string, time.Time, error) {
+	var (
+		p        = models.Profile{Username: username}
+		a        = &p.Appearance
+		userID   string
+		modified *time.Time
+		theme    *string
+	)
+
+	err := db.QueryRowContext(ctx, `
+		SELECT u.id,
+		       GREATEST(u.links_updated_at, p.updated_at),
+		       p.theme,
+		       p.background_color,
+		       p.text_color,
+		       p.button_color,
+		       p.button_text_color,
+		       p.font,
+		       p.display_name,
+		       p.bio,
+		       p.avatar
+		  FROM users u
+		  LEFT JOIN profiles p ON p.user_id = u.id
+		 WHERE u.username = $1
+		`, username).
+		Scan(&userID, &modified, &theme, &a.BackgroundColor, &a.TextColor, &a.ButtonColor, &a.ButtonTextColor, &a.Font,
+			&p.DisplayName, &p.Bio, &p.Avatar)
+	if err != nil {
+		return p, "", time.Time{}, err
+	}
+
+	a.Theme = models.DefaultTheme
+	if theme != nil {
+		a.Theme = *theme
+	}
+
+	if modified == nil {
+		return p, userID, time.Time{}, nil
+	}
+
+	return p, userID, *modified, nil
+}
+
+// getVisibleLinks returns the links of the user which are neither quarantined nor in the trash,
+// in position order
+func getVisibleLinks(ctx context.Context, db *sql.DB, userID string) ([]models.Link, error) {
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.id,
+		       l.type,
+		       l.title,
+		       l.url,
+		       l.thumbnail,
+
+		       sl.id,
+		       sl.metadata
+		  FROM links l
+		  LEFT JOIN sublinks sl ON sl.link_id = l.id
+		 WHERE l.user_id = $1 AND NOT l.quarantined AND l.deleted_at IS NULL
+		 ORDER BY l.position, l.created_at, l.id
+		`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Rows of the same link are adjacent, one for each of its sublinks
+	links := []models.Link{}
+	for rows.Next() {
+		var (
+			l        models.Link
+			subID    *uuid.UUID
+			metadata *json.RawMessage
+		)
+
+		err := rows.Scan(&l.ID, &l.Type, &l.Title, &l.URL, &l.Thumbnail, &subID, &metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		if n := len(links); n == 0 || links[n-1].ID != l.ID {
+			links = append(links, l)
+		}
+
+		if subID != nil && metadata != nil {
+			if _, err := addSublink(&links[len(links)-1], (*subID).String(), *metadata); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return links, rows.Err()
+}
+
+// bodyETag returns a strong ETag for a response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
@@ -0,0 +1,219 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/linkcache"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+var (
+	publicLinkColumns = []string{"l.id", "l.type", "l.title", "l.url", "l.thumbnail", "sl.id", "sl.metadata"}
+	profileColumns    = []string{"u.id", "modified", "p.theme", "p.background_color", "p.text_color", "p.button_color",
+		"p.button_text_color", "p.font", "p.display_name", "p.bio", "p.avatar"}
+)
+
+const profileNotFound = `{"type":"about:blank","title":"Not Found","status":404,"code":"profile_not_found",` +
+	`"detail":"profile not found"}`
+
+func TestPublicHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	modified := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	var testCases = []struct {
+		name       string
+		username   string
+		dbQuery    func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:     "Profile with links",
+			username: "Artist",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT u.id, .* WHERE u.username = \\$1").WithArgs("artist").
+					WillReturnRows(sqlmock.NewRows(profileColumns).
+						AddRow(user1ID, modified, "dark", nil, nil, "#ff0000", nil, nil, "The Artist", nil, nil))
+				mock.ExpectQuery("SELECT l.id, .* NOT l.quarantined AND l.deleted_at IS NULL ORDER BY l.position").
+					WithArgs(user1ID).
+					WillReturnRows(sqlmock.NewRows(publicLinkColumns).
+						AddRow(link1ID, "music", "First Song", nil, nil,
+							sublink1ID, []byte(`{"name":"Spotify","url":"https://open.spotify.com/track/1"}`)).
+						AddRow(link1ID, "music", "First Song", nil, nil,
+							sublink2ID, []byte(`{"name":"Tidal","url":"https://tidal.com/track/1"}`)).
+						AddRow(user2ID, "classic", "Website", "https://artist.com", nil, nil, nil))
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `{"username": "artist", "display_name": "The Artist", "appearance": {"theme": "dark", "button_color": "#ff0000"}, "links": [
+				{"id": "` + link1ID + `", "type": "music", "title": "First Song", "url": null, "sublinks": [
+					{"id": "` + sublink1ID + `", "name": "Spotify", "url": "https://open.spotify.com/track/1"},
+					{"id": "` + sublink2ID + `", "name": "Tidal", "url": "https://tidal.com/track/1"}]},
+				{"id": "` + user2ID + `", "type": "classic", "title": "Website", "url": "https://artist.com"}]}`,
+		},
+		{
+			name:     "Profile without links",
+			username: "artist",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT u.id").WithArgs("artist").WillReturnRows(sqlmock.NewRows(profileColumns).
+					AddRow(user1ID, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+				mock.ExpectQuery("SELECT l.id").WithArgs(user1ID).WillReturnRows(sqlmock.NewRows(publicLinkColumns))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"username": "artist", "appearance": {"theme": "light"}, "links": []}`,
+		},
+		{
+			name:     "Unknown username",
+			username: "nobody",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT u.id").WithArgs("nobody").WillReturnRows(sqlmock.NewRows(profileColumns))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   profileNotFound,
+		},
+		{
+			name:       "Invalid username",
+			username:   "no/body",
+			wantStatus: http.StatusNotFound,
+			wantBody:   profileNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbQuery != nil {
+				tc.dbQuery()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/public/"+tc.username, nil)
+			req = mux.SetURLVars(req, map[string]string{"username": tc.username})
+			recorder := httptest.NewRecorder()
+
+			PublicHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t); diff != "" {
+				t.Errorf("got unexpected body: %s", diff)
+			}
+
+			if tc.wantStatus == http.StatusOK {
+				if got := recorder.Header().Get("Cache-Control"); got != publicCacheControl {
+					t.Errorf("got Cache-Control %s, want %s", got, publicCacheControl)
+				}
+				if recorder.Header().Get("ETag") == "" {
+					t.Errorf("got no ETag, want the ETag of the profile")
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPublicHandler_ConditionalGET(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	userRead := func() {
+		mock.ExpectQuery("SELECT u.id").WithArgs("artist").WillReturnRows(sqlmock.NewRows(profileColumns).
+			AddRow(user1ID, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+	}
+
+	// The profile is rendered once, then served from the cache
+	g := handlers.Group{DB: db, LinksCache: linkcache.New(time.Minute)}
+	userRead()
+	mock.ExpectQuery("SELECT l.id").WithArgs(user1ID).WillReturnRows(sqlmock.NewRows(publicLinkColumns).
+		AddRow(link1ID, "classic", "Website", "https://artist.com", nil, nil, nil))
+	userRead()
+
+	serve := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "https://linktree.com/public/artist", nil)
+		req = mux.SetURLVars(req, map[string]string{"username": "artist"})
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		recorder := httptest.NewRecorder()
+		PublicHandler(g).ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := serve("")
+	if got := first.Code; got != http.StatusOK {
+		t.Fatalf("got status %d, want %d", got, http.StatusOK)
+	}
+
+	second := serve(first.Header().Get("ETag"))
+	if got := second.Code; got != http.StatusNotModified {
+		t.Errorf("got status %d, want %d", got, http.StatusNotModified)
+	}
+	if got := second.Body.Len(); got != 0 {
+		t.Errorf("got a body of %d bytes, want none", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPublicHandler_Page(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT u.id").WithArgs("artist").WillReturnRows(sqlmock.NewRows(profileColumns).
+		AddRow(user1ID, nil, "sunset", nil, nil, nil, nil, nil, "The Artist", "Songs & shows", nil))
+	mock.ExpectQuery("SELECT l.id").WithArgs(user1ID).WillReturnRows(sqlmock.NewRows(publicLinkColumns).
+		AddRow(link1ID, "classic", "Website", "https://artist.com", nil, nil, nil))
+
+	req := httptest.NewRequest("GET", "https://linktree.com/public/artist", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req = mux.SetURLVars(req, map[string]string{"username": "artist"})
+	recorder := httptest.NewRecorder()
+
+	PublicHandler(handlers.Group{DB: db, PublicURL: "https://linktr.ee"}).ServeHTTP(recorder, req)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	if got, want := recorder.Header().Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("got Content-Type %s, want %s", got, want)
+	}
+
+	for _, want := range []string{
+		`<a class="button" href="/r/` + link1ID + `">Website</a>`,
+		`<meta property="og:title" content="The Artist">`,
+		`<meta property="og:url" content="https://linktr.ee/public/artist">`,
+		`<p>Songs &amp; shows</p>`,
+	} {
+		if !strings.Contains(recorder.Body.String(), want) {
+			t.Errorf("got page %s, want %s", recorder.Body.String(), want)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
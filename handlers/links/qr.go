@@ -0,0 +1,165 @@
+package links
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image/color"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/qrcode"
+)
+
+const codeInvalidQR = "invalid_qr_options"
+
+// QRHandler returns a QR code of the tracked redirect of a link, so that scans are counted as clicks.
+// Links without a redirect, in the trash, quarantined or without a url, are not found.
+type QRHandler handlers.Group
+
+func (h QRHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	linkID, ok := linkIDVar(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	var exists bool
+	err := h.DB.QueryRowContext(ctx, `
+		SELECT true
+		  FROM links
+		 WHERE id = $1 AND user_id = $2 AND url IS NOT NULL AND NOT quarantined AND deleted_at IS NULL
+		`, linkID, middleware.CtxProfileUserID(ctx)).Scan(&exists)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The link may be deleted, so only the client keeps the code
+	writeQR(w, r, h.PublicURL+"/r/"+linkID.String(), "link-"+linkID.String(), "private, max-age=3600")
+}
+
+// PublicQRHandler returns a QR code of the public profile of a user by username
+type PublicQRHandler handlers.Group
+
+func (h PublicQRHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	username := strings.ToLower(mux.Vars(r)["username"])
+	if !usernamePattern.MatchString(username) {
+		e.WriteError(w, http.StatusNotFound, errProfileNotFound)
+		return
+	}
+
+	var exists bool
+	err := h.DB.QueryRowContext(r.Context(), "SELECT true FROM users WHERE username = $1", username).Scan(&exists)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errProfileNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Codes only change with the options, which are part of the url
+	writeQR(w, r, h.PublicURL+"/public/"+username, username, "public, max-age=86400")
+}
+
+// writeQR writes the QR code of text in the format of the route, PNG or SVG, drawn with the options
+// of the query parameters
+func writeQR(w http.ResponseWriter, r *http.Request, text, name, cacheControl string) {
+	o, err := qrOptions(r)
+	if err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	format := mux.Vars(r)["format"]
+
+	var body bytes.Buffer
+	switch format {
+	case "svg":
+		err = qrcode.WriteSVG(&body, text, o)
+	default:
+		format = "png"
+		err = qrcode.WritePNG(&body, text, o)
+	}
+	if err != nil {
+		e.WriteError(w, http.StatusBadRequest, e.New(codeInvalidQR, err.Error()))
+		return
+	}
+
+	contentType := "image/png"
+	if format == "svg" {
+		contentType = "image/svg+xml"
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-qr.%s"`, name, format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body.Bytes())
+}
+
+// qrOptions reads the size in pixels, margin in modules, error correction level and colours of
+// a code from the query parameters, with defaults for those not given
+func qrOptions(r *http.Request) (qrcode.Options, error) {
+	o := qrcode.DefaultOptions()
+
+	if v := r.FormValue("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < qrcode.MinSize || size > qrcode.MaxSize {
+			return o, e.New(codeInvalidQR, fmt.Sprintf("size must be between %d and %d", qrcode.MinSize, qrcode.MaxSize))
+		}
+		o.Size = size
+	}
+
+	if v := r.FormValue("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil || margin < 0 || margin > qrcode.MaxMargin {
+			return o, e.New(codeInvalidQR, fmt.Sprintf("margin must be between 0 and %d", qrcode.MaxMargin))
+		}
+		o.Margin = margin
+	}
+
+	if v := r.FormValue("level"); v != "" {
+		level, ok := qrcode.ParseLevel(v)
+		if !ok {
+			return o, e.New(codeInvalidQR, "level must be L, M, Q or H")
+		}
+		o.Level = level
+	}
+
+	for _, c := range []struct {
+		name string
+		dst  *color.RGBA
+	}{{"color", &o.Foreground}, {"background", &o.Background}} {
+		v := r.FormValue(c.name)
+		if v == "" {
+			continue
+		}
+
+		rgba, ok := qrcode.ParseColor(v)
+		if !ok {
+			return o, e.New(codeInvalidQR, c.name+" must be a hex colour, rrggbb")
+		}
+		*c.dst = rgba
+	}
+
+	return o, nil
+}
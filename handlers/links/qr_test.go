@@ -0,0 +1,181 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+func TestQRHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	linkFound := func() {
+		mock.ExpectQuery("SELECT true FROM links").WithArgs(link1ID, user1ID).
+			WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+	}
+
+	var testCases = []struct {
+		name            string
+		format          string
+		query           string
+		dbQuery         func()
+		wantStatus      int
+		wantContentType string
+	}{
+		{
+			name:            "PNG",
+			format:          "png",
+			query:           "size=128&margin=2&level=h&color=%23336699&background=ffffff",
+			dbQuery:         linkFound,
+			wantStatus:      http.StatusOK,
+			wantContentType: "image/png",
+		},
+		{
+			name:            "SVG",
+			format:          "svg",
+			dbQuery:         linkFound,
+			wantStatus:      http.StatusOK,
+			wantContentType: "image/svg+xml",
+		},
+		{
+			name:   "Link not found",
+			format: "png",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT true FROM links").WithArgs(link1ID, user1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"bool"}))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid size",
+			format:     "png",
+			query:      "size=10000",
+			dbQuery:    linkFound,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid level",
+			format:     "svg",
+			query:      "level=X",
+			dbQuery:    linkFound,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid colour",
+			format:     "svg",
+			query:      "color=red",
+			dbQuery:    linkFound,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Same colours",
+			format:     "svg",
+			query:      "color=000000&background=000000",
+			dbQuery:    linkFound,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.dbQuery()
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/links/"+link1ID+"/qr."+tc.format+"?"+tc.query, nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			req = mux.SetURLVars(req, map[string]string{"link_id": link1ID, "format": tc.format})
+			recorder := httptest.NewRecorder()
+
+			QRHandler(handlers.Group{DB: db, PublicURL: "https://linktr.ee"}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if tc.wantContentType != "" {
+				if got := recorder.Header().Get("Content-Type"); got != tc.wantContentType {
+					t.Errorf("got Content-Type %s, want %s", got, tc.wantContentType)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPublicQRHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		username   string
+		dbQuery    func()
+		wantStatus int
+	}{
+		{
+			name:     "Profile",
+			username: "Artist",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT true FROM users WHERE username = \\$1").WithArgs("artist").
+					WillReturnRows(sqlmock.NewRows([]string{"bool"}).AddRow(true))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:     "Unknown username",
+			username: "nobody",
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT true FROM users").WithArgs("nobody").WillReturnRows(sqlmock.NewRows([]string{"bool"}))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid username",
+			username:   "no/body",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbQuery != nil {
+				tc.dbQuery()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/public/"+tc.username+"/qr.svg", nil)
+			req = mux.SetURLVars(req, map[string]string{"username": tc.username, "format": "svg"})
+			recorder := httptest.NewRecorder()
+
+			PublicQRHandler(handlers.Group{DB: db, PublicURL: "https://linktr.ee"}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if tc.wantStatus == http.StatusOK {
+				if got, want := recorder.Header().Get("Cache-Control"), "public, max-age=86400"; got != want {
+					t.Errorf("got Cache-Control %s, want %s", got, want)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+)
+
+// RedirectHandler records a click on a link, or on one of its sublinks, and redirects to its url.
+// It is public, so links in the trash, quarantined or without a url are not found.
+type RedirectHandler handlers.Group
+
+func (h RedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	linkID, ok := linkIDVar(w, r)
+	if !ok {
+		return
+	}
+
+	var sublinkID *uuid.UUID
+	if v, ok := mux.Vars(r)["sublink_id"]; ok {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+			return
+		}
+		sublinkID = &id
+	}
+
+	target, userID, err := redirectTarget(r.Context(), h.DB, linkID, sublinkID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Clicks are still counted if the visitor cannot be identified
+	visitor, err := h.Visitors.ID(r.Context(), audit.RemoteIP(r), r.UserAgent())
+	if err != nil {
+		log.Printf("Failed to identify visitor: %v", err)
+	}
+
+	click := clicks.Click{
+		LinkID:    linkID.String(),
+		UserID:    userID,
+		Visitor:   visitor,
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+	if sublinkID != nil {
+		click.SublinkID = sublinkID.String()
+	}
+	h.Clicks.Record(click)
+
+	// Every click must reach the server to be counted
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// redirectTarget returns the url of the link, or of its sublink if set, and the owner of the link.
+// It returns sql.ErrNoRows if there is no url to redirect to.
+func redirectTarget(ctx context.Context, db *sql.DB, linkID uuid.UUID, sublinkID *uuid.UUID) (string, string, error) {
+	var (
+		target *string
+		userID string
+		err    error
+	)
+
+	if sublinkID == nil {
+		err = db.QueryRowContext(ctx, `
+			SELECT url, user_id
+			  FROM links
+			 WHERE id = $1 AND NOT quarantined AND deleted_at IS NULL
+			`, linkID).Scan(&target, &userID)
+	} else {
+		err = db.QueryRowContext(ctx, `
+			SELECT sl.metadata->>'url', l.user_id
+			  FROM sublinks sl
+			  JOIN links l ON l.id = sl.link_id
+			 WHERE sl.id = $1 AND l.id = $2 AND NOT l.quarantined AND l.deleted_at IS NULL
+			`, sublinkID, linkID).Scan(&target, &userID)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if target == nil || *target == "" {
+		return "", "", sql.ErrNoRows
+	}
+
+	return *target, userID, nil
+}
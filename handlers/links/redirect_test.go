@@ -0,0 +1,114 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+)
+
+func TestRedirectHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	iPhone := "Mozilla/5.0 (iPhone; CPU iPhone OS 13_4 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+
+	var testCases = []struct {
+		name         string
+		vars         map[string]string
+		dbQuery      func()
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name: "Link",
+			vars: map[string]string{"link_id": link1ID},
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT url, user_id FROM links").WithArgs(link1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"url", "user_id"}).AddRow("http://firstlink.com/1", user1ID))
+				mock.ExpectExec("INSERT INTO click_events").
+					WithArgs(link1ID, nil, user1ID, nil, "https://instagram.com/", iPhone, clicks.DeviceMobile, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantStatus:   http.StatusFound,
+			wantLocation: "http://firstlink.com/1",
+		},
+		{
+			name: "Sublink",
+			vars: map[string]string{"link_id": link1ID, "sublink_id": sublink1ID},
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT sl.metadata->>'url', l.user_id FROM sublinks sl").WithArgs(sublink1ID, link1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"url", "user_id"}).
+						AddRow("https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs", user1ID))
+				mock.ExpectExec("INSERT INTO click_events").
+					WithArgs(link1ID, sublink1ID, user1ID, nil, "https://instagram.com/", iPhone, clicks.DeviceMobile, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs",
+		},
+		{
+			name: "Link without url",
+			vars: map[string]string{"link_id": link1ID},
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT url, user_id FROM links").WithArgs(link1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"url", "user_id"}).AddRow(nil, user1ID))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Link not found",
+			vars: map[string]string{"link_id": link1ID},
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT url, user_id FROM links").WithArgs(link1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"url", "user_id"}))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid sublink id",
+			vars:       map[string]string{"link_id": link1ID, "sublink_id": "not-a-sublink"},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbQuery != nil {
+				tc.dbQuery()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/r/"+tc.vars["link_id"], nil)
+			req.Header.Set("Referer", "https://instagram.com/")
+			req.Header.Set("User-Agent", iPhone)
+			req = mux.SetURLVars(req, tc.vars)
+			recorder := httptest.NewRecorder()
+
+			// Clicks are written as soon as they are recorded, and before Close returns
+			rec := clicks.NewRecorder(db, 1, time.Hour)
+			RedirectHandler(handlers.Group{DB: db, Clicks: rec}).ServeHTTP(recorder, req)
+			rec.Close()
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Header().Get("Location"); got != tc.wantLocation {
+				t.Errorf("got Location %s, want %s", got, tc.wantLocation)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
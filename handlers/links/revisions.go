@@ -0,0 +1,148 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+// The changes recorded as link revisions
+const (
+	revisionCreate  = "create"
+	revisionUpdate  = "update"
+	revisionDelete  = "delete"
+	revisionRestore = "restore"
+	revisionRevert  = "revert"
+)
+
+var errRevisionNotFound = e.New("revision_not_found", "revision not found")
+
+// RevisionsHandler lists the revisions of a link, most recent first.
+type RevisionsHandler handlers.Group
+
+func (h RevisionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	linkID, ok := linkIDVar(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.CtxProfileUserID(ctx)
+
+	// Links in the trash keep their revisions
+	var exists bool
+	err := h.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM links WHERE id = $1 AND user_id = $2)
+		`, linkID, userID).Scan(&exists)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !exists {
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	}
+
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT id, actor_id, action, version, before, after, created_at
+		  FROM link_revisions
+		 WHERE link_id = $1
+		 ORDER BY created_at DESC, version DESC
+		`, linkID)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	revisions := []models.LinkRevision{}
+	for rows.Next() {
+		var (
+			rev    models.LinkRevision
+			before *json.RawMessage
+		)
+
+		if err := rows.Scan(&rev.ID, &rev.ActorID, &rev.Action, &rev.Version, &before, &rev.After, &rev.CreatedAt); err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if before != nil {
+			rev.Before = *before
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, revisions)
+}
+
+// RevisionRestoreHandler reverts a link and its sublinks to their state after the given revision.
+// The reverted link is validated and screened as any update.
+type RevisionRestoreHandler handlers.Group
+
+func (h RevisionRestoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	revisionID, err := uuid.Parse(mux.Vars(r)["revision_id"])
+	if err != nil {
+		e.WriteError(w, http.StatusNotFound, errRevisionNotFound)
+		return
+	}
+
+	update(handlers.Group(h), w, r, revisionRevert, func(ctx context.Context, db *sql.DB, current *models.Link) ([]byte, error) {
+		// The snapshot of a link is a valid payload, its ids and state fields are ignored
+		var after []byte
+		err := db.QueryRowContext(ctx, `
+			SELECT after FROM link_revisions WHERE id = $1 AND link_id = $2
+			`, revisionID, current.ID).Scan(&after)
+		if err == sql.ErrNoRows {
+			return nil, errRevisionNotFound
+		}
+
+		return after, err
+	})
+}
+
+// recordRevision stores the change to a link by the authenticated user.
+// before is nil for created links.
+func recordRevision(ctx context.Context, db execer, action string, before, after *models.Link) error {
+	id, _ := models.GenerateUUIDPair()
+
+	var beforeData []byte
+	if before != nil {
+		data, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		beforeData = data
+	}
+
+	afterData, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO link_revisions (id, link_id, user_id, actor_id, action, version, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, id, after.UUID, middleware.CtxProfileUserID(ctx), middleware.CtxReqUserID(ctx),
+		action, after.Version, beforeData, afterData)
+
+	return err
+}
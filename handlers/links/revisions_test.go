@@ -0,0 +1,192 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var revision1ID = "d1b7c3b0-7a47-4c4a-9a8e-2d5f0c6e9b11"
+
+func TestRevisionsHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	createdAt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	linkExists := func(exists bool) {
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(link1ID, user1ID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+	}
+
+	var testCases = []struct {
+		name       string
+		dbTx       func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "Link with revisions",
+			dbTx: func() {
+				linkExists(true)
+				rows := sqlmock.NewRows([]string{"id", "actor_id", "action", "version", "before", "after", "created_at"}).
+					AddRow(revision1ID, user2ID, "update", 2,
+						[]byte(`{"id":"`+link1ID+`","type":"classic","title":"First","url":null}`),
+						[]byte(`{"id":"`+link1ID+`","type":"classic","title":"Second","url":null}`), createdAt.Add(time.Hour)).
+					AddRow("0f5b1f9e-3c2d-4b8a-a1e7-6c9d2f4e8b20", user1ID, "create", 1, nil,
+						[]byte(`{"id":"`+link1ID+`","type":"classic","title":"First","url":null}`), createdAt)
+				mock.ExpectQuery("SELECT id, actor_id").WithArgs(link1ID).WillReturnRows(rows)
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `[{"id":"` + revision1ID + `","actor_id":"` + user2ID + `","action":"update","version":2,` +
+				`"before":{"id":"` + link1ID + `","type":"classic","title":"First","url":null},` +
+				`"after":{"id":"` + link1ID + `","type":"classic","title":"Second","url":null},"created_at":"2020-05-01T11:00:00Z"},` +
+				`{"id":"0f5b1f9e-3c2d-4b8a-a1e7-6c9d2f4e8b20","actor_id":"` + user1ID + `","action":"create","version":1,"before":null,` +
+				`"after":{"id":"` + link1ID + `","type":"classic","title":"First","url":null},"created_at":"2020-05-01T10:00:00Z"}]`,
+		},
+		{
+			name:       "Unknown link",
+			dbTx:       func() { linkExists(false) },
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.dbTx()
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/links/"+link1ID+"/revisions", nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			req = mux.SetURLVars(req, map[string]string{"link_id": link1ID})
+			recorder := httptest.NewRecorder()
+
+			RevisionsHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
+
+func TestRevisionRestoreHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	revisionFound := func(after string) {
+		mock.ExpectQuery("SELECT l.id").WithArgs(link1ID, user1ID).WillReturnRows(musicLinkRows(3))
+		rows := sqlmock.NewRows([]string{"after"})
+		if after != "" {
+			rows.AddRow([]byte(after))
+		}
+		mock.ExpectQuery("SELECT after FROM link_revisions").WithArgs(revision1ID, link1ID).WillReturnRows(rows)
+	}
+
+	var testCases = []struct {
+		name       string
+		revisionID string
+		dbTx       func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Revert to revision",
+			revisionID: revision1ID,
+			dbTx: func() {
+				revisionFound(`{"id":"` + link1ID + `","type":"classic","title":"First","url":"https://firstlink.com",` +
+					`"deleted_at":"2020-05-02T10:00:00Z"}`)
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT version FROM links (.+) FOR UPDATE").WithArgs(link1ID, user1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+				mock.ExpectQuery("UPDATE links").
+					WillReturnRows(sqlmock.NewRows([]string{"quarantined", "version", "updated_at"}).AddRow(false, 4, time.Now()))
+				mock.ExpectExec(`DELETE FROM sublinks WHERE link_id = \$1$`).WithArgs(link1ID).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec("UPDATE users SET links_updated_at").WithArgs(user1ID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO link_revisions").
+					WithArgs(sqlmock.AnyArg(), link1ID, user1ID, user1ID, "revert", 4, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":"` + link1ID + `","type":"classic","title":"First","url":"https://firstlink.com"}`,
+		},
+		{
+			name:       "Revision no longer valid",
+			revisionID: revision1ID,
+			dbTx: func() {
+				revisionFound(`{"id":"` + link1ID + `","type":"classic","url":"javascript:alert(1)"}`)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown revision",
+			revisionID: revision1ID,
+			dbTx: func() {
+				revisionFound("")
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"revision_not_found",` +
+				`"detail":"revision not found"}`,
+		},
+		{
+			name:       "Invalid revision id",
+			revisionID: "not-a-revision",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			url := "https://linktree.com/api/links/" + link1ID + "/revisions/" + tc.revisionID + "/restore"
+			req := httptest.NewRequest("POST", url, nil)
+			req.Header.Set("If-Match", `"3"`)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			req = mux.SetURLVars(req, map[string]string{"link_id": link1ID, "revision_id": tc.revisionID})
+			recorder := httptest.NewRecorder()
+
+			RevisionRestoreHandler(handlers.Group{DB: db, Validator: validator.New()}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/screening"
+	"github.com/google/uuid"
+)
+
+const codeURLBlocked = "url_blocked"
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// screenLink checks the url of the link and of its sublinks against the moderation rules.
+// Links are allowed if no screener is configured.
+func screenLink(ctx context.Context, s screening.Screener, l *models.Link) (screening.Verdict, error) {
+	if s == nil {
+		return screening.Verdict{Action: screening.ActionAllow}, nil
+	}
+
+	var urls []string
+	if l.URL != nil {
+		urls = append(urls, *l.URL)
+	}
+
+	for _, sl := range l.SubLinks {
+		switch sb := sl.(type) {
+		case models.Platform:
+			urls = append(urls, sb.URL)
+		case models.Show:
+			urls = append(urls, sb.URL)
+		}
+	}
+
+	return s.Screen(ctx, urls)
+}
+
+// recordScreening stores the verdict for moderators to review. linkID is nil for rejected new links.
+func recordScreening(ctx context.Context, db execer, userID string, linkID *uuid.UUID, v screening.Verdict) error {
+	id, _ := models.GenerateUUIDPair()
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO link_screenings (id, link_id, user_id, url, matched_url, action, rule, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, id, linkID, userID, v.URL, v.MatchedURL, v.Action, v.Rule, v.Reason)
+
+	return err
+}
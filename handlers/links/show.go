@@ -0,0 +1,121 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/google/uuid"
+)
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ShowHandler returns a single link with its ETag.
+type ShowHandler handlers.Group
+
+func (h ShowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	linkID, ok := linkIDVar(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	link, err := getLink(ctx, h.DB, linkID, middleware.CtxProfileUserID(ctx), false)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("ETag", linkETag(link.Version))
+	handlers.WriteResponse(w, http.StatusOK, link)
+}
+
+// getLink returns the link of the user with its sublinks, or sql.ErrNoRows if not found or in the trash.
+// The link row is locked until the end of the transaction if lock is set.
+func getLink(ctx context.Context, db querier, linkID uuid.UUID, userID string, lock bool) (*models.Link, error) {
+
+	stmt := `
+		SELECT l.id,
+		       l.type,
+		       l.title,
+		       l.url,
+		       l.thumbnail,
+		       l.quarantined,
+		       l.version,
+		       l.created_at,
+		       l.updated_at,
+
+		       sl.id,
+		       sl.metadata
+		  FROM links l
+		  LEFT JOIN sublinks sl ON sl.link_id = l.id
+		 WHERE l.id = $1 AND l.user_id = $2 AND l.deleted_at IS NULL
+	`
+	if lock {
+		stmt += " FOR UPDATE OF l"
+	}
+
+	rows, err := db.QueryContext(ctx, stmt, linkID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var link *models.Link
+	for rows.Next() {
+		var (
+			l         models.Link
+			updatedAt *time.Time
+			subID     *uuid.UUID
+			metadata  *json.RawMessage
+		)
+
+		err := rows.Scan(&l.ID, &l.Type, &l.Title, &l.URL, &l.Thumbnail,
+			&l.Quarantined, &l.Version, &l.CreatedAt, &updatedAt, &subID, &metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every row repeats the link, with one of its sublinks
+		if link == nil {
+			l.UUID = linkID
+			if updatedAt != nil {
+				l.UpdatedAt = *updatedAt
+			}
+			link = &l
+		}
+
+		if subID != nil && metadata != nil {
+			if _, err := addSublink(link, (*subID).String(), *metadata); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if link == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	return link, nil
+}
@@ -0,0 +1,111 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+var (
+	link1ID    = "b626168a-6c34-44cb-bf94-667c76235a26"
+	sublink1ID = "fbd19ca9-8006-448f-a2f0-52817ad7e9e1"
+	sublink2ID = "2cbc2043-d67e-45fc-a687-7e147def358f"
+)
+
+// musicLinkRows returns the rows of a music link with two sublinks at the given version
+func musicLinkRows(version int) *sqlmock.Rows {
+	createdAt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	return sqlmock.NewRows([]string{"l.id", "l.type", "l.title", "l.url", "l.thumbnail", "l.quarantined",
+		"l.version", "l.created_at", "l.updated_at", "sl.id", "sl.metadata"}).
+		AddRow(link1ID, "music", "All of me", "https://music-link.com/all-of-me", nil, false,
+			version, createdAt, createdAt, sublink1ID,
+			[]byte(`{"name":"Spotify","url":"https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs"}`)).
+		AddRow(link1ID, "music", "All of me", "https://music-link.com/all-of-me", nil, false,
+			version, createdAt, createdAt, sublink2ID,
+			[]byte(`{"name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-3"}`))
+}
+
+func TestShowHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		linkID     string
+		dbTx       func()
+		wantStatus int
+		wantETag   string
+		wantBody   string
+	}{
+		{
+			name:   "Link with sublinks",
+			linkID: link1ID,
+			dbTx: func() {
+				mock.ExpectQuery("SELECT l.id").WithArgs(link1ID, user1ID).WillReturnRows(musicLinkRows(3))
+			},
+			wantStatus: http.StatusOK,
+			wantETag:   `"3"`,
+			wantBody: `{"id":"` + link1ID + `","type":"music","title":"All of me","url":"https://music-link.com/all-of-me",` +
+				`"sublinks":[{"id":"` + sublink1ID + `","name":"Spotify","url":"https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs"},` +
+				`{"id":"` + sublink2ID + `","name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-3"}]}`,
+		},
+		{
+			name:   "Unknown link",
+			linkID: link1ID,
+			dbTx: func() {
+				mock.ExpectQuery("SELECT l.id").WithArgs(link1ID, user1ID).WillReturnRows(sqlmock.NewRows(nil))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid link id",
+			linkID:     "not-a-link",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/links/"+tc.linkID, nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			req = mux.SetURLVars(req, map[string]string{"link_id": tc.linkID})
+			recorder := httptest.NewRecorder()
+
+			ShowHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Header().Get("ETag"); got != tc.wantETag {
+				t.Errorf("got ETag %s, want %s", got, tc.wantETag)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/google/uuid"
+)
+
+// TrashHandler lists the deleted links of a user, most recently deleted first.
+type TrashHandler handlers.Group
+
+func (h TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	ctx := r.Context()
+	links, err := getTrashedLinks(ctx, h.DB, middleware.CtxProfileUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, links)
+}
+
+// RestoreHandler moves a link and its sublinks out of the trash.
+type RestoreHandler handlers.Group
+
+func (h RestoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleEditor) {
+		return
+	}
+
+	linkID, ok := linkIDVar(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.CtxProfileUserID(ctx)
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT deleted_at FROM links
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		   FOR UPDATE
+		`, linkID, userID).Scan(&deletedAt)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE links SET deleted_at = NULL, version = version + 1, updated_at = NOW() WHERE id = $1
+		`, linkID)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	link, err := getLink(ctx, tx, linkID, userID, false)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The link is unchanged in the trash, only its version and deletion differ
+	trashed := *link
+	trashed.Version--
+	trashed.DeletedAt = &deletedAt
+	if err := recordRevision(ctx, tx, revisionRestore, &trashed, link); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := touchLinks(ctx, tx, userID); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.LinksCache.Invalidate(userID)
+
+	w.Header().Set("ETag", linkETag(link.Version))
+	handlers.WriteResponse(w, http.StatusOK, link)
+}
+
+func getTrashedLinks(ctx context.Context, db *sql.DB, userID string) ([]models.Link, error) {
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.id,
+		       l.type,
+		       l.title,
+		       l.url,
+		       l.thumbnail,
+		       l.quarantined,
+		       l.deleted_at,
+
+		       sl.id,
+		       sl.metadata
+		  FROM links l
+		  LEFT JOIN sublinks sl ON sl.link_id = l.id
+		 WHERE l.user_id = $1 AND l.deleted_at IS NOT NULL
+		 ORDER BY l.deleted_at DESC, l.id
+		`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Rows of the same link are adjacent, one for each of its sublinks
+	links := []models.Link{}
+	for rows.Next() {
+		var (
+			l        models.Link
+			subID    *uuid.UUID
+			metadata *json.RawMessage
+		)
+
+		err := rows.Scan(&l.ID, &l.Type, &l.Title, &l.URL,
+			&l.Thumbnail, &l.Quarantined, &l.DeletedAt, &subID, &metadata)
+		if err != nil {
+			return nil, err
+		}
+
+		if n := len(links); n == 0 || links[n-1].ID != l.ID {
+			links = append(links, l)
+		}
+
+		if subID != nil && metadata != nil {
+			if _, err := addSublink(&links[len(links)-1], (*subID).String(), *metadata); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return links, rows.Err()
+}
+
+// PurgeTrash deletes the links, with their sublinks, which have been in the trash for longer than retention
+func PurgeTrash(ctx context.Context, db *sql.DB, retention time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-retention)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM sublinks
+		 WHERE link_id IN (SELECT id FROM links WHERE deleted_at < $1)
+		`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM links WHERE deleted_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
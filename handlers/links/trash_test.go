@@ -0,0 +1,168 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+func TestTrashHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	deletedAt := time.Date(2020, 5, 2, 10, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"l.id", "l.type", "l.title", "l.url", "l.thumbnail", "l.quarantined",
+		"l.deleted_at", "sl.id", "sl.metadata"}).
+		AddRow(link1ID, "music", "All of me", nil, nil, false, deletedAt, sublink1ID,
+			[]byte(`{"name":"Spotify","url":"https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs"}`)).
+		AddRow(link1ID, "music", "All of me", nil, nil, false, deletedAt, sublink2ID,
+			[]byte(`{"name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-3"}`)).
+		AddRow("6e3060f3-4c99-41c7-a97b-a287399f3dd1", "classic", "First Link", "http://firstlink.com/1",
+			nil, false, deletedAt.Add(-time.Hour), nil, nil)
+	mock.ExpectQuery("SELECT l.id(.+)deleted_at IS NOT NULL").WithArgs(user1ID).WillReturnRows(rows)
+
+	req := httptest.NewRequest("GET", "https://linktree.com/api/links/trash", nil)
+	req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+	recorder := httptest.NewRecorder()
+
+	TrashHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	wantBody := `[{"id":"` + link1ID + `","type":"music","title":"All of me","url":null,"deleted_at":"2020-05-02T10:00:00Z",` +
+		`"sublinks":[{"id":"` + sublink1ID + `","name":"Spotify","url":"https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs"},` +
+		`{"id":"` + sublink2ID + `","name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-3"}]},` +
+		`{"id":"6e3060f3-4c99-41c7-a97b-a287399f3dd1","type":"classic","title":"First Link","url":"http://firstlink.com/1",` +
+		`"deleted_at":"2020-05-02T09:00:00Z"}]`
+	if diff := test.CompareJSON(recorder.Body.String(), wantBody, t); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRestoreHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		linkID     string
+		role       string
+		dbTx       func()
+		wantStatus int
+		wantETag   string
+	}{
+		{
+			name:   "Link in the trash",
+			linkID: link1ID,
+			dbTx: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT deleted_at FROM links").WithArgs(link1ID, user1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(time.Now()))
+				mock.ExpectExec("UPDATE links SET deleted_at = NULL").WithArgs(link1ID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("SELECT l.id").WithArgs(link1ID, user1ID).WillReturnRows(musicLinkRows(5))
+				mock.ExpectExec("INSERT INTO link_revisions").
+					WithArgs(sqlmock.AnyArg(), link1ID, user1ID, user1ID, "restore", 5, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("UPDATE users SET links_updated_at").WithArgs(user1ID).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusOK,
+			wantETag:   `"5"`,
+		},
+		{
+			name:   "Link not in the trash",
+			linkID: link1ID,
+			dbTx: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery("SELECT deleted_at FROM links").WithArgs(link1ID, user1ID).
+					WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}))
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Viewer of a shared profile",
+			linkID:     link1ID,
+			role:       middleware.RoleViewer,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Invalid link id",
+			linkID:     "not-a-link",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("POST", "https://linktree.com/api/links/"+tc.linkID+"/restore", nil)
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			if tc.role != "" {
+				req = middleware.CtxSetProfile(req.Context(), req, user1ID, tc.role)
+			}
+			req = mux.SetURLVars(req, map[string]string{"link_id": tc.linkID})
+			recorder := httptest.NewRecorder()
+
+			RestoreHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Header().Get("ETag"); got != tc.wantETag {
+				t.Errorf("got ETag %s, want %s", got, tc.wantETag)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM sublinks").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM links WHERE deleted_at").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	n, err := PurgeTrash(context.Background(), db, 30*24*time.Hour)
+	if err != nil || n != 2 {
+		t.Errorf("got %d purged links and error %v, want 2", n, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,262 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/screening"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+// PutHandler replaces a link and its sublinks.
+type PutHandler handlers.Group
+
+func (h PutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	update(handlers.Group(h), w, r, revisionUpdate, func(ctx context.Context, db *sql.DB, current *models.Link) ([]byte, error) {
+		return body, nil
+	})
+}
+
+// PatchHandler updates the fields of a link given in the request, replacing its sublinks if given.
+type PatchHandler handlers.Group
+
+func (h PatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	update(handlers.Group(h), w, r, revisionUpdate, func(ctx context.Context, db *sql.DB, current *models.Link) ([]byte, error) {
+		return mergePayload(current, body)
+	})
+}
+
+// payloadFunc returns the payload to store in place of the current link.
+// Errors are reported to the client if they are an *e.Error.
+type payloadFunc func(ctx context.Context, db *sql.DB, current *models.Link) ([]byte, error)
+
+// update stores the link payload if the If-Match header matches the current version of the link,
+// recording the change as a revision with the given action. Links changed while the payload
+// is screened are not updated.
+func update(g handlers.Group, w http.ResponseWriter, r *http.Request, action string, payload payloadFunc) {
+	if !middleware.Authorize(w, r, middleware.RoleEditor) {
+		return
+	}
+
+	linkID, ok := linkIDVar(w, r)
+	if !ok {
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && g.RequireIfMatch {
+		e.WriteError(w, http.StatusPreconditionRequired, errIfMatchRequired)
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.CtxProfileUserID(ctx)
+
+	// The payload is built and screened before the link is locked, since screening follows the urls
+	current, err := getLink(ctx, g.DB, linkID, userID, false)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if ifMatch != "" && !matchesETag(ifMatch, current.Version) {
+		e.WriteError(w, http.StatusPreconditionFailed, errPreconditionFailed)
+		return
+	}
+
+	body, err := payload(ctx, g.DB, current)
+	if err == errRevisionNotFound {
+		e.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if _, ok := err.(*e.Error); ok {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	locales := validator.Locales(r.Header.Get("Accept-Language"))
+	link, sublinks, err := prepareDbObject(body, g.Validator, locales, sublinkIDs(current))
+	if err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	link.UUID, link.ID = linkID, current.ID
+
+	verdict, err := screenLink(ctx, g.Screener, link)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if verdict.Action == screening.ActionReject {
+		if err := recordScreening(ctx, g.DB, userID, &linkID, verdict); err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		e.WriteError(w, http.StatusBadRequest, e.New(codeURLBlocked, verdict.Reason))
+		return
+	}
+
+	tx, err := g.DB.Begin()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// The payload was built from the version read above, so the link must not have changed since
+	var version int
+	err = tx.QueryRowContext(ctx, `
+		SELECT version FROM links WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE
+		`, linkID, userID).Scan(&version)
+	switch {
+	case err == sql.ErrNoRows:
+		e.WriteError(w, http.StatusNotFound, errLinkNotFound)
+		return
+	case err != nil:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	case version != current.Version:
+		e.WriteError(w, http.StatusPreconditionFailed, errPreconditionFailed)
+		return
+	}
+
+	if err := updateLink(ctx, tx, userID, link, sublinks, verdict); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recordRevision(ctx, tx, action, current, link); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	g.LinksCache.Invalidate(userID)
+
+	w.Header().Set("ETag", linkETag(link.Version))
+	handlers.WriteResponse(w, http.StatusOK, *link)
+}
+
+// mergePayload returns the payload of the current link with the fields in body replaced
+func mergePayload(current *models.Link, body []byte) ([]byte, error) {
+	p := models.LinkPayload{
+		Type:      current.Type,
+		Title:     current.Title,
+		URL:       current.URL,
+		Thumbnail: current.Thumbnail,
+	}
+
+	for _, sl := range current.SubLinks {
+		data, err := json.Marshal(sl)
+		if err != nil {
+			return nil, err
+		}
+		p.SubLinks = append(p.SubLinks, data)
+	}
+
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, e.New(e.CodeMalformedBody, err.Error())
+	}
+
+	return json.Marshal(p)
+}
+
+// updateLink stores the link with its sublinks, incrementing its version. Sublinks not in sl
+// are deleted and the others upserted, so that kept sublinks keep their ids.
+// A link stays quarantined until reviewed, even if updated with allowed urls.
+func updateLink(ctx context.Context, tx *sql.Tx, userID string, l *models.Link, sl []models.Sublink, v screening.Verdict) error {
+	quarantine := v.Action == screening.ActionQuarantine
+
+	err := tx.QueryRowContext(ctx, `
+		UPDATE links
+		   SET type = $3, title = $4, url = $5, thumbnail = $6,
+		       quarantined = quarantined OR $7,
+		       version = version + 1,
+		       updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING quarantined, version, updated_at
+		`, l.UUID, userID, l.Type, l.Title, l.URL, l.Thumbnail, quarantine).
+		Scan(&l.Quarantined, &l.Version, &l.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if quarantine {
+		if err := recordScreening(ctx, tx, userID, &l.UUID, v); err != nil {
+			return err
+		}
+	}
+
+	stmt, values := generateRemovedDelete(l.UUID, sl)
+	if _, err := tx.ExecContext(ctx, stmt, values...); err != nil {
+		return err
+	}
+
+	if len(sl) > 0 {
+		stmt, values := generateBulkInsert(l.UUID, sl)
+		stmt += `
+		ON CONFLICT (id) DO UPDATE SET metadata = EXCLUDED.metadata
+		 WHERE sublinks.link_id = EXCLUDED.link_id`
+		if _, err := tx.ExecContext(ctx, stmt, values...); err != nil {
+			return err
+		}
+	}
+
+	return touchLinks(ctx, tx, userID)
+}
+
+// generateRemovedDelete returns the statement deleting the sublinks of a link which are not in sl
+func generateRemovedDelete(linkID uuid.UUID, sl []models.Sublink) (string, []interface{}) {
+	values := make([]interface{}, 0, len(sl)+1)
+	values = append(values, linkID)
+
+	if len(sl) == 0 {
+		return "DELETE FROM sublinks WHERE link_id = $1", values
+	}
+
+	placeholders := make([]string, 0, len(sl))
+	for i, s := range sl {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+2))
+		values = append(values, s.ID)
+	}
+
+	return fmt.Sprintf("DELETE FROM sublinks WHERE link_id = $1 AND id NOT IN (%s)",
+		strings.Join(placeholders, ", ")), values
+}
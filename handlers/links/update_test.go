@@ -0,0 +1,248 @@
+package links
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/screening"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+func TestUpdateHandlers_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	linkRead := func() {
+		mock.ExpectQuery("SELECT l.id").WithArgs(link1ID, user1ID).WillReturnRows(musicLinkRows(3))
+	}
+
+	linkLocked := func(version int) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT version FROM links (.+) FOR UPDATE").WithArgs(link1ID, user1ID).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+	}
+
+	// updated expects the update of the link, keeping the sublinks with the given ids
+	updated := func(quarantined bool, sublinkIDs ...driver.Value) func() {
+		return func() {
+			linkRead()
+			linkLocked(3)
+			mock.ExpectQuery("UPDATE links").
+				WithArgs(link1ID, user1ID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), quarantined).
+				WillReturnRows(sqlmock.NewRows([]string{"quarantined", "version", "updated_at"}).AddRow(quarantined, 4, time.Now()))
+			if quarantined {
+				mock.ExpectExec("INSERT INTO link_screenings").WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+			if len(sublinkIDs) > 0 {
+				mock.ExpectExec(`DELETE FROM sublinks WHERE link_id = \$1 AND id NOT IN`).
+					WithArgs(append([]driver.Value{link1ID}, sublinkIDs...)...).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO sublinks (.+) ON CONFLICT \(id\) DO UPDATE`).
+					WillReturnResult(sqlmock.NewResult(1, int64(len(sublinkIDs))))
+			} else {
+				mock.ExpectExec(`DELETE FROM sublinks WHERE link_id = \$1$`).WithArgs(link1ID).WillReturnResult(sqlmock.NewResult(0, 2))
+			}
+			mock.ExpectExec("UPDATE users SET links_updated_at").WithArgs(user1ID).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("INSERT INTO link_revisions").
+				WithArgs(sqlmock.AnyArg(), link1ID, user1ID, user1ID, "update", 4, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+		}
+	}
+
+	var testCases = []struct {
+		name           string
+		method         string
+		role           string
+		ifMatch        string
+		requireIfMatch bool
+		payload        string
+		dbTx           func()
+		wantStatus     int
+		wantETag       string
+		wantBody       string
+	}{
+		{
+			name:           "Missing If-Match",
+			method:         "PUT",
+			requireIfMatch: true,
+			payload:        `{"type":"classic","url":"https://mylink.com"}`,
+			wantStatus:     http.StatusPreconditionRequired,
+			wantBody: `{"type":"about:blank","title":"Precondition Required","status":428,"code":"if_match_required",` +
+				`"detail":"If-Match header with the link ETag is required"}`,
+		},
+		{
+			name:       "Stale If-Match",
+			method:     "PUT",
+			ifMatch:    `"2"`,
+			payload:    `{"type":"classic","url":"https://mylink.com"}`,
+			dbTx:       linkRead,
+			wantStatus: http.StatusPreconditionFailed,
+			wantBody: `{"type":"about:blank","title":"Precondition Failed","status":412,"code":"precondition_failed",` +
+				`"detail":"link was modified since it was read"}`,
+		},
+		{
+			name:       "Viewer of a shared profile",
+			method:     "PUT",
+			role:       middleware.RoleViewer,
+			ifMatch:    `"3"`,
+			payload:    `{"type":"classic","url":"https://mylink.com"}`,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:    "Unknown link",
+			method:  "PUT",
+			ifMatch: `"3"`,
+			payload: `{"type":"classic","url":"https://mylink.com"}`,
+			dbTx: func() {
+				mock.ExpectQuery("SELECT l.id").WithArgs(link1ID, user1ID).WillReturnRows(sqlmock.NewRows(nil))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid payload",
+			method:     "PUT",
+			ifMatch:    `"3"`,
+			payload:    `{"title":"My Link"}`,
+			dbTx:       linkRead,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Replace link",
+			method:     "PUT",
+			ifMatch:    `"1", "3"`,
+			payload:    `{"type":"classic","title":"My Link","url":"https://MyLink.com"}`,
+			dbTx:       updated(false),
+			wantStatus: http.StatusOK,
+			wantETag:   `"4"`,
+			wantBody:   `{"id":"` + link1ID + `","type":"classic","title":"My Link","url":"https://mylink.com"}`,
+		},
+		{
+			name:       "Replace link without If-Match when not required",
+			method:     "PUT",
+			payload:    `{"type":"classic","title":"My Link","url":"https://mylink.com"}`,
+			dbTx:       updated(false),
+			wantStatus: http.StatusOK,
+			wantETag:   `"4"`,
+		},
+		{
+			name:       "Patch title keeps sublinks",
+			method:     "PATCH",
+			ifMatch:    `"3"`,
+			payload:    `{"title":"All of me - John Legend"}`,
+			dbTx:       updated(false, sublink1ID, sublink2ID),
+			wantStatus: http.StatusOK,
+			wantETag:   `"4"`,
+			wantBody: `{"id":"` + link1ID + `","type":"music","title":"All of me - John Legend","url":"https://music-link.com/all-of-me",` +
+				`"sublinks":[{"id":"` + sublink1ID + `","name":"Spotify","url":"https://open.spotify.com/album/1YdXQgntClL3BhIXB0xpgs"},` +
+				`{"id":"` + sublink2ID + `","name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-3"}]}`,
+		},
+		{
+			name:    "Replace sublinks keeps known ids",
+			method:  "PUT",
+			ifMatch: `"3"`,
+			payload: `{"type":"music","url":"https://music-link.com/all-of-me","sublinks":[` +
+				`{"id":"` + sublink2ID + `","name":"SoundCloud","url":"https://soundcloud.com/johnlegend/all-of-me-4"},` +
+				`{"id":"` + sublink2ID + `","name":"Tidal","url":"https://tidal.com/track/1"}]}`,
+			dbTx:       updated(false, sublink2ID, sqlmock.AnyArg()),
+			wantStatus: http.StatusOK,
+			wantETag:   `"4"`,
+		},
+		{
+			name:       "Quarantined url",
+			method:     "PATCH",
+			ifMatch:    "*",
+			payload:    `{"url":"https://short.example/gift"}`,
+			dbTx:       updated(true, sublink1ID, sublink2ID),
+			wantStatus: http.StatusOK,
+			wantETag:   `"4"`,
+		},
+		{
+			name:    "Rejected url",
+			method:  "PATCH",
+			ifMatch: `"3"`,
+			payload: `{"url":"https://phishing.example/login"}`,
+			dbTx: func() {
+				linkRead()
+				mock.ExpectExec("INSERT INTO link_screenings").WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:    "Link changed while screened",
+			method:  "PUT",
+			ifMatch: `"3"`,
+			payload: `{"type":"classic","url":"https://mylink.com"}`,
+			dbTx: func() {
+				linkRead()
+				linkLocked(4)
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusPreconditionFailed,
+			wantBody: `{"type":"about:blank","title":"Precondition Failed","status":412,"code":"precondition_failed",` +
+				`"detail":"link was modified since it was read"}`,
+		},
+	}
+
+	scr := stubScreener{
+		"https://phishing.example/login": screening.ActionReject,
+		"https://short.example/gift":     screening.ActionQuarantine,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest(tc.method, "https://linktree.com/api/links/"+link1ID, strings.NewReader(tc.payload))
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+			if tc.role != "" {
+				req = middleware.CtxSetProfile(req.Context(), req, user1ID, tc.role)
+			}
+			req = mux.SetURLVars(req, map[string]string{"link_id": link1ID})
+			recorder := httptest.NewRecorder()
+
+			g := handlers.Group{DB: db, Validator: validator.New(), Screener: scr, RequireIfMatch: tc.requireIfMatch}
+			if tc.method == "PATCH" {
+				PatchHandler(g).ServeHTTP(recorder, req)
+			} else {
+				PutHandler(g).ServeHTTP(recorder, req)
+			}
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Header().Get("ETag"); got != tc.wantETag {
+				t.Errorf("got ETag %s, want %s", got, tc.wantETag)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				ignoreFields := []string{"id"}
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t, ignoreFields...); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ClickCount is the number of clicks and distinct visitors in a period.
+// Visitors are not counted by hour.
+type ClickCount struct {
+	Clicks   int64 `json:"clicks"`
+	Visitors int64 `json:"visitors,omitempty"`
+}
+
+// ClickBucket counts the clicks of the interval starting at Start
+type ClickBucket struct {
+	Start time.Time `json:"start"`
+	ClickCount
+}
+
+// SublinkAnalytics counts the clicks on a sublink
+type SublinkAnalytics struct {
+	SublinkID string `json:"sublink_id"`
+	ClickCount
+	Buckets []ClickBucket `json:"buckets"`
+}
+
+// LinkAnalytics counts the clicks on a link and its sublinks, with the clicks on each sublink
+type LinkAnalytics struct {
+	LinkID string `json:"link_id"`
+	ClickCount
+	Buckets  []ClickBucket      `json:"buckets"`
+	Sublinks []SublinkAnalytics `json:"sublinks,omitempty"`
+}
+
+// Breakdown is the number of clicks with a given referrer or device
+type Breakdown struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Analytics are the clicks on the links of a user between From and To
+type Analytics struct {
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Interval  string          `json:"interval"`
+	Links     []LinkAnalytics `json:"links"`
+	Referrers []Breakdown     `json:"referrers"`
+	Devices   []Breakdown     `json:"devices"`
+}
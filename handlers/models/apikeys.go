@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// APIKeyPayload validates a request to create a personal API key
+type APIKeyPayload struct {
+	Name       string     `json:"name" validate:"required,max=100"`
+	Scopes     []string   `json:"scopes" validate:"required,min=1,dive,oneof=links:read links:write analytics:read"`
+	ExpireAt   *time.Time `json:"expire_at"`
+	AllowedIPs []string   `json:"allowed_ips" validate:"omitempty,max=20,dive,ip|cidr"`
+}
+
+// APIKey is a long lived key granting scoped access to the api.
+// Key is only returned when the key is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpireAt   *time.Time `json:"expire_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
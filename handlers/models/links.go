@@ -22,30 +22,34 @@ const (
 // Link is the base model for a link that can contain a list
 // of sublinks associated with its type
 type Link struct {
-	ID        string        `json:"id"`
-	UUID      uuid.UUID     `json:"-"`
-	UserID    string        `json:"-"`
-	Type      linkType      `json:"type"`
-	Title     *string       `json:"title"`
-	URL       *string       `json:"url"`
-	Thumbnail *string       `json:"thumbnail,omitempty"`
-	CreatedAt time.Time     `json:"-"`
-	SubLinks  []interface{} `json:"sublinks,omitempty"`
+	ID          string        `json:"id"`
+	UUID        uuid.UUID     `json:"-"`
+	UserID      string        `json:"-"`
+	Type        linkType      `json:"type"`
+	Title       *string       `json:"title"`
+	URL         *string       `json:"url"`
+	Thumbnail   *string       `json:"thumbnail,omitempty"`
+	Quarantined bool          `json:"quarantined,omitempty"`
+	Version     int           `json:"-"`
+	CreatedAt   time.Time     `json:"-"`
+	UpdatedAt   time.Time     `json:"-"`
+	DeletedAt   *time.Time    `json:"deleted_at,omitempty"`
+	SubLinks    []interface{} `json:"sublinks,omitempty"`
 }
 
-// LinkPayload validates a request to create a new Link
+// LinkPayload validates a request to create a new Link.
+// Sublinks are bounded so are the urls screened by a request.
 type LinkPayload struct {
 	Type      linkType          `json:"type" validate:"required,oneof=classic music shows"`
 	Title     *string           `json:"title" validate:"omitempty,max=144"`
-	URL       *string           `json:"url" validate:"omitempty,max=500"`
+	URL       *string           `json:"url" validate:"omitempty,lkURL=500"`
 	Thumbnail *string           `json:"thumbnail,omitempty" validate:"omitempty,max=144"`
-	SubLinks  []json.RawMessage `json:"sublinks,omitempty"`
+	SubLinks  []json.RawMessage `json:"sublinks,omitempty" validate:"max=50"`
 }
 
 // Sublink contains the metadata of a sublink
 type Sublink struct {
 	ID       uuid.UUID
-	UserID   string
 	Metadata json.RawMessage
 }
 
@@ -67,14 +71,14 @@ type Show struct {
 	Venue    string     `json:"venue" validate:"required_without=Location"`
 	Location string     `json:"location" validate:"required_without=Venue"`
 	Status   showStatus `json:"status" validate:"required,oneof=on-sale sold-out not-on-sale"`
-	URL      string     `json:"url" validate:"required"`
+	URL      string     `json:"url" validate:"required,lkURL"`
 }
 
 // Platform is a sublink representing a song's streaming platform and its url
 type Platform struct {
 	ID   string `json:"id"`
 	Name string `json:"name" validate:"required"`
-	URL  string `json:"url" validate:"required"`
+	URL  string `json:"url" validate:"required,lkURL"`
 }
 
 // GenerateUUIDPair returns a newly generated UUID (version 4) and its string version
@@ -0,0 +1,30 @@
+package models
+
+// DefaultTheme is the theme of profiles whose users have not chosen one
+const DefaultTheme = "light"
+
+// Profile is the public page of a user, listing their visible links in position order
+type Profile struct {
+	Username string `json:"username"`
+	ProfileDetails
+	Appearance Appearance `json:"appearance"`
+	Links      []Link     `json:"links"`
+}
+
+// ProfileDetails describe the user on their public page and in its previews when shared
+type ProfileDetails struct {
+	DisplayName *string `json:"display_name,omitempty" validate:"omitempty,max=60"`
+	Bio         *string `json:"bio,omitempty" validate:"omitempty,max=160"`
+	Avatar      *string `json:"avatar,omitempty" validate:"omitempty,lkURL=500"`
+}
+
+// Appearance is the style of the public page of a user: a theme, with optional colours
+// and font replacing those of the theme
+type Appearance struct {
+	Theme           string  `json:"theme" validate:"required,oneof=light dark sunset"`
+	BackgroundColor *string `json:"background_color,omitempty" validate:"omitempty,hexcolor"`
+	TextColor       *string `json:"text_color,omitempty" validate:"omitempty,hexcolor"`
+	ButtonColor     *string `json:"button_color,omitempty" validate:"omitempty,hexcolor"`
+	ButtonTextColor *string `json:"button_text_color,omitempty" validate:"omitempty,hexcolor"`
+	Font            *string `json:"font,omitempty" validate:"omitempty,oneof=system serif mono rounded"`
+}
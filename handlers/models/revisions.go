@@ -0,0 +1,18 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// LinkRevision is a change to a link by a user, with the link before and after it.
+// Before is null for created links.
+type LinkRevision struct {
+	ID        string          `json:"id"`
+	ActorID   string          `json:"actor_id"`
+	Action    string          `json:"action"`
+	Version   int             `json:"version"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// CredentialsPayload validates a request to issue new tokens
+type CredentialsPayload struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RefreshPayload validates a request to refresh the tokens of a session
+type RefreshPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Tokens is the response to a successful authentication or refresh
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	SessionID    string `json:"session_id"`
+}
+
+// Session groups the tokens issued from a single authentication
+type Session struct {
+	ID              string     `json:"id"`
+	UserAgent       *string    `json:"user_agent"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastRefreshedAt *time.Time `json:"last_refreshed_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// TeamPayload validates a request to create a team
+type TeamPayload struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// MemberPayload validates a request to set the role of a team member
+type MemberPayload struct {
+	Role string `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+// Team groups users managing the profiles of its owners.
+// Role is the role of the authenticated user in the team, Pending if not accepted yet.
+type Team struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Role      string       `json:"role"`
+	Pending   bool         `json:"pending,omitempty"`
+	Members   []TeamMember `json:"members"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// TeamMember is a user with a role on the profiles of the team owners, once they accept
+type TeamMember struct {
+	UserID  string `json:"user_id"`
+	Role    string `json:"role"`
+	Pending bool   `json:"pending,omitempty"`
+}
@@ -0,0 +1,95 @@
+package profiles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+// AppearanceHandler returns the appearance of the public page of the profile
+type AppearanceHandler handlers.Group
+
+func (h AppearanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	ctx := r.Context()
+	a, err := getAppearance(ctx, h.DB, middleware.CtxProfileUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, a)
+}
+
+// AppearancePutHandler sets the theme, colours and font of the public page of the profile
+type AppearancePutHandler handlers.Group
+
+func (h AppearancePutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleEditor) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var a models.Appearance
+	err = json.Unmarshal(body, &a)
+	if err := e.CheckValid(err, a, h.Validator, validator.Locales(r.Header.Get("Accept-Language"))...); err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.CtxProfileUserID(ctx)
+
+	_, err = h.DB.ExecContext(ctx, `
+		INSERT INTO profiles (user_id, theme, background_color, text_color, button_color, button_text_color, font)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		    ON CONFLICT (user_id) DO UPDATE
+		   SET theme = EXCLUDED.theme,
+		       background_color = EXCLUDED.background_color,
+		       text_color = EXCLUDED.text_color,
+		       button_color = EXCLUDED.button_color,
+		       button_text_color = EXCLUDED.button_text_color,
+		       font = EXCLUDED.font,
+		       updated_at = NOW()
+		`, userID, a.Theme, a.BackgroundColor, a.TextColor, a.ButtonColor, a.ButtonTextColor, a.Font)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// The public page is cached with the links
+	h.LinksCache.Invalidate(userID)
+
+	handlers.WriteResponse(w, http.StatusOK, a)
+}
+
+// getAppearance returns the appearance of the user, the default theme if they have not set one
+func getAppearance(ctx context.Context, db *sql.DB, userID string) (models.Appearance, error) {
+	var a models.Appearance
+
+	err := db.QueryRowContext(ctx, `
+		SELECT theme, background_color, text_color, button_color, button_text_color, font
+		  FROM profiles
+		 WHERE user_id = $1
+		`, userID).Scan(&a.Theme, &a.BackgroundColor, &a.TextColor, &a.ButtonColor, &a.ButtonTextColor, &a.Font)
+	if err == sql.ErrNoRows {
+		return models.Appearance{Theme: models.DefaultTheme}, nil
+	}
+
+	return a, err
+}
@@ -0,0 +1,153 @@
+package profiles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var (
+	user1ID = "fac90185-d243-46f5-8797-e57ac9c2c293"
+	user2ID = "9bce575b-1507-4a0f-a523-4072a72fc968"
+)
+
+var appearanceColumns = []string{"theme", "background_color", "text_color", "button_color", "button_text_color", "font"}
+
+func TestAppearanceHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name     string
+		userID   string
+		dbQuery  func()
+		wantBody string
+	}{
+		{
+			name:   "Appearance set",
+			userID: user1ID,
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT theme").WithArgs(user1ID).WillReturnRows(sqlmock.NewRows(appearanceColumns).
+					AddRow("dark", "#000000", nil, "#ff0000", nil, "serif"))
+			},
+			wantBody: `{"theme": "dark", "background_color": "#000000", "button_color": "#ff0000", "font": "serif"}`,
+		},
+		{
+			name:   "Default appearance",
+			userID: user2ID,
+			dbQuery: func() {
+				mock.ExpectQuery("SELECT theme").WithArgs(user2ID).WillReturnRows(sqlmock.NewRows(appearanceColumns))
+			},
+			wantBody: `{"theme": "light"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.dbQuery()
+
+			req := httptest.NewRequest("GET", "https://linktree.com/api/profile/appearance", nil)
+			req = middleware.CtxSetUserID(req.Context(), req, tc.userID)
+			recorder := httptest.NewRecorder()
+
+			AppearanceHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+			if got, want := recorder.Code, http.StatusOK; got != want {
+				t.Errorf("got status %d, want %d", got, want)
+			}
+
+			if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t); diff != "" {
+				t.Error(diff)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAppearancePutHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		role       string
+		body       string
+		dbExec     func()
+		wantStatus int
+	}{
+		{
+			name: "Theme with colours",
+			role: middleware.RoleEditor,
+			body: `{"theme": "sunset", "text_color": "#fff", "font": "mono"}`,
+			dbExec: func() {
+				mock.ExpectExec("INSERT INTO profiles").
+					WithArgs(user1ID, "sunset", nil, "#fff", nil, nil, "mono").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Unknown theme",
+			role:       middleware.RoleEditor,
+			body:       `{"theme": "neon"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid colour",
+			role:       middleware.RoleEditor,
+			body:       `{"theme": "light", "button_color": "red; background: url(x)"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown font",
+			role:       middleware.RoleEditor,
+			body:       `{"theme": "light", "font": "comic"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Viewer",
+			role:       middleware.RoleViewer,
+			body:       `{"theme": "dark"}`,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbExec != nil {
+				tc.dbExec()
+			}
+
+			req := httptest.NewRequest("PUT", "https://linktree.com/api/profile/appearance", strings.NewReader(tc.body))
+			req = middleware.CtxSetProfile(req.Context(), req, user1ID, tc.role)
+			recorder := httptest.NewRecorder()
+
+			AppearancePutHandler(handlers.Group{DB: db, Validator: validator.New()}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
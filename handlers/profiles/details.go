@@ -0,0 +1,90 @@
+package profiles
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+// DetailsHandler returns the display name, bio and avatar of the profile
+type DetailsHandler handlers.Group
+
+func (h DetailsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	ctx := r.Context()
+	d, err := getDetails(ctx, h.DB, middleware.CtxProfileUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, d)
+}
+
+// DetailsPutHandler sets the display name, bio and avatar of the profile
+type DetailsPutHandler handlers.Group
+
+func (h DetailsPutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, middleware.RoleEditor) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var d models.ProfileDetails
+	err = json.Unmarshal(body, &d)
+	if err := e.CheckValid(err, d, h.Validator, validator.Locales(r.Header.Get("Accept-Language"))...); err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.CtxProfileUserID(ctx)
+
+	// Profiles created here get the default theme
+	_, err = h.DB.ExecContext(ctx, `
+		INSERT INTO profiles (user_id, theme, display_name, bio, avatar)
+		VALUES ($1, $2, $3, $4, $5)
+		    ON CONFLICT (user_id) DO UPDATE
+		   SET display_name = EXCLUDED.display_name,
+		       bio = EXCLUDED.bio,
+		       avatar = EXCLUDED.avatar,
+		       updated_at = NOW()
+		`, userID, models.DefaultTheme, d.DisplayName, d.Bio, d.Avatar)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// The public page is cached with the links
+	h.LinksCache.Invalidate(userID)
+
+	handlers.WriteResponse(w, http.StatusOK, d)
+}
+
+// getDetails returns the details of the user, empty if they have not set them
+func getDetails(ctx context.Context, db *sql.DB, userID string) (models.ProfileDetails, error) {
+	var d models.ProfileDetails
+
+	err := db.QueryRowContext(ctx, "SELECT display_name, bio, avatar FROM profiles WHERE user_id = $1", userID).
+		Scan(&d.DisplayName, &d.Bio, &d.Avatar)
+	if err == sql.ErrNoRows {
+		return d, nil
+	}
+
+	return d, err
+}
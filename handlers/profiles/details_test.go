@@ -0,0 +1,103 @@
+package profiles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+func TestDetailsHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT display_name, bio, avatar FROM profiles").WithArgs(user1ID).
+		WillReturnRows(sqlmock.NewRows([]string{"display_name", "bio", "avatar"}).AddRow("The Artist", nil, nil))
+
+	req := httptest.NewRequest("GET", "https://linktree.com/api/profile", nil)
+	req = middleware.CtxSetUserID(req.Context(), req, user1ID)
+	recorder := httptest.NewRecorder()
+
+	DetailsHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	if diff := test.CompareJSON(recorder.Body.String(), `{"display_name": "The Artist"}`, t); diff != "" {
+		t.Error(diff)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDetailsPutHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		body       string
+		dbExec     func()
+		wantStatus int
+	}{
+		{
+			name: "Details",
+			body: `{"display_name": "The Artist", "bio": "Songs and shows", "avatar": "https://img.linktr.ee/artist.png"}`,
+			dbExec: func() {
+				mock.ExpectExec("INSERT INTO profiles").
+					WithArgs(user1ID, models.DefaultTheme, "The Artist", "Songs and shows", "https://img.linktr.ee/artist.png").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Bio too long",
+			body:       `{"bio": "` + strings.Repeat("a", 161) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid avatar",
+			body:       `{"avatar": "javascript:alert(1)"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbExec != nil {
+				tc.dbExec()
+			}
+
+			req := httptest.NewRequest("PUT", "https://linktree.com/api/profile", strings.NewReader(tc.body))
+			req = middleware.CtxSetProfile(req.Context(), req, user1ID, middleware.RoleEditor)
+			recorder := httptest.NewRecorder()
+
+			DetailsPutHandler(handlers.Group{DB: db, Validator: validator.New()}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
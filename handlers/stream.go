@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// StreamChunkTimeout is the time given to write each chunk of a streamed response,
+// so that streams of any size outlast the write timeout of the server
+const StreamChunkTimeout = 30 * time.Second
+
+// StreamCompleteTrailer is the trailer ending the streams written in full,
+// truncated streams miss it
+const StreamCompleteTrailer = "Stream-Complete"
+
+// Stream writes a response in chunks, each given StreamChunkTimeout to be written
+type Stream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// NewStream returns a Stream writing to w, giving the first chunk StreamChunkTimeout to be written
+func NewStream(w http.ResponseWriter) (*Stream, error) {
+	s := &Stream{w: w, rc: http.NewResponseController(w)}
+	return s, s.extend()
+}
+
+// Flush sends the chunk written so far to the client and gives the next one StreamChunkTimeout
+func (s *Stream) Flush() error {
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return s.extend()
+}
+
+// Complete ends the stream with the StreamCompleteTrailer, once all its chunks are written
+func (s *Stream) Complete() error {
+	s.w.Header().Set(http.TrailerPrefix+StreamCompleteTrailer, "true")
+	return s.Flush()
+}
+
+// extend moves the write deadline of the response, writers not supporting deadlines are left as they are
+func (s *Stream) extend() error {
+	err := s.rc.SetWriteDeadline(time.Now().Add(StreamChunkTimeout))
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
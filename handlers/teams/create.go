@@ -0,0 +1,74 @@
+package teams
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+// PostHandler creates a team owned by the authenticated user.
+type PostHandler handlers.Group
+
+func (h PostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var p models.TeamPayload
+	err = json.Unmarshal(body, &p)
+	if err := e.CheckValid(err, p, h.Validator, validator.Locales(r.Header.Get("Accept-Language"))...); err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID := middleware.CtxReqUserID(ctx)
+
+	id, idStr := models.GenerateUUIDPair()
+	t := models.Team{
+		ID:        idStr,
+		Name:      p.Name,
+		Role:      middleware.RoleOwner,
+		Members:   []models.TeamMember{{UserID: userID, Role: middleware.RoleOwner}},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO teams (id, name, created_at)
+		VALUES ($1, $2, $3)
+		`, id, t.Name, t.CreatedAt)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO team_members (team_id, user_id, role, accepted_at)
+			VALUES ($1, $2, $3, NOW())
+			`, id, userID, middleware.RoleOwner)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusCreated, t)
+}
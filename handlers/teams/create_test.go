@@ -0,0 +1,86 @@
+package teams
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var (
+	ownerID   = "fac90185-d243-46f5-8797-e57ac9c2c293"
+	managerID = "9bce575b-1507-4a0f-a523-4072a72fc968"
+	team1ID   = "2d4c7e1a-5b6f-4a3e-8c9d-0e1f2a3b4c5d"
+)
+
+func TestPostHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	var testCases = []struct {
+		name       string
+		payload    string
+		dbTx       func()
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:    "Valid team",
+			payload: `{"name":"Label"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("INSERT INTO teams").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("INSERT INTO team_members").
+					WithArgs(sqlmock.AnyArg(), ownerID, middleware.RoleOwner).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"name":"Label","role":"owner","members":[{"user_id":"` + ownerID + `","role":"owner"}]}`,
+		},
+		{
+			name:       "Missing name",
+			payload:    `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("POST", "https://linktree.com/api/teams", strings.NewReader(tc.payload))
+			req = middleware.CtxSetUserID(req.Context(), req, ownerID)
+			recorder := httptest.NewRecorder()
+
+			PostHandler(handlers.Group{DB: db, Validator: validator.New()}).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+
+			if tc.wantBody != "" {
+				ignoreFields := []string{"id", "created_at"}
+				if diff := test.CompareJSON(recorder.Body.String(), tc.wantBody, t, ignoreFields...); diff != "" {
+					t.Error(diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package teams
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+// IndexHandler lists the teams of the authenticated user with their members, including
+// the teams the user is invited to.
+type IndexHandler handlers.Group
+
+func (h IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := middleware.CtxReqUserID(ctx)
+
+	teams, err := getUserTeams(ctx, h.DB, userID)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	handlers.WriteResponse(w, http.StatusOK, teams)
+}
+
+func getUserTeams(ctx context.Context, db *sql.DB, userID string) ([]models.Team, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.id,
+		       t.name,
+		       me.role,
+		       me.accepted_at IS NULL,
+		       t.created_at,
+
+		       m.user_id,
+		       m.role,
+		       m.accepted_at IS NULL
+		  FROM teams t
+		  JOIN team_members me ON me.team_id = t.id
+		                      AND me.user_id = $1
+		  JOIN team_members m ON m.team_id = t.id
+		 ORDER BY t.created_at, t.id, m.user_id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []models.Team{}
+	for rows.Next() {
+		var (
+			t models.Team
+			m models.TeamMember
+		)
+
+		if err := rows.Scan(&t.ID, &t.Name, &t.Role, &t.Pending, &t.CreatedAt, &m.UserID, &m.Role, &m.Pending); err != nil {
+			return nil, err
+		}
+
+		// Rows are ordered by team so members of the same team are adjacent
+		if n := len(teams); n > 0 && teams[n-1].ID == t.ID {
+			teams[n-1].Members = append(teams[n-1].Members, m)
+			continue
+		}
+
+		t.Members = []models.TeamMember{m}
+		teams = append(teams, t)
+	}
+
+	return teams, rows.Err()
+}
@@ -0,0 +1,48 @@
+package teams
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/test"
+)
+
+func TestIndexHandler_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	createdAt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"t.id", "t.name", "me.role", "me.pending", "t.created_at", "m.user_id", "m.role", "m.pending"}).
+		AddRow(team1ID, "Label", "editor", true, createdAt, managerID, "editor", true).
+		AddRow(team1ID, "Label", "editor", true, createdAt, ownerID, "owner", false)
+	mock.ExpectQuery("SELECT t.id").WithArgs(managerID).WillReturnRows(rows)
+
+	req := httptest.NewRequest("GET", "https://linktree.com/api/teams", nil)
+	req = middleware.CtxSetUserID(req.Context(), req, managerID)
+	recorder := httptest.NewRecorder()
+
+	IndexHandler(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	wantBody := `[{"id":"` + team1ID + `","name":"Label","role":"editor","pending":true,"created_at":"2020-05-01T10:00:00Z",` +
+		`"members":[{"user_id":"` + managerID + `","role":"editor","pending":true},{"user_id":"` + ownerID + `","role":"owner"}]}]`
+	if diff := test.CompareJSON(recorder.Body.String(), wantBody, t); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,268 @@
+package teams
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+var (
+	errTeamNotFound = e.New("team_not_found", "team not found")
+	errUserNotFound = e.New("user_not_found", "user not found")
+	errNotTeamOwner = e.New("not_team_owner", "only team owners can manage members")
+	errLastOwner    = e.New("last_owner", "a team must keep at least one owner")
+
+	errOwnerNotGrantable  = e.New("owner_not_grantable", "the owner role cannot be granted to other users")
+	errInvitationNotFound = e.New("invitation_not_found", "invitation not found")
+)
+
+// MemberPutHandler invites a user to a team or changes their role. Only owners manage members,
+// and invited users act on the profiles of the team owners once they accept.
+// The owner role is never granted to another user, as it would share their profile.
+type MemberPutHandler handlers.Group
+
+func (h MemberPutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var p models.MemberPayload
+	err = json.Unmarshal(body, &p)
+	if err := e.CheckValid(err, p, h.Validator, validator.Locales(r.Header.Get("Accept-Language"))...); err != nil {
+		e.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := r.Context()
+	teamID, memberID, ok := memberVars(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	status, err := checkOwner(ctx, tx, teamID, middleware.CtxReqUserID(ctx))
+	if err != nil {
+		e.WriteError(w, status, err)
+		return
+	}
+
+	if p.Role == middleware.RoleOwner && memberID != middleware.CtxReqUserID(ctx) {
+		e.WriteError(w, http.StatusForbidden, errOwnerNotGrantable)
+		return
+	}
+
+	if p.Role != middleware.RoleOwner {
+		if status, err := checkOtherOwners(ctx, tx, teamID, memberID); err != nil {
+			e.WriteError(w, status, err)
+			return
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO team_members (team_id, user_id, role)
+		SELECT $1, u.id, $3
+		  FROM users u
+		 WHERE u.id = $2
+		    ON CONFLICT (team_id, user_id) DO UPDATE SET role = EXCLUDED.role
+		`, teamID, memberID, p.Role)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if n == 0 {
+		e.WriteError(w, http.StatusNotFound, errUserNotFound)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptHandler accepts the invitation of the authenticated user to a team.
+type AcceptHandler handlers.Group
+
+func (h AcceptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	teamID := mux.Vars(r)["team_id"]
+	if _, err := uuid.Parse(teamID); err != nil {
+		e.WriteError(w, http.StatusNotFound, errInvitationNotFound)
+		return
+	}
+
+	res, err := h.DB.ExecContext(ctx, `
+		UPDATE team_members
+		   SET accepted_at = NOW()
+		 WHERE team_id = $1
+		   AND user_id = $2
+		   AND accepted_at IS NULL
+		`, teamID, middleware.CtxReqUserID(ctx))
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if n == 0 {
+		e.WriteError(w, http.StatusNotFound, errInvitationNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MemberDeleteHandler removes a user from a team. Owners remove any member,
+// members can leave and invited users decline.
+type MemberDeleteHandler handlers.Group
+
+func (h MemberDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := middleware.CtxReqUserID(ctx)
+
+	teamID, memberID, ok := memberVars(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer tx.Rollback()
+
+	if memberID != userID {
+		if status, err := checkOwner(ctx, tx, teamID, userID); err != nil {
+			e.WriteError(w, status, err)
+			return
+		}
+	}
+
+	if status, err := checkOtherOwners(ctx, tx, teamID, memberID); err != nil {
+		e.WriteError(w, status, err)
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM team_members
+		 WHERE team_id = $1
+		   AND user_id = $2
+		`, teamID, memberID)
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if n == 0 {
+		e.WriteError(w, http.StatusNotFound, errUserNotFound)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// memberVars returns the team and user ids in the path, writing a not found error if invalid
+func memberVars(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	vars := mux.Vars(r)
+	if _, err := uuid.Parse(vars["team_id"]); err != nil {
+		e.WriteError(w, http.StatusNotFound, errTeamNotFound)
+		return "", "", false
+	}
+	if _, err := uuid.Parse(vars["user_id"]); err != nil {
+		e.WriteError(w, http.StatusNotFound, errUserNotFound)
+		return "", "", false
+	}
+
+	return vars["team_id"], vars["user_id"], true
+}
+
+// checkOwner returns an error with its status unless the user owns the team.
+// The membership row is locked so that concurrent changes cannot remove every owner.
+func checkOwner(ctx context.Context, tx *sql.Tx, teamID, userID string) (int, error) {
+	var role string
+	err := tx.QueryRowContext(ctx, `
+		SELECT role
+		  FROM team_members
+		 WHERE team_id = $1
+		   AND user_id = $2
+		   FOR UPDATE
+		`, teamID, userID).Scan(&role)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return http.StatusNotFound, errTeamNotFound
+	case err != nil:
+		return http.StatusInternalServerError, err
+	case role != middleware.RoleOwner:
+		return http.StatusForbidden, errNotTeamOwner
+	}
+
+	return 0, nil
+}
+
+// checkOtherOwners returns an error with its status unless the team has owners other than the user
+func checkOtherOwners(ctx context.Context, tx *sql.Tx, teamID, userID string) (int, error) {
+	var owners int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		  FROM team_members
+		 WHERE team_id = $1
+		   AND user_id <> $2
+		   AND role = 'owner'
+		`, teamID, userID).Scan(&owners)
+
+	switch {
+	case err != nil:
+		return http.StatusInternalServerError, err
+	case owners == 0:
+		return http.StatusConflict, errLastOwner
+	}
+
+	return 0, nil
+}
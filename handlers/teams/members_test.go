@@ -0,0 +1,229 @@
+package teams
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
+)
+
+func TestMemberHandlers_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	callerRole := func(userID, role string) {
+		rows := sqlmock.NewRows([]string{"role"})
+		if role != "" {
+			rows.AddRow(role)
+		}
+		mock.ExpectQuery("SELECT role").WithArgs(team1ID, userID).WillReturnRows(rows)
+	}
+
+	otherOwners := func(memberID string, n int) {
+		mock.ExpectQuery("SELECT COUNT").WithArgs(team1ID, memberID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(n))
+	}
+
+	var testCases = []struct {
+		name       string
+		method     string
+		userID     string
+		memberID   string
+		payload    string
+		dbTx       func()
+		wantStatus int
+	}{
+		{
+			name:     "Owner adds an editor",
+			method:   "PUT",
+			userID:   ownerID,
+			memberID: managerID,
+			payload:  `{"role":"editor"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				callerRole(ownerID, middleware.RoleOwner)
+				otherOwners(managerID, 1)
+				mock.ExpectExec("INSERT INTO team_members").WithArgs(team1ID, managerID, "editor").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:     "Owner adds an unknown user",
+			method:   "PUT",
+			userID:   ownerID,
+			memberID: managerID,
+			payload:  `{"role":"viewer"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				callerRole(ownerID, middleware.RoleOwner)
+				otherOwners(managerID, 1)
+				mock.ExpectExec("INSERT INTO team_members").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:     "Owner cannot make another user an owner",
+			method:   "PUT",
+			userID:   ownerID,
+			memberID: managerID,
+			payload:  `{"role":"owner"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				callerRole(ownerID, middleware.RoleOwner)
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:     "Editor cannot manage members",
+			method:   "PUT",
+			userID:   managerID,
+			memberID: managerID,
+			payload:  `{"role":"owner"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				callerRole(managerID, middleware.RoleEditor)
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:     "Team of another user",
+			method:   "PUT",
+			userID:   managerID,
+			memberID: managerID,
+			payload:  `{"role":"owner"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				callerRole(managerID, "")
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:     "Last owner cannot step down",
+			method:   "PUT",
+			userID:   ownerID,
+			memberID: ownerID,
+			payload:  `{"role":"viewer"}`,
+			dbTx: func() {
+				mock.ExpectBegin()
+				callerRole(ownerID, middleware.RoleOwner)
+				otherOwners(ownerID, 0)
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Invalid role",
+			method:     "PUT",
+			userID:     ownerID,
+			memberID:   managerID,
+			payload:    `{"role":"admin"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:     "Member leaves the team",
+			method:   "DELETE",
+			userID:   managerID,
+			memberID: managerID,
+			dbTx: func() {
+				mock.ExpectBegin()
+				otherOwners(managerID, 1)
+				mock.ExpectExec("DELETE FROM team_members").WithArgs(team1ID, managerID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:     "Last owner cannot leave",
+			method:   "DELETE",
+			userID:   ownerID,
+			memberID: ownerID,
+			dbTx: func() {
+				mock.ExpectBegin()
+				otherOwners(ownerID, 0)
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:     "Editor cannot remove other members",
+			method:   "DELETE",
+			userID:   managerID,
+			memberID: ownerID,
+			dbTx: func() {
+				mock.ExpectBegin()
+				callerRole(managerID, middleware.RoleEditor)
+				mock.ExpectRollback()
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:   "Invited user accepts",
+			method: "POST",
+			userID: managerID,
+			dbTx: func() {
+				mock.ExpectExec("UPDATE team_members SET accepted_at = NOW\\(\\) (.+) accepted_at IS NULL").
+					WithArgs(team1ID, managerID).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:   "No pending invitation",
+			method: "POST",
+			userID: managerID,
+			dbTx: func() {
+				mock.ExpectExec("UPDATE team_members").WithArgs(team1ID, managerID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			url := "https://linktree.com/api/teams/" + team1ID + "/members/" + tc.memberID
+			req := httptest.NewRequest(tc.method, url, strings.NewReader(tc.payload))
+			req = middleware.CtxSetUserID(req.Context(), req, tc.userID)
+			req = mux.SetURLVars(req, map[string]string{"team_id": team1ID, "user_id": tc.memberID})
+			recorder := httptest.NewRecorder()
+
+			g := handlers.Group{DB: db, Validator: validator.New()}
+			switch tc.method {
+			case "PUT":
+				MemberPutHandler(g).ServeHTTP(recorder, req)
+			case "DELETE":
+				MemberDeleteHandler(g).ServeHTTP(recorder, req)
+			case "POST":
+				AcceptHandler(g).ServeHTTP(recorder, req)
+			}
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
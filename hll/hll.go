@@ -0,0 +1,139 @@
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	// precision is the number of hash bits selecting a register, for a standard error of 1.6%
+	precision = 12
+	registers = 1 << precision
+
+	encodingDense  = 0
+	encodingSparse = 1
+)
+
+var errInvalidSketch = errors.New("hll: invalid sketch encoding")
+
+// Sketch is a HyperLogLog estimating the number of distinct 64-bit hashes added to it.
+// Sketches can be merged to estimate the distinct hashes added to any of them.
+type Sketch struct {
+	regs [registers]uint8
+}
+
+// New returns an empty Sketch
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// Add adds a uniformly distributed hash to the sketch
+func (s *Sketch) Add(hash uint64) {
+	idx := hash >> (64 - precision)
+	// The guard bit bounds the rank when the remaining bits are all zeros
+	rank := uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1))) + 1
+
+	if rank > s.regs[idx] {
+		s.regs[idx] = rank
+	}
+}
+
+// Merge adds the hashes of other to the sketch
+func (s *Sketch) Merge(other *Sketch) {
+	for i, r := range other.regs {
+		if r > s.regs[i] {
+			s.regs[i] = r
+		}
+	}
+}
+
+// Count returns the estimated number of distinct hashes added to the sketch
+func (s *Sketch) Count() uint64 {
+	m := float64(registers)
+
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range s.regs {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	est := 0.7213 / (1 + 1.079/m) * m * m / sum
+
+	// Small cardinalities are estimated more accurately by linear counting
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(est + 0.5)
+}
+
+// MarshalBinary encodes the sketch, listing the registers set if they are few
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	var set int
+	for _, r := range s.regs {
+		if r != 0 {
+			set++
+		}
+	}
+
+	if 3*set >= registers {
+		buf := make([]byte, 2, 2+registers)
+		buf[0], buf[1] = precision, encodingDense
+		return append(buf, s.regs[:]...), nil
+	}
+
+	buf := make([]byte, 2, 2+3*set)
+	buf[0], buf[1] = precision, encodingSparse
+	for i, r := range s.regs {
+		if r != 0 {
+			buf = append(buf, byte(i>>8), byte(i), r)
+		}
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || data[0] != precision {
+		return errInvalidSketch
+	}
+
+	var regs [registers]uint8
+	switch body := data[2:]; data[1] {
+	case encodingDense:
+		if len(body) != registers {
+			return errInvalidSketch
+		}
+		copy(regs[:], body)
+	case encodingSparse:
+		if len(body)%3 != 0 {
+			return errInvalidSketch
+		}
+		for i := 0; i < len(body); i += 3 {
+			idx := binary.BigEndian.Uint16(body[i:])
+			if idx >= registers {
+				return errInvalidSketch
+			}
+			regs[idx] = body[i+2]
+		}
+	default:
+		return errInvalidSketch
+	}
+
+	for _, r := range regs {
+		if r > 64-precision+1 {
+			return errInvalidSketch
+		}
+	}
+
+	s.regs = regs
+	return nil
+}
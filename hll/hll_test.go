@@ -0,0 +1,103 @@
+package hll
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// hash returns a uniformly distributed hash of i
+func hash(i int) uint64 {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(i))
+	sum := sha256.Sum256(b[:])
+	return binary.BigEndian.Uint64(sum[:])
+}
+
+func TestSketch_Count(t *testing.T) {
+	var testCases = []struct {
+		distinct  int
+		tolerance float64
+	}{
+		{0, 0},
+		{1, 0},
+		{10, 0},
+		{1000, 0.02},
+		{100000, 0.05},
+	}
+
+	for _, tc := range testCases {
+		s := New()
+		for i := 0; i < tc.distinct; i++ {
+			s.Add(hash(i))
+			// Duplicates are not counted
+			s.Add(hash(i))
+		}
+
+		got := float64(s.Count())
+		if err := math.Abs(got-float64(tc.distinct)) / math.Max(1, float64(tc.distinct)); err > tc.tolerance {
+			t.Errorf("got count %.0f for %d distinct hashes, want error below %.2f", got, tc.distinct, tc.tolerance)
+		}
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, b, union := New(), New(), New()
+	for i := 0; i < 3000; i++ {
+		a.Add(hash(i))
+		union.Add(hash(i))
+	}
+	for i := 2000; i < 5000; i++ {
+		b.Add(hash(i))
+		union.Add(hash(i))
+	}
+
+	a.Merge(b)
+	if got, want := a.Count(), union.Count(); got != want {
+		t.Errorf("got merged count %d, want %d", got, want)
+	}
+}
+
+func TestSketch_MarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 10, 100000} {
+		s := New()
+		for i := 0; i < n; i++ {
+			s.Add(hash(i))
+		}
+
+		data, err := s.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := New()
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if got.regs != s.regs {
+			t.Errorf("got different registers after decoding a sketch of %d hashes", n)
+		}
+
+		if n == 10 && len(data) != 2+3*10 {
+			t.Errorf("got %d bytes for a sketch of 10 hashes, want a sparse encoding", len(data))
+		}
+	}
+}
+
+func TestSketch_UnmarshalBinaryInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{precision + 1, encodingSparse},
+		{precision, encodingDense, 1, 2},
+		{precision, encodingSparse, 0xff, 0xff, 1},
+		{precision, encodingSparse, 0, 1, 64},
+		bytes.Repeat([]byte{precision}, 3),
+	} {
+		if err := New().UnmarshalBinary(data); err != errInvalidSketch {
+			t.Errorf("got error %v decoding %v, want %v", err, data, errInvalidSketch)
+		}
+	}
+}
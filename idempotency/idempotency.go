@@ -0,0 +1,237 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+const (
+	// Header carries the key chosen by the client for a request and its retries
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from a previous request
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// TTL is how long responses are kept for retries
+	TTL = 24 * time.Hour
+
+	maxKeyLength = 255
+)
+
+var (
+	errKeyInvalid    = e.New("idempotency_key_invalid", "idempotency key must be 1 to 255 characters")
+	errKeyReused     = e.New("idempotency_key_reused", "idempotency key was already used for a different request")
+	errKeyInProgress = e.New("idempotency_key_in_progress", "a request with this idempotency key is in progress")
+)
+
+// Handler wraps h so that requests carrying an Idempotency-Key header are executed once per user
+// and key. Retries replay the stored response, and reusing a key for a different request returns 422.
+// Server errors are not stored so that the request can be retried, nor are panics of h.
+func Handler(db *sql.DB, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			e.WriteError(w, http.StatusBadRequest, errKeyInvalid)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		userID := middleware.CtxReqUserID(ctx)
+		fp := fingerprint(r, body)
+
+		reserved, err := reserve(ctx, db, userID, key, fp)
+		if err != nil {
+			e.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !reserved {
+			replay(ctx, w, db, userID, key, fp)
+			return
+		}
+
+		// Release the key if h panics, so that retries are not reported in progress until it expires
+		defer func() {
+			if p := recover(); p != nil {
+				if err := release(context.Background(), db, userID, key); err != nil {
+					log.Printf("idempotency key %q: %v", key, err)
+				}
+				panic(p)
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		// Use a fresh context, the response must be stored even if the client went away
+		if rec.status >= http.StatusInternalServerError {
+			err = release(context.Background(), db, userID, key)
+		} else {
+			err = store(context.Background(), db, userID, key, rec)
+		}
+
+		if err != nil {
+			log.Printf("idempotency key %q: %v", key, err)
+		}
+	})
+}
+
+// fingerprint identifies the request, a key can only be reused for the same request
+func fingerprint(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	for _, s := range []string{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get(middleware.ProfileHeader)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+
+	return h.Sum(nil)
+}
+
+// reserve records the key as in progress, reporting false if the key is already in use.
+// Expired keys are taken over.
+func reserve(ctx context.Context, db *sql.DB, userID, key string, fp []byte) (bool, error) {
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, expire_at)
+		VALUES ($1, $2, $3, $4)
+		    ON CONFLICT (user_id, key) DO UPDATE
+		   SET fingerprint = EXCLUDED.fingerprint,
+		       status = NULL,
+		       content_type = NULL,
+		       etag = NULL,
+		       location = NULL,
+		       body = NULL,
+		       expire_at = EXCLUDED.expire_at
+		 WHERE idempotency_keys.expire_at <= NOW()
+		`, userID, key, fp, time.Now().Add(TTL))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// replay writes the response stored for the key
+func replay(ctx context.Context, w http.ResponseWriter, db *sql.DB, userID, key string, fp []byte) {
+	var (
+		stored      []byte
+		status      sql.NullInt64
+		contentType sql.NullString
+		etag        sql.NullString
+		location    sql.NullString
+		body        []byte
+	)
+
+	err := db.QueryRowContext(ctx, `
+		SELECT fingerprint,
+		       status,
+		       content_type,
+		       etag,
+		       location,
+		       body
+		  FROM idempotency_keys
+		 WHERE user_id = $1
+		   AND key = $2
+		`, userID, key).Scan(&stored, &status, &contentType, &etag, &location, &body)
+
+	switch {
+	case err == sql.ErrNoRows:
+		// Released by a failed request in the meantime
+		w.Header().Set("Retry-After", "1")
+		e.WriteError(w, http.StatusConflict, errKeyInProgress)
+	case err != nil:
+		e.WriteError(w, http.StatusInternalServerError, err)
+	case !bytes.Equal(stored, fp):
+		e.WriteError(w, http.StatusUnprocessableEntity, errKeyReused)
+	case !status.Valid:
+		w.Header().Set("Retry-After", "1")
+		e.WriteError(w, http.StatusConflict, errKeyInProgress)
+	default:
+		for name, v := range map[string]sql.NullString{"Content-Type": contentType, "ETag": etag, "Location": location} {
+			if v.String != "" {
+				w.Header().Set(name, v.String)
+			}
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(int(status.Int64))
+		w.Write(body)
+	}
+}
+
+// store records the response to the request, with the headers needed to replay it
+func store(ctx context.Context, db *sql.DB, userID, key string, rec *recorder) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE idempotency_keys
+		   SET status = $3,
+		       content_type = $4,
+		       etag = $5,
+		       location = $6,
+		       body = $7
+		 WHERE user_id = $1
+		   AND key = $2
+		`, userID, key, rec.status, rec.Header().Get("Content-Type"), rec.Header().Get("ETag"),
+		rec.Header().Get("Location"), rec.body.Bytes())
+	return err
+}
+
+func release(ctx context.Context, db *sql.DB, userID, key string) error {
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		 WHERE user_id = $1
+		   AND key = $2
+		`, userID, key)
+	return err
+}
+
+// Purge deletes the expired keys
+func Purge(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expire_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// recorder writes the response through while keeping a copy of it
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher and deadlines of the response
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
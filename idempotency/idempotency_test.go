@@ -0,0 +1,226 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+var userID = "fac90185-d243-46f5-8797-e57ac9c2c293"
+
+func TestHandler(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	payload := `{"type":"classic","title":"first link"}`
+	fp := fingerprint(httptest.NewRequest("POST", "https://linktree.com/api/links", nil), []byte(payload))
+
+	reserved := func(n int64) {
+		mock.ExpectExec("INSERT INTO idempotency_keys").WithArgs(userID, "key-1", fp, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, n))
+	}
+
+	storedRow := func(status, contentType, body interface{}) {
+		var etag, location interface{}
+		if status != nil {
+			etag, location = `"1"`, "/api/links/1"
+		}
+		rows := sqlmock.NewRows([]string{"fingerprint", "status", "content_type", "etag", "location", "body"}).
+			AddRow(fp, status, contentType, etag, location, body)
+		mock.ExpectQuery("SELECT fingerprint").WithArgs(userID, "key-1").WillReturnRows(rows)
+	}
+
+	var testCases = []struct {
+		name         string
+		key          string
+		payload      string
+		handlerCode  int
+		dbTx         func()
+		wantStatus   int
+		wantBody     string
+		wantReplayed string
+		wantLocation string
+		wantCalls    int
+	}{
+		{
+			name:         "Without key",
+			payload:      payload,
+			handlerCode:  http.StatusCreated,
+			wantStatus:   http.StatusCreated,
+			wantBody:     `{"id":"1"}`,
+			wantLocation: "/api/links/1",
+			wantCalls:    1,
+		},
+		{
+			name:        "First request",
+			key:         "key-1",
+			payload:     payload,
+			handlerCode: http.StatusCreated,
+			dbTx: func() {
+				reserved(1)
+				mock.ExpectExec("UPDATE idempotency_keys").
+					WithArgs(userID, "key-1", http.StatusCreated, "application/json", `"1"`, "/api/links/1", []byte(`{"id":"1"}`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantStatus:   http.StatusCreated,
+			wantBody:     `{"id":"1"}`,
+			wantLocation: "/api/links/1",
+			wantCalls:    1,
+		},
+		{
+			name:    "Retry",
+			key:     "key-1",
+			payload: payload,
+			dbTx: func() {
+				reserved(0)
+				storedRow(http.StatusCreated, "application/json", []byte(`{"id":"1"}`))
+			},
+			wantStatus:   http.StatusCreated,
+			wantBody:     `{"id":"1"}`,
+			wantReplayed: "true",
+			wantLocation: "/api/links/1",
+		},
+		{
+			name:    "Retry while in progress",
+			key:     "key-1",
+			payload: payload,
+			dbTx: func() {
+				reserved(0)
+				storedRow(nil, nil, nil)
+			},
+			wantStatus: http.StatusConflict,
+			wantBody:   e.JSONError(http.StatusConflict, errKeyInProgress),
+		},
+		{
+			name:    "Key reused with a different body",
+			key:     "key-1",
+			payload: `{"type":"classic","title":"second link"}`,
+			dbTx: func() {
+				mock.ExpectExec("INSERT INTO idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 0))
+				storedRow(http.StatusCreated, "application/json", []byte(`{"id":"1"}`))
+			},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   e.JSONError(http.StatusUnprocessableEntity, errKeyReused),
+		},
+		{
+			name:        "Server errors are not stored",
+			key:         "key-1",
+			payload:     payload,
+			handlerCode: http.StatusInternalServerError,
+			dbTx: func() {
+				reserved(1)
+				mock.ExpectExec("DELETE FROM idempotency_keys").WithArgs(userID, "key-1").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantStatus:   http.StatusInternalServerError,
+			wantBody:     `{"id":"1"}`,
+			wantLocation: "/api/links/1",
+			wantCalls:    1,
+		},
+		{
+			name:       "Key too long",
+			key:        strings.Repeat("k", 256),
+			payload:    payload,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   e.JSONError(http.StatusBadRequest, errKeyInvalid),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			var calls int
+			h := Handler(db, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"1"`)
+				w.Header().Set("Location", "/api/links/1")
+				w.WriteHeader(tc.handlerCode)
+				fmt.Fprint(w, `{"id":"1"}`)
+			}))
+
+			req := httptest.NewRequest("POST", "https://linktree.com/api/links", strings.NewReader(tc.payload))
+			req.Header.Set(Header, tc.key)
+			req = middleware.CtxSetUserID(req.Context(), req, userID)
+			recorder := httptest.NewRecorder()
+
+			h.ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Body.String(); got != tc.wantBody {
+				t.Errorf("got body %s, want %s", got, tc.wantBody)
+			}
+
+			if got := recorder.Header().Get(ReplayedHeader); got != tc.wantReplayed {
+				t.Errorf("got replayed header '%s', want '%s'", got, tc.wantReplayed)
+			}
+
+			if got := recorder.Header().Get("Location"); got != tc.wantLocation {
+				t.Errorf("got Location '%s', want '%s'", got, tc.wantLocation)
+			}
+
+			if tc.wantLocation != "" {
+				if got, want := recorder.Header().Get("ETag"), `"1"`; got != want {
+					t.Errorf("got ETag '%s', want '%s'", got, want)
+				}
+			}
+
+			if calls != tc.wantCalls {
+				t.Errorf("got %d handler calls, want %d", calls, tc.wantCalls)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestHandler_Panic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO idempotency_keys").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM idempotency_keys").WithArgs(userID, "key-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	h := Handler(db, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+
+	req := httptest.NewRequest("POST", "https://linktree.com/api/links", strings.NewReader(`{}`))
+	req.Header.Set(Header, "key-1")
+	req = middleware.CtxSetUserID(req.Context(), req, userID)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "handler failed" {
+				t.Errorf("got panic %v, want the panic of the handler", p)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
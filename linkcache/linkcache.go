@@ -0,0 +1,161 @@
+package linkcache
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxUsers is the number of cached users above which expired entries are swept on insert
+	maxUsers = 10000
+	// maxEntries is the number of entries kept per user, above which the oldest is dropped
+	maxEntries = 16
+)
+
+// Entry is a rendered list of links with its validators
+type Entry struct {
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+}
+
+type userEntries struct {
+	entries     map[string]cachedEntry
+	invalidated uint64
+}
+
+type cachedEntry struct {
+	Entry
+	expireAt time.Time
+}
+
+// Cache keeps the rendered links of each user in memory, keyed by their order, up to maxEntries per user.
+// Entries are invalidated on writes to the user's links, and expire after the ttl
+// to pick up writes handled by other servers.
+// A nil Cache caches nothing.
+type Cache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.Mutex
+	seq   uint64
+	users map[string]*userEntries
+	// swept is the generation of the last sweep, which forgets the invalidations before it
+	swept uint64
+}
+
+// New returns an empty Cache
+func New(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:   ttl,
+		now:   time.Now,
+		users: make(map[string]*userEntries),
+	}
+}
+
+// Get returns the entry for the user and key, if any.
+// On a miss, the returned generation must be passed to Set along with the new entry.
+func (c *Cache) Get(userID, key string) (Entry, uint64, bool) {
+	if c == nil {
+		return Entry{}, 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	u, ok := c.users[userID]
+	if !ok {
+		return Entry{}, c.seq, false
+	}
+
+	e, ok := u.entries[key]
+	if !ok || !c.now().Before(e.expireAt) {
+		return Entry{}, c.seq, false
+	}
+
+	return e.Entry, c.seq, true
+}
+
+// Set stores the entry for the user and key, unless the user's links were
+// written since the generation was returned by Get.
+func (c *Cache) Set(userID, key string, gen uint64, e Entry) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	u, ok := c.users[userID]
+	if !ok {
+		if gen < c.swept {
+			return
+		}
+		if len(c.users) >= maxUsers {
+			c.sweep(now)
+		}
+		u = &userEntries{entries: make(map[string]cachedEntry)}
+		c.users[userID] = u
+	}
+
+	if u.invalidated > gen {
+		return
+	}
+
+	if _, ok := u.entries[key]; !ok && len(u.entries) >= maxEntries {
+		u.evict()
+	}
+	u.entries[key] = cachedEntry{Entry: e, expireAt: now.Add(c.ttl)}
+}
+
+// evict drops the entry of the user which expires first, the oldest
+func (u *userEntries) evict() {
+	var (
+		oldest   string
+		expireAt time.Time
+	)
+	for key, e := range u.entries {
+		if expireAt.IsZero() || e.expireAt.Before(expireAt) {
+			oldest, expireAt = key, e.expireAt
+		}
+	}
+	delete(u.entries, oldest)
+}
+
+// Invalidate drops the entries of the user, and any being computed
+func (c *Cache) Invalidate(userID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	u, ok := c.users[userID]
+	if !ok {
+		u = &userEntries{}
+		c.users[userID] = u
+	}
+	u.entries = make(map[string]cachedEntry)
+	u.invalidated = c.seq
+}
+
+// sweep drops the users without live entries
+func (c *Cache) sweep(now time.Time) {
+	for userID, u := range c.users {
+		live := false
+		for _, e := range u.entries {
+			if now.Before(e.expireAt) {
+				live = true
+				break
+			}
+		}
+
+		if !live {
+			delete(c.users, userID)
+		}
+	}
+	c.swept = c.seq
+}
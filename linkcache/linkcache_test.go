@@ -0,0 +1,84 @@
+package linkcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	c := New(time.Minute)
+	c.now = func() time.Time { return now }
+
+	entry := Entry{Body: []byte("[]"), ETag: `"e1"`}
+
+	_, gen, ok := c.Get("user1", "sort_by=title")
+	if ok {
+		t.Fatalf("got a hit on an empty cache, want a miss")
+	}
+	c.Set("user1", "sort_by=title", gen, entry)
+
+	if got, _, ok := c.Get("user1", "sort_by=title"); !ok || got.ETag != entry.ETag {
+		t.Errorf("got entry %+v and hit %t, want %+v", got, ok, entry)
+	}
+
+	if _, _, ok := c.Get("user1", ""); ok {
+		t.Errorf("got a hit for other query parameters, want a miss")
+	}
+
+	if _, _, ok := c.Get("user2", "sort_by=title"); ok {
+		t.Errorf("got a hit for another user, want a miss")
+	}
+
+	// A write while the links were read discards them
+	_, gen, _ = c.Get("user2", "")
+	c.Invalidate("user2")
+	c.Set("user2", "", gen, entry)
+	if _, _, ok := c.Get("user2", ""); ok {
+		t.Errorf("got a hit for links read before a write, want a miss")
+	}
+
+	c.Invalidate("user1")
+	if _, _, ok := c.Get("user1", "sort_by=title"); ok {
+		t.Errorf("got a hit after invalidation, want a miss")
+	}
+
+	_, gen, _ = c.Get("user1", "sort_by=title")
+	c.Set("user1", "sort_by=title", gen, entry)
+	now = now.Add(time.Minute)
+	if _, _, ok := c.Get("user1", "sort_by=title"); ok {
+		t.Errorf("got a hit after the ttl, want a miss")
+	}
+
+	var disabled *Cache
+	disabled.Set("user1", "", 0, entry)
+	disabled.Invalidate("user1")
+	if _, _, ok := disabled.Get("user1", ""); ok {
+		t.Errorf("got a hit on a nil cache, want a miss")
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	c := New(time.Minute)
+	c.now = func() time.Time { return now }
+
+	for i := 0; i <= maxEntries; i++ {
+		_, gen, _ := c.Get("user1", fmt.Sprintf("sort_by=%d", i))
+		c.Set("user1", fmt.Sprintf("sort_by=%d", i), gen, Entry{})
+		now = now.Add(time.Second)
+	}
+
+	if got := len(c.users["user1"].entries); got != maxEntries {
+		t.Errorf("got %d entries, want %d", got, maxEntries)
+	}
+
+	if _, _, ok := c.Get("user1", "sort_by=0"); ok {
+		t.Errorf("got a hit for the oldest entry, want a miss")
+	}
+
+	if _, _, ok := c.Get("user1", fmt.Sprintf("sort_by=%d", maxEntries)); !ok {
+		t.Errorf("got a miss for the newest entry, want a hit")
+	}
+}
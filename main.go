@@ -1,24 +1,61 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/stdlib"
 
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+	"github.com/alessio-palumbo/linktree-challenge/clicks"
 	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/links"
+	"github.com/alessio-palumbo/linktree-challenge/idempotency"
+	"github.com/alessio-palumbo/linktree-challenge/linkcache"
 	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/resolver"
+	"github.com/alessio-palumbo/linktree-challenge/screening"
 	"github.com/alessio-palumbo/linktree-challenge/server"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
 	"github.com/alessio-palumbo/linktree-challenge/validator"
 )
 
 var (
 	port     = flag.Int("port", 8080, "port")
 	dbSource = flag.String("db_source", "dbname=linktree-dev sslmode=disable", "Db")
+
+	resolverURL      = flag.String("resolver_url", "https://api.song.link/v1-alpha.1/links", "Music links resolver service")
+	resolverCacheTTL = flag.Duration("resolver_cache_ttl", 24*time.Hour, "Music links resolver cache ttl")
+
+	blocklist          = flag.String("blocklist", "", "Url screening rules file")
+	screenMaxRedirects = flag.Int("screen_max_redirects", 3, "Redirects followed when screening urls")
+	screenTimeout      = flag.Duration("screen_timeout", 5*time.Second, "Longest time spent screening the urls of a link")
+
+	tokenHashKey  = flag.String("token_hash_key", "", "File holding the key used to hash stored tokens (required)")
+	jwtKeys       = flag.String("jwt_keys", "", "JWT keys file, enables JWT bearer tokens")
+	jwtRevocation = flag.Bool("jwt_revocation_check", false, "Check JWTs against user_tokens for revocation")
+
+	requireIfMatch = flag.Bool("require_if_match", true, "Reject link updates and deletes without an If-Match header")
+	linksCacheTTL  = flag.Duration("links_cache_ttl", time.Minute, "Links index cache ttl, 0 disables the cache")
+	trashRetention = flag.Duration("trash_retention", 30*24*time.Hour, "Time deleted links are kept in the trash")
+
+	clickBatchSize     = flag.Int("click_batch_size", 500, "Clicks written to the db at a time")
+	clickFlushInterval = flag.Duration("click_flush_interval", time.Second, "Longest time clicks are queued before being written")
+	rollupInterval     = flag.Duration("rollup_interval", time.Minute, "Time between rollups of the clicks for analytics")
+
+	publicURL = flag.String("public_url", "", "Base url of the public profiles and redirects, http://localhost:{port} by default")
+
+	shutdownTimeout = flag.Duration("shutdown_timeout", 30*time.Second, "Longest time requests in flight are waited for on shutdown")
+
 	maxDBC   = 5
 	nWorkers = 1
 	apiURL   = "http://linktr.ee/api"
@@ -42,12 +79,109 @@ func main() {
 	pool.SetMaxIdleConns(10)
 	pool.SetMaxOpenConns(10)
 
+	// Initialise music links resolver with a cache in front of the service
+	res := resolver.NewCache(
+		resolver.NewHTTP(*resolverURL, &http.Client{Timeout: 3 * time.Second}),
+		*resolverCacheTTL,
+	)
+
+	// Load url screening rules if provided
+	var scr screening.Screener
+	if *blocklist != "" {
+		rules, err := screening.LoadRules(*blocklist)
+		if err != nil {
+			log.Fatalf("Failed to load blocklist: %v", err)
+		}
+		scr = screening.New(rules, *screenMaxRedirects, *screenTimeout, nil)
+	}
+
+	// Tokens are stored as their keyed hash
+	hasher, err := tokens.LoadHasher(*tokenHashKey)
+	if err != nil {
+		log.Fatalf("Failed to load token hash key: %v", err)
+	}
+
+	// Authentication events and changes are recorded in the audit log, written in batches
+	auditLog := audit.New(pool, 100, time.Second)
+
+	// Issue JWT access tokens when the key set has a signing key, opaque tokens otherwise
+	auth := middleware.NewAuth(pool, hasher).WithAudit(auditLog)
+	var keys *tokens.KeySet
+	if *jwtKeys != "" {
+		ks, err := tokens.LoadKeySet(*jwtKeys)
+		if err != nil {
+			log.Fatalf("Failed to load jwt keys: %v", err)
+		}
+		auth = auth.WithJWT(ks, *jwtRevocation)
+		if ks.CanSign() {
+			keys = ks
+		}
+	}
+
+	// Cache the links index of each user, invalidated on writes
+	var linksCache *linkcache.Cache
+	if *linksCacheTTL > 0 {
+		linksCache = linkcache.New(*linksCacheTTL)
+	}
+
+	// Clicks on redirected links are written in batches, within the 65535 parameters allowed in a statement
+	if maxBatch := 65535 / clicks.ColumnsPerClick; *clickBatchSize < 1 || *clickBatchSize > maxBatch {
+		log.Fatalf("click_batch_size must be between 1 and %d", maxBatch)
+	}
+	if *clickFlushInterval <= 0 {
+		log.Fatal("click_flush_interval must be positive")
+	}
+	clickRecorder := clicks.NewRecorder(pool, *clickBatchSize, *clickFlushInterval)
+
+	// Absolute urls of public pages are built from a fixed base, never from the Host of requests
+	if *publicURL == "" {
+		*publicURL = fmt.Sprintf("http://localhost:%d", *port)
+	}
+
 	g := handlers.Group{
 		DB:        pool,
-		Auth:      middleware.NewAuth(pool),
+		Auth:      auth,
 		Validator: validator.New(),
+		Resolver:  res,
+		Screener:  scr,
+		Keys:      keys,
+		Hasher:    hasher,
+
+		RequireIfMatch: *requireIfMatch,
+		LinksCache:     linksCache,
+		Audit:          auditLog,
+		Clicks:         clickRecorder,
+		Visitors:       clicks.NewVisitors(pool),
+		PublicURL:      strings.TrimSuffix(*publicURL, "/"),
 	}
 
+	// Purge expired idempotency keys
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := idempotency.Purge(context.Background(), pool); err != nil {
+				log.Printf("Failed to purge idempotency keys: %v", err)
+			}
+		}
+	}()
+
+	// Purge links deleted for longer than the retention period
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := links.PurgeTrash(context.Background(), pool, *trashRetention); err != nil {
+				log.Printf("Failed to purge deleted links: %v", err)
+			}
+		}
+	}()
+
+	// Roll up clicks for analytics, leaving time for the queued clicks to be written
+	go func() {
+		for range time.Tick(*rollupInterval) {
+			if _, err := clicks.RollUp(context.Background(), pool, *clickFlushInterval+time.Minute); err != nil {
+				log.Printf("Failed to roll up clicks: %v", err)
+			}
+		}
+	}()
+
 	// Start server
 	s := http.Server{
 		WriteTimeout: time.Second * 5,
@@ -56,5 +190,24 @@ func main() {
 		Handler:      server.New(g),
 	}
 
-	log.Fatal(s.ListenAndServe())
+	go func() {
+		if err := s.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// Stop on SIGINT or SIGTERM once the requests in flight complete, then write the queued
+	// clicks and audit events
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		log.Printf("Failed to wait for the requests in flight: %v", err)
+	}
+
+	clickRecorder.Close()
+	auditLog.Close()
 }
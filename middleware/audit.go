@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+)
+
+// Audit records the requests changing data in the audit log, with the profile they act on
+type Audit struct {
+	log *audit.Logger
+}
+
+// NewAudit returns a new Audit writing to log
+func NewAudit(log *audit.Logger) Audit {
+	return Audit{log: log}
+}
+
+// ServeHTTP implements the negroni.Handler interface, it must run after Profile
+func (a Audit) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		next(w, r)
+		return
+	}
+
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	next(sw, r)
+
+	ctx := r.Context()
+	a.log.Log(r, audit.Event{
+		UserID:   CtxProfileUserID(ctx),
+		ActorID:  CtxReqUserID(ctx),
+		Action:   audit.ActionRequestPrefix + strings.ToLower(r.Method),
+		Resource: r.URL.Path,
+		Status:   sw.status,
+	})
+}
+
+// statusWriter keeps the status of the response written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the flusher and deadlines of the response
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
+)
+
+func TestAudit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	artistID := "9bce575b-1507-4a0f-a523-4072a72fc968"
+
+	var testCases = []struct {
+		name      string
+		method    string
+		profileID string
+		dbTx      func()
+	}{
+		{
+			name:   "Read request",
+			method: "GET",
+		},
+		{
+			name:   "Change to the user's links",
+			method: "DELETE",
+			dbTx: func() {
+				mock.ExpectExec("INSERT INTO audit_events").
+					WithArgs(sqlmock.AnyArg(), userID, userID, "api.delete", "/api/links/l1", http.StatusNoContent, "192.0.2.1", nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name:      "Change to a shared profile",
+			method:    "POST",
+			profileID: artistID,
+			dbTx: func() {
+				mock.ExpectExec("INSERT INTO audit_events").
+					WithArgs(sqlmock.AnyArg(), artistID, userID, "api.post", "/api/links/l1", http.StatusNoContent, "192.0.2.1", nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+	}
+
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest(tc.method, "https://example.com/api/links/l1", nil)
+			req = CtxSetUserID(req.Context(), req, userID)
+			if tc.profileID != "" {
+				req = CtxSetProfile(req.Context(), req, tc.profileID, RoleEditor)
+			}
+			recorder := httptest.NewRecorder()
+
+			l := audit.New(db, 1, time.Hour)
+			NewAudit(l).ServeHTTP(recorder, req, next)
+			l.Close()
+
+			if got, want := recorder.Code, http.StatusNoContent; got != want {
+				t.Errorf("got status %d, want %d", got, want)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
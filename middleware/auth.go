@@ -3,32 +3,62 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/alessio-palumbo/linktree-challenge/audit"
 	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
 )
 
 const (
 	bearerPrefix = "Bearer "
 	authHeader   = "Authorization"
+)
 
-	errTokenMissing = "missing token in request headers"
-	errTokenInvalid = "request token is invalid"
+var (
+	errTokenMissing = e.New("token_missing", "missing token in request headers")
+	errTokenInvalid = e.New("token_invalid", "request token is invalid")
+	errTokenExpired = e.New("token_expired", "request token is expired")
+	errIPNotAllowed = e.New("ip_not_allowed", "api key is not allowed from this address")
 )
 
 type Auth struct {
-	db *sql.DB
+	db     *sql.DB
+	hasher tokens.Hasher
+
+	keys            *tokens.KeySet
+	checkRevocation bool
+
+	audit *audit.Logger
+}
+
+// NewAuth returns a new Auth with a db pool, matching opaque tokens by their keyed hash
+func NewAuth(db *sql.DB, hasher tokens.Hasher) Auth {
+	return Auth{db: db, hasher: hasher}
+}
+
+// WithJWT returns a copy of Auth which also accepts JWTs signed by any key of the set.
+// JWTs are validated locally unless checkRevocation is set, in which case their jti must
+// still match the id of a valid row of user_tokens.
+func (a Auth) WithJWT(keys *tokens.KeySet, checkRevocation bool) Auth {
+	a.keys = keys
+	a.checkRevocation = checkRevocation
+	return a
 }
 
-// NewAuth returns a new Auth with a db pool
-func NewAuth(db *sql.DB) Auth {
-	return Auth{db: db}
+// WithAudit returns a copy of Auth which records the use of tokens in the audit log
+func (a Auth) WithAudit(log *audit.Logger) Auth {
+	a.audit = log
+	return a
 }
 
 // ServeHTTP implements the negroni.Handler interface
-// TODO this could be using a jwt token containing the userID and any other data needed,
-// while using the db tokens table to check the expiry timestamp.
 func (a Auth) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	token := a.requestToken(r)
 
@@ -37,19 +67,62 @@ func (a Auth) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.Handle
 		return
 	}
 
-	ctx := r.Context()
-	userID, err := a.authorize(ctx, token)
+	var (
+		ctx    = r.Context()
+		userID string
+		scopes []string
+		kind   string
+		err    error
+	)
+
+	switch {
+	case tokens.IsAPIKey(token):
+		kind = "api_key"
+		userID, scopes, err = a.authorizeAPIKey(ctx, token, remoteIP(r))
+	case a.keys != nil && tokens.IsJWT(token):
+		kind = "jwt"
+		userID, err = a.authorizeJWT(ctx, token)
+	default:
+		kind = "token"
+		userID, err = a.authorize(ctx, token)
+	}
+
 	if err != nil {
+		status, reason := http.StatusInternalServerError, err
 		switch err {
-		case sql.ErrNoRows:
-			e.WriteError(w, http.StatusUnauthorized, errTokenInvalid)
-		default:
-			e.WriteError(w, http.StatusInternalServerError, err)
+		case sql.ErrNoRows, errTokenInvalid:
+			status, reason = http.StatusUnauthorized, errTokenInvalid
+		case errTokenExpired:
+			status = http.StatusUnauthorized
+		case errIPNotAllowed:
+			status = http.StatusForbidden
 		}
+
+		// Rejections are recorded once per window for each client address and reason
+		code := e.NewProblem(status, reason).Code
+		a.audit.LogSampled(r, audit.ActionTokenRejected+":"+audit.RemoteIP(r)+":"+code, audit.Event{
+			Action:   audit.ActionTokenRejected,
+			Resource: r.URL.Path,
+			Status:   status,
+			Metadata: map[string]string{"kind": kind, "reason": code},
+		})
+		e.WriteError(w, status, reason)
 		return
 	}
 
+	// Uses are recorded once per window for each token, keyed by its hash
+	a.audit.LogSampled(r, audit.ActionTokenUsed+":"+string(a.hasher.Hash(token)), audit.Event{
+		UserID:   userID,
+		ActorID:  userID,
+		Action:   audit.ActionTokenUsed,
+		Resource: r.URL.Path,
+		Metadata: map[string]string{"kind": kind},
+	})
+
 	r = CtxSetUserID(ctx, r, userID)
+	if scopes != nil {
+		r = CtxSetScopes(r.Context(), r, scopes)
+	}
 
 	next(w, r)
 }
@@ -65,7 +138,43 @@ func (a Auth) requestToken(r *http.Request) string {
 	return token
 }
 
+// authorize returns the owner of an opaque token, stored as its keyed hash
 func (a Auth) authorize(ctx context.Context, token string) (string, error) {
+	id, err := uuid.Parse(token)
+	if err != nil {
+		return "", errTokenInvalid
+	}
+
+	// Hash the canonical form, as tokens are issued and stored lowercase
+	token = id.String()
+
+	stmt := `
+		SELECT user_id,
+		       token_hash
+		  FROM user_tokens
+		 WHERE token_hash = $1
+		   AND expire_at > NOW()
+	`
+
+	var (
+		userID string
+		hash   []byte
+	)
+
+	err = a.db.QueryRowContext(ctx, stmt, a.hasher.Hash(token)).Scan(&userID, &hash)
+	if err != nil {
+		return "", err
+	}
+
+	if !a.hasher.Equal(token, hash) {
+		return "", errTokenInvalid
+	}
+
+	return userID, nil
+}
+
+// authorizeID returns the owner of the valid token with the given id
+func (a Auth) authorizeID(ctx context.Context, id string) (string, error) {
 	stmt := `
 		SELECT user_id
 		  FROM user_tokens
@@ -74,6 +183,111 @@ func (a Auth) authorize(ctx context.Context, token string) (string, error) {
 	`
 
 	var userID string
-	err := a.db.QueryRowContext(ctx, stmt, token).Scan(&userID)
+	err := a.db.QueryRowContext(ctx, stmt, id).Scan(&userID)
 	return userID, err
 }
+
+// authorizeJWT verifies the JWT and returns its subject
+func (a Auth) authorizeJWT(ctx context.Context, token string) (string, error) {
+	claims, err := a.keys.Verify(token, time.Now())
+	switch err {
+	case nil:
+	case tokens.ErrExpired:
+		return "", errTokenExpired
+	default:
+		return "", errTokenInvalid
+	}
+
+	if !a.checkRevocation {
+		return claims.Subject, nil
+	}
+
+	if _, err := uuid.Parse(claims.ID); err != nil {
+		return "", errTokenInvalid
+	}
+
+	// Revoked tokens are removed from user_tokens
+	userID, err := a.authorizeID(ctx, claims.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if userID != claims.Subject {
+		return "", errTokenInvalid
+	}
+
+	return userID, nil
+}
+
+// authorizeAPIKey returns the owner and scopes of a valid API key, checking its allow-list against ip
+func (a Auth) authorizeAPIKey(ctx context.Context, key string, ip net.IP) (string, []string, error) {
+	stmt := `
+		SELECT user_id,
+		       token_hash,
+		       scopes,
+		       allowed_ips
+		  FROM api_keys
+		 WHERE token_hash = $1
+		   AND (expire_at IS NULL OR expire_at > NOW())
+	`
+
+	var (
+		userID             string
+		hash               []byte
+		scopes, allowedIPs []byte
+	)
+
+	err := a.db.QueryRowContext(ctx, stmt, a.hasher.Hash(key)).Scan(&userID, &hash, &scopes, &allowedIPs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !a.hasher.Equal(key, hash) {
+		return "", nil, errTokenInvalid
+	}
+
+	var granted, cidrs []string
+	if err := json.Unmarshal(scopes, &granted); err != nil {
+		return "", nil, err
+	}
+	if err := json.Unmarshal(allowedIPs, &cidrs); err != nil {
+		return "", nil, err
+	}
+
+	if !ipAllowed(ip, cidrs) {
+		return "", nil, errIPNotAllowed
+	}
+
+	// Keys are always created with at least one scope, never leave them unrestricted
+	if granted == nil {
+		granted = []string{}
+	}
+
+	return userID, granted, nil
+}
+
+// ipAllowed reports whether ip belongs to any of the networks, an empty list allows any address
+func ipAllowed(ip net.IP, cidrs []string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+
+	for _, c := range cidrs {
+		_, network, err := net.ParseCIDR(c)
+		if err == nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteIP returns the address of the client connected to the server
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
@@ -7,11 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
 )
 
 var (
@@ -19,6 +21,9 @@ var (
 	invalidToken = "05de14de-537a-4819-814a-85ec6c66dd35"
 	userID       = "fac90185-d243-46f5-8797-e57ac9c2c293"
 
+	hasher, _ = tokens.NewHasher([]byte("0123456789abcdef0123456789abcdef"))
+	tokenHash = hasher.Hash(token)
+
 	expiredTimestamp = time.Now().UTC().Add(-1 * time.Minute)
 	validTimestamp   = time.Now().UTC().Add(24 * time.Hour)
 )
@@ -41,32 +46,45 @@ func TestAuth_ServeHTTP(t *testing.T) {
 		{
 			name:       "Missing Authorization header",
 			wantStatus: http.StatusUnauthorized,
-			wantErr:    e.JSONError(errTokenMissing),
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenMissing),
 		},
 		{
 			name:       "Missing token in Authorization",
 			headers:    map[string]string{"Authorization": "Bearer "},
 			wantStatus: http.StatusUnauthorized,
-			wantErr:    e.JSONError(errTokenMissing),
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenMissing),
 		},
 		{
 			name:       "Token not found",
 			headers:    map[string]string{"Authorization": fmt.Sprintf("Bearer %s", invalidToken)},
-			queryArgs:  []driver.Value{token, userID, expiredTimestamp},
+			queryArgs:  []driver.Value{hasher.Hash(invalidToken)},
 			wantStatus: http.StatusUnauthorized,
-			wantErr:    e.JSONError(errTokenInvalid),
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenInvalid),
 		},
 		{
 			name:       "Token expired",
 			headers:    map[string]string{"Authorization": fmt.Sprintf("Bearer %s", token)},
-			queryArgs:  []driver.Value{token, userID, expiredTimestamp},
+			queryArgs:  []driver.Value{tokenHash}, // filtered out by expire_at
 			wantStatus: http.StatusUnauthorized,
-			wantErr:    e.JSONError(errTokenInvalid),
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+		{
+			name:       "Malformed token",
+			headers:    map[string]string{"Authorization": "Bearer not-a-token"},
+			wantStatus: http.StatusUnauthorized,
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+		{
+			name:       "Uppercase token",
+			headers:    map[string]string{"Authorization": fmt.Sprintf("Bearer %s", strings.ToUpper(token))},
+			queryArgs:  []driver.Value{tokenHash, userID, validTimestamp},
+			wantStatus: http.StatusOK,
+			reqUID:     userID,
 		},
 		{
 			name:       "Token valid",
 			headers:    map[string]string{"Authorization": fmt.Sprintf("Bearer %s", token)},
-			queryArgs:  []driver.Value{token, userID, validTimestamp},
+			queryArgs:  []driver.Value{tokenHash, userID, validTimestamp},
 			wantStatus: http.StatusOK,
 			reqUID:     userID,
 		},
@@ -74,7 +92,9 @@ func TestAuth_ServeHTTP(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			populate(mock, tc.queryArgs)
+			if tc.queryArgs != nil {
+				populate(mock, tc.queryArgs)
+			}
 
 			url := url.URL{Scheme: "https", Host: "example.com", Path: "/api/links"}
 			req := httptest.NewRequest("GET", url.String(), nil)
@@ -85,7 +105,143 @@ func TestAuth_ServeHTTP(t *testing.T) {
 			recorder := httptest.NewRecorder()
 
 			var requestUserID string
-			NewAuth(db).ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+			NewAuth(db, hasher).ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				requestUserID = CtxReqUserID(r.Context())
+			})
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Body.String(); got != tc.wantErr {
+				t.Errorf("got error %s, want %s", got, tc.wantErr)
+			}
+
+			if got := requestUserID; got != tc.reqUID {
+				t.Errorf("got requesterID '%s', want '%s'", got, tc.reqUID)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAuth_ServeHTTPJWT(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	key, err := tokens.NewHMACKey("hs", []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := tokens.NewKeySet("hs", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(c tokens.Claims) string {
+		tok, err := keys.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + tok
+	}
+
+	validClaims := tokens.Claims{Subject: userID, ID: token, ExpiresAt: validTimestamp.Unix()}
+
+	var testCases = []struct {
+		name            string
+		header          string
+		checkRevocation bool
+		queryArgs       []driver.Value
+		wantStatus      int
+		wantErr         string
+		reqUID          string
+	}{
+		{
+			name:       "Valid JWT",
+			header:     sign(validClaims),
+			wantStatus: http.StatusOK,
+			reqUID:     userID,
+		},
+		{
+			name:       "Expired JWT",
+			header:     sign(tokens.Claims{Subject: userID, ExpiresAt: expiredTimestamp.Unix()}),
+			wantStatus: http.StatusUnauthorized,
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenExpired),
+		},
+		{
+			name:       "JWT with invalid signature",
+			header:     sign(validClaims) + "x",
+			wantStatus: http.StatusUnauthorized,
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+		{
+			name:            "Valid JWT not revoked",
+			header:          sign(validClaims),
+			checkRevocation: true,
+			queryArgs:       []driver.Value{token, userID, validTimestamp},
+			wantStatus:      http.StatusOK,
+			reqUID:          userID,
+		},
+		{
+			name:            "Revoked JWT",
+			header:          sign(validClaims),
+			checkRevocation: true,
+			queryArgs:       []driver.Value{token},
+			wantStatus:      http.StatusUnauthorized,
+			wantErr:         e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+		{
+			name:            "JWT with malformed id when checking revocation",
+			header:          sign(tokens.Claims{Subject: userID, ID: "not-an-id", ExpiresAt: validTimestamp.Unix()}),
+			checkRevocation: true,
+			wantStatus:      http.StatusUnauthorized,
+			wantErr:         e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+		{
+			name:            "JWT without id when checking revocation",
+			header:          sign(tokens.Claims{Subject: userID, ExpiresAt: validTimestamp.Unix()}),
+			checkRevocation: true,
+			wantStatus:      http.StatusUnauthorized,
+			wantErr:         e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+		{
+			name:            "JWT subject not matching token owner",
+			header:          sign(validClaims),
+			checkRevocation: true,
+			queryArgs:       []driver.Value{token, "9bce575b-1507-4a0f-a523-4072a72fc968", validTimestamp},
+			wantStatus:      http.StatusUnauthorized,
+			wantErr:         e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+		{
+			name:       "Opaque token alongside JWTs",
+			header:     fmt.Sprintf("Bearer %s", token),
+			queryArgs:  []driver.Value{tokenHash, userID, validTimestamp},
+			wantStatus: http.StatusOK,
+			reqUID:     userID,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.queryArgs != nil {
+				populate(mock, tc.queryArgs)
+			}
+
+			url := url.URL{Scheme: "https", Host: "example.com", Path: "/api/links"}
+			req := httptest.NewRequest("GET", url.String(), nil)
+			req.Header.Add("Authorization", tc.header)
+			recorder := httptest.NewRecorder()
+
+			var requestUserID string
+			NewAuth(db, hasher).WithJWT(keys, tc.checkRevocation).ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
 				requestUserID = CtxReqUserID(r.Context())
 			})
 
@@ -101,6 +257,9 @@ func TestAuth_ServeHTTP(t *testing.T) {
 				t.Errorf("got requesterID '%s', want '%s'", got, tc.reqUID)
 			}
 
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
 		})
 	}
 }
@@ -112,6 +271,112 @@ func populate(mock sqlmock.Sqlmock, qArgs []driver.Value) {
 		return
 	}
 
+	// Opaque tokens are looked up by hash, JWTs by id
 	rows := sqlmock.NewRows([]string{"user_id"}).AddRow(qArgs[1])
+	if hash, ok := qArgs[0].([]byte); ok {
+		rows = sqlmock.NewRows([]string{"user_id", "token_hash"}).AddRow(qArgs[1], hash)
+	}
 	mock.ExpectQuery(q).WithArgs(qArgs[0]).WillReturnRows(rows)
 }
+
+func TestAuth_ServeHTTPAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	apiKey := "lk_4tV0n2YQ8cJxk3m1HkzC9w6pQ7rS5uT2vW3xY4zA1bE"
+	keyHash := hasher.Hash(apiKey)
+
+	keyFound := func(allowedIPs string) func() {
+		return func() {
+			rows := sqlmock.NewRows([]string{"user_id", "token_hash", "scopes", "allowed_ips"}).
+				AddRow(userID, keyHash, []byte(`["links:read"]`), []byte(allowedIPs))
+			mock.ExpectQuery("SELECT user_id").WithArgs(keyHash).WillReturnRows(rows)
+		}
+	}
+
+	var testCases = []struct {
+		name       string
+		remoteAddr string
+		dbTx       func()
+		wantStatus int
+		wantErr    string
+		reqUID     string
+		wantScopes []string
+	}{
+		{
+			name:       "Valid API key",
+			remoteAddr: "203.0.113.7:51234",
+			dbTx:       keyFound(`[]`),
+			wantStatus: http.StatusOK,
+			reqUID:     userID,
+			wantScopes: []string{"links:read"},
+		},
+		{
+			name:       "Valid API key from an allowed address",
+			remoteAddr: "203.0.113.7:51234",
+			dbTx:       keyFound(`["198.51.100.0/24","203.0.113.7/32"]`),
+			wantStatus: http.StatusOK,
+			reqUID:     userID,
+			wantScopes: []string{"links:read"},
+		},
+		{
+			name:       "Valid API key from another address",
+			remoteAddr: "192.0.2.1:51234",
+			dbTx:       keyFound(`["203.0.113.0/24"]`),
+			wantStatus: http.StatusForbidden,
+			wantErr:    e.JSONError(http.StatusForbidden, errIPNotAllowed),
+		},
+		{
+			name:       "Unknown or expired API key",
+			remoteAddr: "203.0.113.7:51234",
+			dbTx: func() {
+				mock.ExpectQuery("SELECT user_id").WithArgs(keyHash).WillReturnError(sql.ErrNoRows)
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantErr:    e.JSONError(http.StatusUnauthorized, errTokenInvalid),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.dbTx()
+
+			req := httptest.NewRequest("GET", "https://example.com/api/links", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Add("Authorization", "Bearer "+apiKey)
+			recorder := httptest.NewRecorder()
+
+			var (
+				requestUserID string
+				scopes        []string
+			)
+			NewAuth(db, hasher).ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				requestUserID = CtxReqUserID(r.Context())
+				scopes, _ = CtxReqScopes(r.Context())
+			})
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Body.String(); got != tc.wantErr {
+				t.Errorf("got error %s, want %s", got, tc.wantErr)
+			}
+
+			if got := requestUserID; got != tc.reqUID {
+				t.Errorf("got requesterID '%s', want '%s'", got, tc.reqUID)
+			}
+
+			if got, want := strings.Join(scopes, " "), strings.Join(tc.wantScopes, " "); got != want {
+				t.Errorf("got scopes '%s', want '%s'", got, want)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -7,7 +7,12 @@ import (
 
 type contextKey string
 
-const requestUserID contextKey = "request_user_id"
+const (
+	requestUserID contextKey = "request_user_id"
+	requestScopes contextKey = "request_scopes"
+	profileUserID contextKey = "profile_user_id"
+	profileRole   contextKey = "profile_role"
+)
 
 func setRequestCtx(ctx context.Context, r *http.Request, key, value interface{}) *http.Request {
 	return r.WithContext(context.WithValue(ctx, key, value))
@@ -29,3 +34,41 @@ func CtxReqUserID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// CtxSetScopes sets the scopes of the API key authenticating the request
+func CtxSetScopes(ctx context.Context, r *http.Request, v []string) *http.Request {
+	return setRequestCtx(ctx, r, requestScopes, v)
+}
+
+// CtxReqScopes retrieves the scopes of the API key authenticating the request.
+// It reports false if the request was not authenticated with an API key.
+func CtxReqScopes(ctx context.Context) ([]string, bool) {
+	if v := contextValue(ctx, requestScopes); v != nil {
+		return v.([]string), true
+	}
+	return nil, false
+}
+
+// CtxSetProfile sets the profile the request acts on and the role of the authenticated user on it
+func CtxSetProfile(ctx context.Context, r *http.Request, userID, role string) *http.Request {
+	ctx = context.WithValue(ctx, profileUserID, userID)
+	return setRequestCtx(ctx, r, profileRole, role)
+}
+
+// CtxProfileUserID retrieves the user ID of the profile the request acts on,
+// the authenticated user if none was selected
+func CtxProfileUserID(ctx context.Context) string {
+	if v := contextValue(ctx, profileUserID); v != nil {
+		return v.(string)
+	}
+	return CtxReqUserID(ctx)
+}
+
+// CtxProfileRole retrieves the role of the authenticated user on the profile the request acts on,
+// users own their profile
+func CtxProfileRole(ctx context.Context) string {
+	if v := contextValue(ctx, profileRole); v != nil {
+		return v.(string)
+	}
+	return RoleOwner
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+)
+
+// ProfileHeader selects the profile a request acts on, the authenticated user's own by default
+const ProfileHeader = "Linktree-Profile"
+
+// Roles of team members on the profiles of the team owners, from the most to the least privileged
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRanks = map[string]int{
+	RoleOwner:  3,
+	RoleEditor: 2,
+	RoleViewer: 1,
+}
+
+var (
+	errProfileForbidden = e.New("profile_forbidden", "not a member of a team owning this profile")
+	errRoleForbidden    = e.New("role_forbidden", "role on this profile does not allow the request")
+)
+
+// Profile resolves the profile a request acts on and the role of the authenticated user on it.
+// Members of a team act on the profiles of the team owners with their team role.
+type Profile struct {
+	db *sql.DB
+}
+
+// NewProfile returns a new Profile with a db pool
+func NewProfile(db *sql.DB) Profile {
+	return Profile{db: db}
+}
+
+// ServeHTTP implements the negroni.Handler interface, it must run after Auth
+func (p Profile) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ctx := r.Context()
+	userID := CtxReqUserID(ctx)
+
+	profileID := r.Header.Get(ProfileHeader)
+	if profileID == "" || profileID == userID {
+		next(w, r)
+		return
+	}
+
+	if _, err := uuid.Parse(profileID); err != nil {
+		e.WriteError(w, http.StatusForbidden, errProfileForbidden)
+		return
+	}
+
+	role, err := p.role(ctx, userID, profileID)
+	switch err {
+	case nil:
+	case sql.ErrNoRows:
+		e.WriteError(w, http.StatusForbidden, errProfileForbidden)
+		return
+	default:
+		e.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	r = CtxSetProfile(ctx, r, profileID, role)
+
+	next(w, r)
+}
+
+// role returns the highest role of the user among the teams owned by the profile.
+// Both must have accepted their membership, so that nobody is made to share their profile.
+func (p Profile) role(ctx context.Context, userID, profileID string) (string, error) {
+	stmt := `
+		SELECT m.role
+		  FROM team_members m
+		  JOIN team_members o ON o.team_id = m.team_id
+		                     AND o.role = 'owner'
+		                     AND o.accepted_at IS NOT NULL
+		 WHERE m.user_id = $1
+		   AND m.accepted_at IS NOT NULL
+		   AND o.user_id = $2
+	`
+
+	rows, err := p.db.QueryContext(ctx, stmt, userID, profileID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var best string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return "", err
+		}
+		if roleRanks[role] > roleRanks[best] {
+			best = role
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if best == "" {
+		return "", sql.ErrNoRows
+	}
+
+	return best, nil
+}
+
+// RoleAllows reports whether role grants at least the permissions of required
+func RoleAllows(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
+
+// Authorize writes a forbidden error and returns false unless the request's role on its profile
+// grants at least the required role.
+func Authorize(w http.ResponseWriter, r *http.Request, required string) bool {
+	if RoleAllows(CtxProfileRole(r.Context()), required) {
+		return true
+	}
+
+	e.WriteError(w, http.StatusForbidden, errRoleForbidden)
+	return false
+}
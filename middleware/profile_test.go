@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+)
+
+func TestProfile_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	artistID := "9bce575b-1507-4a0f-a523-4072a72fc968"
+
+	roles := func(roles ...string) func() {
+		return func() {
+			rows := sqlmock.NewRows([]string{"role"})
+			for _, r := range roles {
+				rows.AddRow(r)
+			}
+			mock.ExpectQuery("SELECT m.role (.+) o.accepted_at IS NOT NULL (.+) m.accepted_at IS NOT NULL").
+				WithArgs(userID, artistID).WillReturnRows(rows)
+		}
+	}
+
+	var testCases = []struct {
+		name        string
+		profile     string
+		dbTx        func()
+		wantStatus  int
+		wantErr     string
+		wantProfile string
+		wantRole    string
+	}{
+		{
+			name:        "Own profile by default",
+			wantStatus:  http.StatusOK,
+			wantProfile: userID,
+			wantRole:    RoleOwner,
+		},
+		{
+			name:        "Own profile selected",
+			profile:     userID,
+			wantStatus:  http.StatusOK,
+			wantProfile: userID,
+			wantRole:    RoleOwner,
+		},
+		{
+			name:        "Shared profile with the highest role among teams",
+			profile:     artistID,
+			dbTx:        roles(RoleViewer, RoleEditor),
+			wantStatus:  http.StatusOK,
+			wantProfile: artistID,
+			wantRole:    RoleEditor,
+		},
+		{
+			name:       "Profile not shared with the user",
+			profile:    artistID,
+			dbTx:       roles(),
+			wantStatus: http.StatusForbidden,
+			wantErr:    e.JSONError(http.StatusForbidden, errProfileForbidden),
+		},
+		{
+			name:       "Invalid profile",
+			profile:    "not-a-profile",
+			wantStatus: http.StatusForbidden,
+			wantErr:    e.JSONError(http.StatusForbidden, errProfileForbidden),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.dbTx != nil {
+				tc.dbTx()
+			}
+
+			req := httptest.NewRequest("GET", "https://example.com/api/links", nil)
+			req.Header.Set(ProfileHeader, tc.profile)
+			req = CtxSetUserID(req.Context(), req, userID)
+			recorder := httptest.NewRecorder()
+
+			var profileID, role string
+			NewProfile(db).ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {
+				profileID = CtxProfileUserID(r.Context())
+				role = CtxProfileRole(r.Context())
+			})
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Body.String(); got != tc.wantErr {
+				t.Errorf("got error %s, want %s", got, tc.wantErr)
+			}
+
+			if profileID != tc.wantProfile || role != tc.wantRole {
+				t.Errorf("got profile '%s' with role '%s', want '%s' with role '%s'",
+					profileID, role, tc.wantProfile, tc.wantRole)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	var testCases = []struct {
+		role, required string
+		want           bool
+	}{
+		{RoleOwner, RoleEditor, true},
+		{RoleEditor, RoleEditor, true},
+		{RoleViewer, RoleEditor, false},
+		{RoleViewer, RoleViewer, true},
+		{"", RoleViewer, false},
+	}
+
+	for _, tc := range testCases {
+		if got := RoleAllows(tc.role, tc.required); got != tc.want {
+			t.Errorf("got %t for role '%s' requiring '%s', want %t", got, tc.role, tc.required, tc.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+)
+
+// Scopes granted to API keys
+const (
+	ScopeLinksRead     = "links:read"
+	ScopeLinksWrite    = "links:write"
+	ScopeAnalyticsRead = "analytics:read"
+)
+
+var (
+	errInsufficientScope = e.New("insufficient_scope", "api key is missing the scope required by this endpoint")
+	errSessionRequired   = e.New("session_required", "endpoint is not available to api keys")
+)
+
+// RequireScope returns a handler serving only requests authorised for scope.
+// Session tokens are granted every scope, API keys only the ones they were created with.
+func RequireScope(scope string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, ok := CtxReqScopes(r.Context())
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		for _, s := range scopes {
+			if s == scope {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		e.WriteError(w, http.StatusForbidden, errInsufficientScope)
+	})
+}
+
+// RequireSession returns a handler rejecting requests authenticated with an API key,
+// so that keys cannot manage credentials.
+func RequireSession(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := CtxReqScopes(r.Context()); ok {
+			e.WriteError(w, http.StatusForbidden, errSessionRequired)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+)
+
+func TestRequireScope(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		scopes     []string
+		wrap       func(http.Handler) http.Handler
+		wantStatus int
+		wantErr    string
+	}{
+		{
+			name:       "Session token holds every scope",
+			wrap:       func(h http.Handler) http.Handler { return RequireScope(ScopeLinksWrite, h) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "API key with the scope",
+			scopes:     []string{ScopeLinksRead, ScopeLinksWrite},
+			wrap:       func(h http.Handler) http.Handler { return RequireScope(ScopeLinksWrite, h) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "API key without the scope",
+			scopes:     []string{ScopeLinksRead},
+			wrap:       func(h http.Handler) http.Handler { return RequireScope(ScopeLinksWrite, h) },
+			wantStatus: http.StatusForbidden,
+			wantErr:    e.JSONError(http.StatusForbidden, errInsufficientScope),
+		},
+		{
+			name:       "API key without scopes",
+			scopes:     []string{},
+			wrap:       func(h http.Handler) http.Handler { return RequireScope(ScopeLinksRead, h) },
+			wantStatus: http.StatusForbidden,
+			wantErr:    e.JSONError(http.StatusForbidden, errInsufficientScope),
+		},
+		{
+			name:       "Session on session only endpoint",
+			wrap:       RequireSession,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "API key on session only endpoint",
+			scopes:     []string{ScopeLinksRead, ScopeLinksWrite, ScopeAnalyticsRead},
+			wrap:       RequireSession,
+			wantStatus: http.StatusForbidden,
+			wantErr:    e.JSONError(http.StatusForbidden, errSessionRequired),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "https://example.com/api/links", nil)
+			if tc.scopes != nil {
+				req = CtxSetScopes(req.Context(), req, tc.scopes)
+			}
+			recorder := httptest.NewRecorder()
+
+			tc.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(recorder, req)
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			if got := recorder.Body.String(); got != tc.wantErr {
+				t.Errorf("got error %s, want %s", got, tc.wantErr)
+			}
+		})
+	}
+}
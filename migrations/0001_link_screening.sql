@@ -0,0 +1,19 @@
+-- Links matching the screening rules are either rejected or stored in quarantine.
+-- Every verdict is recorded for moderators to review.
+
+ALTER TABLE links ADD COLUMN quarantined BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE link_screenings (
+    id          UUID NOT NULL PRIMARY KEY,
+    link_id     UUID REFERENCES links (id) ON DELETE CASCADE, -- NULL for rejected links
+    user_id     UUID NOT NULL REFERENCES users (id),
+    url         TEXT NOT NULL, -- sublink urls have no max length
+    matched_url TEXT NOT NULL,
+    action      VARCHAR(10) NOT NULL,
+    rule        TEXT NOT NULL,
+    reason      TEXT NOT NULL,
+    reviewed_at TIMESTAMPTZ DEFAULT NULL,
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX link_screenings_pending_idx ON link_screenings (created_at) WHERE reviewed_at IS NULL;
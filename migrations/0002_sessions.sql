@@ -0,0 +1,34 @@
+-- Users authenticate with email and password to start a session. Each session holds
+-- a short lived access token and a single use refresh token which is rotated on refresh.
+
+CREATE TABLE user_credentials (
+    user_id       UUID NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    email         VARCHAR(254) NOT NULL UNIQUE, -- stored lowercase
+    password_hash BYTEA NOT NULL -- bcrypt
+);
+
+CREATE TABLE sessions (
+    id                UUID NOT NULL PRIMARY KEY,
+    user_id           UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    user_agent        TEXT DEFAULT NULL,
+    created_at        TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    last_refreshed_at TIMESTAMPTZ DEFAULT NULL,
+    revoked_at        TIMESTAMPTZ DEFAULT NULL
+);
+
+CREATE INDEX sessions_user_idx ON sessions (user_id) WHERE revoked_at IS NULL;
+
+-- Tokens issued outside a session keep a NULL session_id
+ALTER TABLE user_tokens ADD COLUMN session_id UUID REFERENCES sessions (id) ON DELETE CASCADE;
+ALTER TABLE user_tokens ADD COLUMN created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+
+CREATE TABLE refresh_tokens (
+    id         UUID NOT NULL PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id UUID NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    expire_at  TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ DEFAULT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_session_idx ON refresh_tokens (session_id);
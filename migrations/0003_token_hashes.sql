@@ -0,0 +1,23 @@
+-- Tokens are stored as their keyed hash (HMAC-SHA256), so a leaked dump does not leak usable tokens.
+-- Run with the key passed to the server in -token_hash_key:
+--
+--   psql -v token_hash_key="$(cat token_hash.key)" -f migrations/0003_token_hashes.sql
+--
+-- Existing tokens are their own id, so they are hashed and given a new random id which,
+-- unlike the token, is not secret.
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+BEGIN;
+
+ALTER TABLE user_tokens ADD COLUMN token_hash BYTEA;
+UPDATE user_tokens SET token_hash = hmac(id::text, :'token_hash_key', 'sha256'), id = gen_random_uuid();
+ALTER TABLE user_tokens ALTER COLUMN token_hash SET NOT NULL;
+CREATE UNIQUE INDEX user_tokens_token_hash_idx ON user_tokens (token_hash);
+
+ALTER TABLE refresh_tokens ADD COLUMN token_hash BYTEA;
+UPDATE refresh_tokens SET token_hash = hmac(id::text, :'token_hash_key', 'sha256'), id = gen_random_uuid();
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+CREATE UNIQUE INDEX refresh_tokens_token_hash_idx ON refresh_tokens (token_hash);
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- Personal API keys for integrations. Like other tokens only their keyed hash is stored.
+
+CREATE TABLE api_keys (
+    id          UUID NOT NULL PRIMARY KEY,
+    user_id     UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name        VARCHAR(100) NOT NULL,
+    prefix      VARCHAR(16) NOT NULL, -- first characters of the key, shown to tell keys apart
+    token_hash  BYTEA NOT NULL UNIQUE,
+    scopes      JSONB NOT NULL, -- e.g. ["links:read", "links:write"]
+    allowed_ips JSONB NOT NULL DEFAULT '[]', -- CIDRs, empty allows any address
+    expire_at   TIMESTAMPTZ DEFAULT NULL, -- NULL never expires
+    created_at  TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_idx ON api_keys (user_id);
@@ -0,0 +1,19 @@
+-- Teams let managers act on the profiles of the team owners, e.g. an artist owning a team
+-- with their manager as editor.
+
+CREATE TABLE teams (
+    id         UUID NOT NULL PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE team_members (
+    team_id UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role    VARCHAR(10) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    -- NULL while the invitation is pending, members act on profiles only once they accept
+    accepted_at TIMESTAMPTZ DEFAULT NULL,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX team_members_user_idx ON team_members (user_id);
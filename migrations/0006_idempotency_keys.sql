@@ -0,0 +1,18 @@
+-- Responses to requests carrying an Idempotency-Key, replayed on retries for 24 hours.
+-- A NULL status marks a request in progress.
+
+CREATE TABLE idempotency_keys (
+    user_id      UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    key          VARCHAR(255) NOT NULL,
+    fingerprint  BYTEA NOT NULL, -- sha256 of the method, path, query, profile and body
+    status       SMALLINT DEFAULT NULL,
+    content_type TEXT DEFAULT NULL,
+    etag         TEXT DEFAULT NULL,
+    location     TEXT DEFAULT NULL,
+    body         BYTEA DEFAULT NULL,
+    expire_at    TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idempotency_keys_expire_idx ON idempotency_keys (expire_at);
@@ -0,0 +1,7 @@
+-- Links carry a version, incremented on every update, used as their ETag so that
+-- concurrent edits are rejected instead of overwriting each other.
+
+ALTER TABLE links ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE links ADD COLUMN updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE links SET updated_at = created_at;
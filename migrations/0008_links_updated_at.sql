@@ -0,0 +1,6 @@
+-- Time of the last write to the links of each user, sent as the Last-Modified of the
+-- links index. Unlike the updated_at of the links themselves, it also moves on deletes.
+
+ALTER TABLE users ADD COLUMN links_updated_at TIMESTAMPTZ DEFAULT NULL;
+
+UPDATE users u SET links_updated_at = (SELECT max(l.updated_at) FROM links l WHERE l.user_id = u.id);
@@ -0,0 +1,5 @@
+-- Deleted links are kept in the trash, with their sublinks, until purged after the retention period.
+
+ALTER TABLE links ADD COLUMN deleted_at TIMESTAMPTZ DEFAULT NULL;
+
+CREATE INDEX links_trash_idx ON links (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,16 @@
+-- Every change to a link and its sublinks, with the link before and after it,
+-- so that changes can be reviewed and reverted.
+
+CREATE TABLE link_revisions (
+    id         UUID NOT NULL PRIMARY KEY,
+    link_id    UUID NOT NULL REFERENCES links (id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE, -- owner of the link
+    actor_id   UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE, -- user making the change
+    action     VARCHAR(10) NOT NULL, -- create, update, delete, restore or revert
+    version    INTEGER NOT NULL, -- version of the link after the change
+    before     JSONB DEFAULT NULL, -- NULL for created links
+    after      JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX link_revisions_link_idx ON link_revisions (link_id, created_at);
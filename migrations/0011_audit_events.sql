@@ -0,0 +1,27 @@
+-- Append-only audit trail of authentication events and changes made through the api.
+-- Events outlive the users they refer to, so they have no foreign keys.
+
+CREATE TABLE audit_events (
+    id         UUID NOT NULL PRIMARY KEY,
+    user_id    UUID DEFAULT NULL, -- account the event belongs to
+    actor_id   UUID DEFAULT NULL, -- authenticated user causing the event
+    action     VARCHAR(50) NOT NULL,
+    resource   TEXT DEFAULT NULL, -- request path
+    status     SMALLINT DEFAULT NULL,
+    remote_ip  TEXT DEFAULT NULL,
+    metadata   JSONB DEFAULT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_events_user_idx ON audit_events (user_id, created_at);
+CREATE INDEX audit_events_actor_idx ON audit_events (actor_id, created_at);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();
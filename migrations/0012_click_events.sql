@@ -0,0 +1,17 @@
+-- Clicks on links and sublinks made through the redirect endpoint.
+-- Rows are written in batches after the redirect, so they have no foreign keys
+-- that could fail a batch when a link is purged in the meantime.
+
+CREATE TABLE click_events (
+    id         BIGSERIAL PRIMARY KEY,
+    link_id    UUID NOT NULL,
+    sublink_id UUID DEFAULT NULL,
+    user_id    UUID NOT NULL, -- owner of the link
+    referrer   TEXT DEFAULT NULL,
+    user_agent TEXT DEFAULT NULL,
+    device     VARCHAR(10) NOT NULL, -- desktop, mobile, tablet, bot or unknown
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX click_events_user_idx ON click_events (user_id, created_at);
+CREATE INDEX click_events_link_idx ON click_events (link_id, created_at);
@@ -0,0 +1,24 @@
+-- Hourly rollups of click_events maintained by a background aggregator, read by the analytics api.
+-- Clicks on a link itself have the nil uuid as sublink_id, so that it can be part of the keys.
+
+CREATE TABLE click_rollups (
+    user_id    UUID NOT NULL,
+    link_id    UUID NOT NULL,
+    sublink_id UUID NOT NULL,
+    hour       TIMESTAMPTZ NOT NULL,
+    device     VARCHAR(10) NOT NULL,
+    referrer   TEXT NOT NULL, -- host of the referrer, empty if none
+    clicks     BIGINT NOT NULL,
+    PRIMARY KEY (link_id, sublink_id, hour, device, referrer)
+);
+
+CREATE INDEX click_rollups_user_idx ON click_rollups (user_id, hour);
+
+-- Clicks made before rolled_up_to are in the rollups. The single row is locked while aggregating.
+CREATE TABLE click_rollup_state (
+    id           BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    rolled_up_to TIMESTAMPTZ NOT NULL
+);
+
+INSERT INTO click_rollup_state (rolled_up_to)
+SELECT COALESCE(min(created_at), NOW()) FROM click_events;
@@ -0,0 +1,20 @@
+-- Visitors are identified with a keyed hash of their address and user agent, keyed with a salt
+-- replaced every day and deleted afterwards, and counted with HyperLogLog sketches by day.
+
+ALTER TABLE click_events ADD COLUMN visitor BYTEA DEFAULT NULL; -- keyed hash with the salt of the day
+
+CREATE TABLE visitor_salts (
+    day  DATE PRIMARY KEY,
+    salt BYTEA NOT NULL
+);
+
+CREATE TABLE visitor_sketches (
+    user_id    UUID NOT NULL,
+    link_id    UUID NOT NULL,
+    sublink_id UUID NOT NULL, -- the nil uuid for visitors of the link itself
+    day        DATE NOT NULL,
+    sketch     BYTEA NOT NULL,
+    PRIMARY KEY (link_id, sublink_id, day)
+);
+
+CREATE INDEX visitor_sketches_user_idx ON visitor_sketches (user_id, day);
@@ -0,0 +1,14 @@
+-- Usernames identify the public profiles of users, and links get a position to be listed in.
+-- Users are provisioned outside the api, so usernames are set along with them.
+
+ALTER TABLE users ADD COLUMN username VARCHAR(30) DEFAULT NULL;
+
+CREATE UNIQUE INDEX users_username_idx ON users (username);
+
+ALTER TABLE links ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+-- Existing links keep the order they were created in
+UPDATE links l SET position = p.position
+  FROM (SELECT id, row_number() OVER (PARTITION BY user_id ORDER BY created_at, id) AS position
+          FROM links) p
+ WHERE p.id = l.id;
@@ -0,0 +1,12 @@
+-- Appearance of the public profile pages, chosen by users. Users without a row get the default theme.
+
+CREATE TABLE profiles (
+    user_id           UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    theme             VARCHAR(20) NOT NULL,
+    background_color  VARCHAR(7) DEFAULT NULL, -- hex colours overriding those of the theme
+    text_color        VARCHAR(7) DEFAULT NULL,
+    button_color      VARCHAR(7) DEFAULT NULL,
+    button_text_color VARCHAR(7) DEFAULT NULL,
+    font              VARCHAR(20) DEFAULT NULL,
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
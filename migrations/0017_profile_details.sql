@@ -0,0 +1,5 @@
+-- Details shown on the public profile pages and in their previews when shared
+
+ALTER TABLE profiles ADD COLUMN display_name VARCHAR(60) DEFAULT NULL;
+ALTER TABLE profiles ADD COLUMN bio VARCHAR(160) DEFAULT NULL;
+ALTER TABLE profiles ADD COLUMN avatar VARCHAR(500) DEFAULT NULL;
@@ -0,0 +1,168 @@
+package pages
+
+import (
+	"encoding/json"
+	"html/template"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+)
+
+const (
+	schemaContext = "https://schema.org"
+	// showDateFormat is the format of the dates of shows, validated by lkDate
+	showDateFormat = "Jan 02 2006"
+)
+
+// offerAvailability is the schema.org availability of the tickets of shows by status.
+// Shows not on sale yet have no offer.
+var offerAvailability = map[string]string{
+	string(models.StatusOnSale):  "https://schema.org/InStock",
+	string(models.StatusSoldOut): "https://schema.org/SoldOut",
+}
+
+// meta is the preview of a page when shared, with its structured data
+type meta struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	JSONLD      template.JS
+}
+
+// thing is a schema.org item, with the properties of the types used in profiles
+type thing struct {
+	Context     string   `json:"@context,omitempty"`
+	Type        string   `json:"@type"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Image       string   `json:"image,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	MainEntity  *thing   `json:"mainEntity,omitempty"`
+	ByArtist    *thing   `json:"byArtist,omitempty"`
+	Performer   *thing   `json:"performer,omitempty"`
+	StartDate   string   `json:"startDate,omitempty"`
+	EventStatus string   `json:"eventStatus,omitempty"`
+	Attendance  string   `json:"eventAttendanceMode,omitempty"`
+	Location    *place   `json:"location,omitempty"`
+	Offers      *offer   `json:"offers,omitempty"`
+	SameAs      []string `json:"sameAs,omitempty"`
+}
+
+type place struct {
+	Type    string `json:"@type"`
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address"`
+}
+
+type offer struct {
+	Type         string `json:"@type"`
+	URL          string `json:"url"`
+	Availability string `json:"availability"`
+}
+
+// profileMeta returns the preview and the structured data of a profile, with urls under baseURL.
+// Music links are described as recordings and shows as events, so they can appear as rich results.
+func profileMeta(p models.Profile, baseURL string) (meta, error) {
+	m := meta{
+		Title: "@" + p.Username,
+		URL:   baseURL + "/public/" + p.Username,
+	}
+	if p.DisplayName != nil && *p.DisplayName != "" {
+		m.Title = *p.DisplayName
+	}
+
+	m.Description = "Links of " + m.Title
+	if p.Bio != nil && *p.Bio != "" {
+		m.Description = *p.Bio
+	}
+	if p.Avatar != nil {
+		m.Image = *p.Avatar
+	}
+
+	artist := &thing{Type: "MusicGroup", Name: m.Title}
+	items := []thing{{
+		Context:    schemaContext,
+		Type:       "ProfilePage",
+		URL:        m.URL,
+		MainEntity: &thing{Type: "Person", Name: m.Title, Description: m.Description, Image: m.Image, URL: m.URL},
+	}}
+
+	for _, l := range p.Links {
+		redirect := baseURL + "/r/" + l.ID
+
+		switch l.Type {
+		case models.LinkMusic:
+			rec := thing{Context: schemaContext, Type: "MusicRecording", Name: value(l.Title), ByArtist: artist}
+			if l.URL != nil {
+				rec.URL = redirect
+			}
+			if l.Thumbnail != nil {
+				rec.Image = *l.Thumbnail
+			}
+			for _, sl := range l.SubLinks {
+				if pl, ok := sl.(models.Platform); ok {
+					rec.SameAs = append(rec.SameAs, pl.URL)
+				}
+			}
+			items = append(items, rec)
+
+		case models.LinkShows:
+			for _, sl := range l.SubLinks {
+				if s, ok := sl.(models.Show); ok {
+					if event, ok := showEvent(s, artist, redirect+"/"+s.ID); ok {
+						items = append(items, event)
+					}
+				}
+			}
+		}
+	}
+
+	// Marshalled json escapes <, > and &, so it cannot end the script element
+	data, err := json.Marshal(items)
+	if err != nil {
+		return m, err
+	}
+	m.JSONLD = template.JS(data)
+
+	return m, nil
+}
+
+// showEvent returns the event of a show, false if its date cannot be read
+func showEvent(s models.Show, artist *thing, url string) (thing, bool) {
+	date, err := time.Parse(showDateFormat, s.Date)
+	if err != nil {
+		return thing{}, false
+	}
+
+	event := thing{
+		Context:     schemaContext,
+		Type:        "MusicEvent",
+		Name:        s.Name,
+		URL:         url,
+		StartDate:   date.Format("2006-01-02"),
+		EventStatus: "https://schema.org/EventScheduled",
+		Attendance:  "https://schema.org/OfflineEventAttendanceMode",
+		Location:    &place{Type: "Place", Name: s.Venue, Address: s.Location},
+		Performer:   artist,
+	}
+
+	if event.Name == "" {
+		event.Name = artist.Name
+	}
+	if event.Location.Address == "" {
+		event.Location.Address = s.Venue
+	}
+	if a, ok := offerAvailability[string(s.Status)]; ok {
+		event.Offers = &offer{Type: "Offer", URL: url, Availability: a}
+	}
+
+	return event, true
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,102 @@
+package pages
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+)
+
+func TestProfileMeta(t *testing.T) {
+	profile := models.Profile{
+		Username: "artist",
+		ProfileDetails: models.ProfileDetails{
+			DisplayName: strPtr("The Artist"),
+			Bio:         strPtr("Songs and shows"),
+			Avatar:      strPtr("https://img.linktr.ee/artist.png"),
+		},
+		Links: []models.Link{
+			{ID: "l1", Type: models.LinkClassic, Title: strPtr("Website"), URL: strPtr("https://artist.com")},
+			{ID: "l2", Type: models.LinkMusic, Title: strPtr("First Song"), URL: strPtr("https://song.link/1"),
+				SubLinks: []interface{}{models.Platform{ID: "p1", Name: "Spotify", URL: "https://open.spotify.com/track/1"}}},
+			{ID: "l3", Type: models.LinkShows, Title: strPtr("Tour"), SubLinks: []interface{}{
+				models.Show{ID: "s1", Date: "Jun 01 2020", Name: "Album launch", Venue: "Corner Hotel",
+					Location: "Melbourne", Status: models.StatusOnSale},
+				models.Show{ID: "s2", Date: "Jun 02 2020", Venue: "Enmore", Status: models.StatusNotOnSale},
+				models.Show{ID: "s3", Date: "sometime", Venue: "Metro", Status: models.StatusSoldOut},
+			}},
+		},
+	}
+
+	m, err := profileMeta(profile, "https://linktr.ee")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := m.Title, "The Artist"; got != want {
+		t.Errorf("got title %s, want %s", got, want)
+	}
+	if got, want := m.Description, "Songs and shows"; got != want {
+		t.Errorf("got description %s, want %s", got, want)
+	}
+	if got, want := m.URL, "https://linktr.ee/public/artist"; got != want {
+		t.Errorf("got url %s, want %s", got, want)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal([]byte(m.JSONLD), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	artist := map[string]interface{}{"@type": "MusicGroup", "name": "The Artist"}
+	want := []map[string]interface{}{
+		{
+			"@context": "https://schema.org", "@type": "ProfilePage", "url": "https://linktr.ee/public/artist",
+			"mainEntity": map[string]interface{}{"@type": "Person", "name": "The Artist", "description": "Songs and shows",
+				"image": "https://img.linktr.ee/artist.png", "url": "https://linktr.ee/public/artist"},
+		},
+		{
+			"@context": "https://schema.org", "@type": "MusicRecording", "name": "First Song",
+			"url": "https://linktr.ee/r/l2", "byArtist": artist,
+			"sameAs": []interface{}{"https://open.spotify.com/track/1"},
+		},
+		{
+			"@context": "https://schema.org", "@type": "MusicEvent", "name": "Album launch",
+			"url": "https://linktr.ee/r/l3/s1", "performer": artist, "startDate": "2020-06-01",
+			"eventStatus": "https://schema.org/EventScheduled", "eventAttendanceMode": "https://schema.org/OfflineEventAttendanceMode",
+			"location": map[string]interface{}{"@type": "Place", "name": "Corner Hotel", "address": "Melbourne"},
+			"offers": map[string]interface{}{"@type": "Offer", "url": "https://linktr.ee/r/l3/s1",
+				"availability": "https://schema.org/InStock"},
+		},
+		{
+			"@context": "https://schema.org", "@type": "MusicEvent", "name": "The Artist",
+			"url": "https://linktr.ee/r/l3/s2", "performer": artist, "startDate": "2020-06-02",
+			"eventStatus": "https://schema.org/EventScheduled", "eventAttendanceMode": "https://schema.org/OfflineEventAttendanceMode",
+			"location": map[string]interface{}{"@type": "Place", "name": "Enmore", "address": "Enmore"},
+		},
+	}
+
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("got unexpected structured data: %s", diff)
+	}
+}
+
+func TestProfileMeta_Defaults(t *testing.T) {
+	m, err := profileMeta(models.Profile{Username: "artist"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := meta{
+		Title:       "@artist",
+		Description: "Links of @artist",
+		URL:         "/public/artist",
+		JSONLD: `[{"@context":"https://schema.org","@type":"ProfilePage","url":"/public/artist",` +
+			`"mainEntity":{"@type":"Person","name":"@artist","description":"Links of @artist","url":"/public/artist"}}]`,
+	}
+	if m != want {
+		t.Errorf("got meta %+v, want %+v", m, want)
+	}
+}
@@ -0,0 +1,137 @@
+package pages
+
+import (
+	"html/template"
+	"io"
+	"regexp"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+)
+
+// style holds the css values of a page
+type style struct {
+	Background string
+	Text       string
+	Button     string
+	ButtonText string
+	Font       template.CSS
+}
+
+// themes are the styles users can choose for their page, keyed by the names accepted by models.Appearance
+var themes = map[string]style{
+	"light":  {Background: "#f5f5f5", Text: "#1d1d1f", Button: "#ffffff", ButtonText: "#1d1d1f", Font: fonts["system"]},
+	"dark":   {Background: "#121212", Text: "#f5f5f5", Button: "#2a2a2a", ButtonText: "#f5f5f5", Font: fonts["system"]},
+	"sunset": {Background: "#ff7e5f", Text: "#ffffff", Button: "#feb47b", ButtonText: "#3d1f0f", Font: fonts["rounded"]},
+}
+
+// fonts are the font stacks users can choose, installed on most devices so no font is downloaded
+var fonts = map[string]template.CSS{
+	"system":  `-apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif`,
+	"serif":   `Georgia, "Times New Roman", serif`,
+	"mono":    `Menlo, Consolas, "Liberation Mono", monospace`,
+	"rounded": `ui-rounded, "SF Pro Rounded", "Arial Rounded MT Bold", sans-serif`,
+}
+
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// statusLabels are the badges of shows by status
+var statusLabels = map[string]string{
+	string(models.StatusOnSale):    "On sale",
+	string(models.StatusSoldOut):   "Sold out",
+	string(models.StatusNotOnSale): "Not on sale",
+}
+
+// page is the data of the profile template
+type page struct {
+	Username string
+	Bio      string
+	Meta     meta
+	Style    style
+	Links    []link
+}
+
+// link is a link of the page, which points to its tracked redirect
+type link struct {
+	ID        string
+	Type      string
+	Title     string
+	HasURL    bool
+	Thumbnail string
+	Platforms []models.Platform
+	Shows     []show
+}
+
+type show struct {
+	models.Show
+	Label  string
+	OnSale bool
+}
+
+// RenderProfile writes the html page of a profile, with absolute urls under baseURL.
+// Links point to their redirects, so that visits to the page record clicks.
+func RenderProfile(w io.Writer, p models.Profile, baseURL string) error {
+	m, err := profileMeta(p, baseURL)
+	if err != nil {
+		return err
+	}
+
+	data := page{Username: p.Username, Bio: value(p.Bio), Meta: m, Style: appearanceStyle(p.Appearance)}
+
+	for _, l := range p.Links {
+		pl := link{ID: l.ID, Type: string(l.Type), HasURL: l.URL != nil}
+		if l.Title != nil {
+			pl.Title = *l.Title
+		}
+		if l.Thumbnail != nil {
+			pl.Thumbnail = *l.Thumbnail
+		}
+
+		for _, sl := range l.SubLinks {
+			switch sb := sl.(type) {
+			case models.Platform:
+				pl.Platforms = append(pl.Platforms, sb)
+			case models.Show:
+				pl.Shows = append(pl.Shows, show{
+					Show:   sb,
+					Label:  statusLabels[string(sb.Status)],
+					OnSale: sb.Status == models.StatusOnSale,
+				})
+			}
+		}
+
+		data.Links = append(data.Links, pl)
+	}
+
+	return profileTemplate.Execute(w, data)
+}
+
+// appearanceStyle returns the style of the theme with the colours and font of the appearance.
+// Values are checked again as they are written in css.
+func appearanceStyle(a models.Appearance) style {
+	s, ok := themes[a.Theme]
+	if !ok {
+		s = themes[models.DefaultTheme]
+	}
+
+	for _, c := range []struct {
+		value *string
+		dst   *string
+	}{
+		{a.BackgroundColor, &s.Background},
+		{a.TextColor, &s.Text},
+		{a.ButtonColor, &s.Button},
+		{a.ButtonTextColor, &s.ButtonText},
+	} {
+		if c.value != nil && hexColor.MatchString(*c.value) {
+			*c.dst = *c.value
+		}
+	}
+
+	if a.Font != nil {
+		if f, ok := fonts[*a.Font]; ok {
+			s.Font = f
+		}
+	}
+
+	return s
+}
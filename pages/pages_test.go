@@ -0,0 +1,96 @@
+package pages
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestRenderProfile(t *testing.T) {
+	profile := models.Profile{
+		Username:   "artist",
+		Appearance: models.Appearance{Theme: "dark", ButtonColor: strPtr("#ff0000"), Font: strPtr("serif")},
+		Links: []models.Link{
+			{ID: "l1", Type: models.LinkClassic, Title: strPtr(`Website <script>alert(1)</script>`), URL: strPtr("https://artist.com")},
+			{ID: "l2", Type: models.LinkClassic, Title: strPtr("Upcoming")},
+			{ID: "l3", Type: models.LinkMusic, Title: strPtr("First Song"), Thumbnail: strPtr("javascript:alert(1)"),
+				SubLinks: []interface{}{models.Platform{ID: "p1", Name: "Spotify", URL: "https://open.spotify.com/track/1"}}},
+			{ID: "l4", Type: models.LinkShows, Title: strPtr("Tour"), SubLinks: []interface{}{
+				models.Show{ID: "s1", Date: "Jun 01 2020", Venue: "Corner Hotel", Location: "Melbourne", Status: models.StatusOnSale},
+				models.Show{ID: "s2", Date: "Jun 02 2020", Venue: "Enmore", Status: models.StatusSoldOut},
+			}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderProfile(&buf, profile, "https://linktr.ee"); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		`<a class="button" href="/r/l1">Website &lt;script&gt;alert(1)&lt;/script&gt;</a>`,
+		`<h2>Upcoming</h2>`,
+		`src="#ZgotmplZ"`,
+		`<a class="badge" href="/r/l3/p1">Play</a>`,
+		`Jun 01 2020 · Corner Hotel, Melbourne`,
+		`<a class="badge" href="/r/l4/s1">On sale</a>`,
+		`<span class="badge">Sold out</span>`,
+		`background: #121212`,
+		`background: #ff0000`,
+		`font-family: Georgia, "Times New Roman", serif`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got page without %s", want)
+		}
+	}
+
+	if strings.Contains(got, "<script>") {
+		t.Errorf("got unescaped title in page")
+	}
+}
+
+func TestAppearanceStyle(t *testing.T) {
+	var testCases = []struct {
+		name       string
+		appearance models.Appearance
+		want       style
+	}{
+		{
+			name:       "Theme",
+			appearance: models.Appearance{Theme: "sunset"},
+			want:       themes["sunset"],
+		},
+		{
+			name:       "Unknown theme",
+			appearance: models.Appearance{Theme: "neon"},
+			want:       themes[models.DefaultTheme],
+		},
+		{
+			name: "Overrides",
+			appearance: models.Appearance{Theme: "light", BackgroundColor: strPtr("#000"), TextColor: strPtr("#fff"),
+				Font: strPtr("mono")},
+			want: style{Background: "#000", Text: "#fff", Button: themes["light"].Button,
+				ButtonText: themes["light"].ButtonText, Font: fonts["mono"]},
+		},
+		{
+			name:       "Invalid overrides",
+			appearance: models.Appearance{Theme: "light", TextColor: strPtr("red; background: url(x)"), Font: strPtr("comic")},
+			want:       themes["light"],
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := appearanceStyle(tc.appearance); got != tc.want {
+				t.Errorf("got style %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
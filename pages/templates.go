@@ -0,0 +1,69 @@
+package pages
+
+import "html/template"
+
+// profileTemplate renders a profile, escaping every value for its context
+var profileTemplate = template.Must(template.New("profile").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Meta.Title}}</title>
+<meta name="description" content="{{.Meta.Description}}">
+<link rel="canonical" href="{{.Meta.URL}}">
+<meta property="og:type" content="profile">
+<meta property="og:title" content="{{.Meta.Title}}">
+<meta property="og:description" content="{{.Meta.Description}}">
+<meta property="og:url" content="{{.Meta.URL}}">
+<meta property="profile:username" content="{{.Username}}">
+{{if .Meta.Image}}<meta property="og:image" content="{{.Meta.Image}}">
+{{end}}<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="{{.Meta.Title}}">
+<meta name="twitter:description" content="{{.Meta.Description}}">
+{{if .Meta.Image}}<meta name="twitter:image" content="{{.Meta.Image}}">
+{{end}}<script type="application/ld+json">{{.Meta.JSONLD}}</script>
+<style>
+body { margin: 0; padding: 32px 16px; background: {{.Style.Background}}; color: {{.Style.Text}}; font-family: {{.Style.Font}}; }
+main { max-width: 640px; margin: 0 auto; }
+header { text-align: center; }
+h1 { font-size: 1.25rem; }
+.avatar { width: 96px; height: 96px; border-radius: 50%; object-fit: cover; }
+h2 { font-size: 1rem; margin: 0 0 8px; }
+a { color: inherit; }
+.button, .card { display: block; margin: 12px 0; padding: 14px 20px; border-radius: 8px; background: {{.Style.Button}}; color: {{.Style.ButtonText}}; }
+.button { text-align: center; text-decoration: none; font-weight: 600; }
+.card ul { list-style: none; margin: 0; padding: 0; }
+.card li { display: flex; justify-content: space-between; align-items: center; gap: 8px; padding: 8px 0; border-top: 1px solid rgba(127, 127, 127, 0.3); }
+.thumbnail { width: 64px; height: 64px; border-radius: 4px; object-fit: cover; float: left; margin-right: 12px; }
+.badge { font-size: 0.75rem; padding: 2px 8px; border-radius: 999px; border: 1px solid currentColor; white-space: nowrap; }
+.sold-out, .not-on-sale { opacity: 0.6; }
+.clear { clear: both; }
+</style>
+</head>
+<body>
+<main>
+<header>
+{{if .Meta.Image}}<img class="avatar" src="{{.Meta.Image}}" alt="">
+{{end}}<h1>{{.Meta.Title}}</h1>
+{{if .Bio}}<p>{{.Bio}}</p>
+{{end}}</header>
+{{range .Links}}{{if eq .Type "music"}}{{template "music" .}}{{else if eq .Type "shows"}}{{template "shows" .}}{{else}}{{template "classic" .}}{{end}}
+{{end}}</main>
+</body>
+</html>
+{{define "classic"}}{{if .HasURL}}<a class="button" href="/r/{{.ID}}">{{.Title}}</a>{{else}}<h2>{{.Title}}</h2>{{end}}{{end}}
+{{define "music"}}<section class="card music">
+{{if .Thumbnail}}<img class="thumbnail" src="{{.Thumbnail}}" alt="">{{end}}<h2>{{if .HasURL}}<a href="/r/{{.ID}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h2>
+<div class="clear"></div>
+<ul>
+{{$link := .ID}}{{range .Platforms}}<li><span>{{.Name}}</span><a class="badge" href="/r/{{$link}}/{{.ID}}">Play</a></li>
+{{end}}</ul>
+</section>{{end}}
+{{define "shows"}}<section class="card shows">
+<h2>{{.Title}}</h2>
+<ul>
+{{$link := .ID}}{{range .Shows}}<li class="{{.Status}}"><span>{{.Date}} · {{if .Venue}}{{.Venue}}{{if .Location}}, {{end}}{{end}}{{.Location}}{{if .Name}} · {{.Name}}{{end}}</span>
+{{if .OnSale}}<a class="badge" href="/r/{{$link}}/{{.ID}}">{{.Label}}</a>{{else}}<span class="badge">{{.Label}}</span>{{end}}</li>
+{{end}}</ul>
+</section>{{end}}
+`))
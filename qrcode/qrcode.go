@@ -0,0 +1,159 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Limits of the options, in pixels for the size and in modules for the margin
+const (
+	MinSize   = 64
+	MaxSize   = 2048
+	MaxMargin = 16
+)
+
+var (
+	errTooSmall   = errors.New("size is too small for the code")
+	errSameColors = errors.New("colours must differ")
+)
+
+// levels are the error correction levels, from least to most tolerant of damage
+var levels = map[string]qr.Level{
+	"L": qr.L,
+	"M": qr.M,
+	"Q": qr.Q,
+	"H": qr.H,
+}
+
+// Options control how codes are drawn
+type Options struct {
+	// Size is the width and height of the image, in pixels
+	Size int
+	// Margin is the quiet zone around the code, in modules. Scanners need at least 4.
+	Margin     int
+	Level      qr.Level
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// DefaultOptions returns black codes on white of 256 pixels, with medium error correction
+func DefaultOptions() Options {
+	return Options{
+		Size:       256,
+		Margin:     4,
+		Level:      qr.M,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// ParseLevel returns the error correction level named L, M, Q or H
+func ParseLevel(s string) (qr.Level, bool) {
+	l, ok := levels[strings.ToUpper(s)]
+	return l, ok
+}
+
+// ParseColor returns the colour of a hex value, rrggbb or #rrggbb
+func ParseColor(s string) (color.RGBA, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, false
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true
+}
+
+// modules encodes text, returning the code and its width in modules with the margin
+func modules(text string, o Options) (*qr.Code, int, error) {
+	if o.Foreground == o.Background {
+		return nil, 0, errSameColors
+	}
+
+	code, err := qr.Encode(text, o.Level)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return code, code.Size + 2*o.Margin, nil
+}
+
+// WritePNG writes the code of text as a PNG image of o.Size pixels. Modules are drawn with
+// a whole number of pixels, so the code is centered in any pixels left.
+func WritePNG(w io.Writer, text string, o Options) error {
+	code, n, err := modules(text, o)
+	if err != nil {
+		return err
+	}
+
+	scale := o.Size / n
+	if scale < 1 {
+		return errTooSmall
+	}
+	offset := (o.Size-scale*n)/2 + o.Margin*scale
+
+	// Index 0 is the background
+	img := image.NewPaletted(image.Rect(0, 0, o.Size, o.Size), color.Palette{o.Background, o.Foreground})
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+
+			for py := 0; py < scale; py++ {
+				row := img.Pix[(offset+y*scale+py)*img.Stride:]
+				for px := 0; px < scale; px++ {
+					row[offset+x*scale+px] = 1
+				}
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// WriteSVG writes the code of text as an SVG image of o.Size pixels, scaling without loss
+func WriteSVG(w io.Writer, text string, o Options) error {
+	code, n, err := modules(text, o)
+	if err != nil {
+		return err
+	}
+
+	var path strings.Builder
+	for y := 0; y < code.Size; y++ {
+		// Adjacent dark modules of a row are drawn as one rectangle
+		for x := 0; x < code.Size; {
+			if !code.Black(x, y) {
+				x++
+				continue
+			}
+
+			start := x
+			for x < code.Size && code.Black(x, y) {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start+o.Margin, y+o.Margin, x-start, x-start)
+		}
+	}
+
+	_, err = fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="%s"/><path fill="%s" d="%s"/></svg>`,
+		o.Size, o.Size, n, n, hex(o.Background), hex(o.Foreground), path.String())
+	return err
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,123 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"rsc.io/qr"
+)
+
+const text = "https://linktr.ee/public/artist"
+
+func TestWritePNG(t *testing.T) {
+	o := DefaultOptions()
+	o.Size = 300
+	o.Foreground = color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+
+	var buf bytes.Buffer
+	if err := WritePNG(&buf, text, o); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds().Dx(); got != o.Size {
+		t.Errorf("got width %d, want %d", got, o.Size)
+	}
+
+	code, err := qr.Encode(text, o.Level)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The center of every module has its colour
+	n := code.Size + 2*o.Margin
+	scale := o.Size / n
+	offset := (o.Size-scale*n)/2 + o.Margin*scale
+	for y := -o.Margin; y < code.Size+o.Margin; y++ {
+		for x := -o.Margin; x < code.Size+o.Margin; x++ {
+			want := o.Background
+			if code.Black(x, y) {
+				want = o.Foreground
+			}
+
+			c := img.At(offset+x*scale+scale/2, offset+y*scale+scale/2)
+			if got := color.RGBAModel.Convert(c).(color.RGBA); got != want {
+				t.Fatalf("got colour %v for module (%d, %d), want %v", got, x, y, want)
+			}
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	o := DefaultOptions()
+	o.Margin = 2
+
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, text, o); err != nil {
+		t.Fatal(err)
+	}
+
+	var svg struct {
+		Width   string `xml:"width,attr"`
+		ViewBox string `xml:"viewBox,attr"`
+		Path    struct {
+			Fill string `xml:"fill,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("got invalid svg: %v", err)
+	}
+
+	code, _ := qr.Encode(text, o.Level)
+	if got, want := svg.ViewBox, fmt.Sprintf("0 0 %d %d", code.Size+4, code.Size+4); got != want {
+		t.Errorf("got viewBox %s, want %s", got, want)
+	}
+	if got, want := svg.Width, "256"; got != want {
+		t.Errorf("got width %s, want %s", got, want)
+	}
+	if got, want := svg.Path.Fill, "#000000"; got != want {
+		t.Errorf("got fill %s, want %s", got, want)
+	}
+}
+
+func TestWriteInvalidOptions(t *testing.T) {
+	small := DefaultOptions()
+	small.Size = 20
+	if err := WritePNG(&bytes.Buffer{}, text, small); err != errTooSmall {
+		t.Errorf("got error %v, want %v", err, errTooSmall)
+	}
+
+	same := DefaultOptions()
+	same.Background = same.Foreground
+	if err := WriteSVG(&bytes.Buffer{}, text, same); err != errSameColors {
+		t.Errorf("got error %v, want %v", err, errSameColors)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	var testCases = []struct {
+		value  string
+		want   color.RGBA
+		wantOK bool
+	}{
+		{"#ff8000", color.RGBA{R: 0xff, G: 0x80, A: 0xff}, true},
+		{"0a0B0c", color.RGBA{R: 0x0a, G: 0x0b, B: 0x0c, A: 0xff}, true},
+		{"#fff", color.RGBA{}, false},
+		{"red", color.RGBA{}, false},
+		{"#gggggg", color.RGBA{}, false},
+	}
+
+	for _, tc := range testCases {
+		got, ok := ParseColor(tc.value)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("got %v, %t parsing %s, want %v, %t", got, ok, tc.value, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	e "github.com/alessio-palumbo/linktree-challenge/errors"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+var errRateLimited = e.New("rate_limited", "too many requests, retry later")
+
+// Rule applies a limit to the requests matching the method, any if empty, and the path prefix.
+// Requests are counted separately for each rule.
+type Rule struct {
+	Name   string
+	Method string
+	Path   string
+	Limit  Limit
+}
+
+func (rule Rule) matches(r *http.Request) bool {
+	return (rule.Method == "" || rule.Method == r.Method) && strings.HasPrefix(r.URL.Path, rule.Path)
+}
+
+// Limiter is a negroni middleware rate limiting requests with token buckets.
+// Buckets are kept per authenticated user, or per client address for anonymous requests,
+// so it must run after the authentication middleware.
+type Limiter struct {
+	store Store
+	rules []Rule
+	now   func() time.Time
+	// byAddress keeps buckets per client address, even for authenticated requests
+	byAddress bool
+}
+
+// New returns a Limiter applying the first of the rules matching a request.
+// Requests matching no rule are not limited.
+func New(store Store, rules []Rule) Limiter {
+	return Limiter{store: store, rules: rules, now: time.Now}
+}
+
+// NewByAddress returns a Limiter like New, keeping buckets per client address.
+// It can run before the authentication middleware, to limit the requests it handles.
+func NewByAddress(store Store, rules []Rule) Limiter {
+	return Limiter{store: store, rules: rules, now: time.Now, byAddress: true}
+}
+
+// ServeHTTP implements the negroni.Handler interface
+func (l Limiter) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	rule, ok := l.match(r)
+	if !ok {
+		next(w, r)
+		return
+	}
+
+	key := clientKey(r)
+	if l.byAddress {
+		key = addressKey(r)
+	}
+
+	res, err := l.store.Take(r.Context(), rule.Name+":"+key, rule.Limit, l.now())
+	if err != nil {
+		// Do not fail requests when a shared store is unavailable
+		log.Printf("rate limit store: %v", err)
+		next(w, r)
+		return
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+	if !res.Allowed {
+		h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+		e.WriteError(w, http.StatusTooManyRequests, errRateLimited)
+		return
+	}
+
+	next(w, r)
+}
+
+func (l Limiter) match(r *http.Request) (Rule, bool) {
+	for _, rule := range l.rules {
+		if rule.matches(r) {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// clientKey identifies the authenticated user, or the client address if anonymous
+func clientKey(r *http.Request) string {
+	if userID := middleware.CtxReqUserID(r.Context()); userID != "" {
+		return "user:" + userID
+	}
+
+	return addressKey(r)
+}
+
+// addressKey identifies the client address
+func addressKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+)
+
+func TestBucket_Take(t *testing.T) {
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	l := Limit{Requests: 2, Per: time.Minute}
+	b := NewBucket(l, now)
+
+	var testCases = []struct {
+		name           string
+		elapsed        time.Duration
+		wantAllowed    bool
+		wantRemaining  int
+		wantReset      time.Duration
+		wantRetryAfter time.Duration
+	}{
+		{"First request", 0, true, 1, 30 * time.Second, 0},
+		{"Burst", 0, true, 0, time.Minute, 0},
+		{"Bucket empty", 0, false, 0, time.Minute, 30 * time.Second},
+		{"Partially refilled", 15 * time.Second, false, 0, 45 * time.Second, 15 * time.Second},
+		{"Token refilled", 15 * time.Second, true, 0, time.Minute, 0},
+		{"Refills up to the limit", time.Hour, true, 1, 30 * time.Second, 0},
+	}
+
+	for _, tc := range testCases {
+		now = now.Add(tc.elapsed)
+		res := b.Take(l, now)
+
+		if res.Allowed != tc.wantAllowed || res.Remaining != tc.wantRemaining {
+			t.Errorf("%s: got allowed %t with %d remaining, want %t with %d",
+				tc.name, res.Allowed, res.Remaining, tc.wantAllowed, tc.wantRemaining)
+		}
+
+		if res.Reset != tc.wantReset || res.RetryAfter != tc.wantRetryAfter {
+			t.Errorf("%s: got reset %s and retry after %s, want %s and %s",
+				tc.name, res.Reset, res.RetryAfter, tc.wantReset, tc.wantRetryAfter)
+		}
+	}
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	l := Limit{Requests: 2, Per: time.Minute}
+	s := NewMemoryStore()
+
+	s.Take(context.Background(), "a", l, now)
+	s.Take(context.Background(), "b", l, now)
+	s.Take(context.Background(), "b", l, now.Add(time.Minute))
+
+	if got := len(s.buckets); got != 1 {
+		t.Errorf("got %d buckets, want 1 as the bucket of a refilled", got)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	return Result{}, errors.New("store unavailable")
+}
+
+func TestLimiter_ServeHTTP(t *testing.T) {
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	rules := []Rule{
+		{Name: "create", Method: "POST", Path: "/api/links", Limit: Limit{Requests: 1, Per: time.Minute}},
+		{Name: "api", Path: "/api/", Limit: Limit{Requests: 2, Per: time.Minute}},
+	}
+
+	limiter := New(NewMemoryStore(), rules)
+	limiter.now = func() time.Time { return now }
+
+	byAddress := NewByAddress(NewMemoryStore(), rules)
+	byAddress.now = func() time.Time { return now }
+
+	var testCases = []struct {
+		name        string
+		limiter     Limiter
+		method      string
+		path        string
+		userID      string
+		remoteAddr  string
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			name: "User within the route limit", limiter: limiter,
+			method: "POST", path: "/api/links", userID: "user-1",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0", "RateLimit-Reset": "60"},
+		},
+		{
+			name: "User over the route limit", limiter: limiter,
+			method: "POST", path: "/api/links", userID: "user-1",
+			wantStatus: http.StatusTooManyRequests,
+			wantHeaders: map[string]string{"RateLimit-Limit": "1", "RateLimit-Remaining": "0",
+				"RateLimit-Reset": "60", "Retry-After": "60"},
+		},
+		{
+			name: "Other routes are counted separately", limiter: limiter,
+			method: "GET", path: "/api/links", userID: "user-1",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "1"},
+		},
+		{
+			name: "Other users are counted separately", limiter: limiter,
+			method: "POST", path: "/api/links", userID: "user-2",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Remaining": "0"},
+		},
+		{
+			name: "Anonymous client by address", limiter: limiter,
+			method: "POST", path: "/api/links", remoteAddr: "203.0.113.7:1234",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Remaining": "0"},
+		},
+		{
+			name: "Anonymous client over the limit", limiter: limiter,
+			method: "POST", path: "/api/links", remoteAddr: "203.0.113.7:4321",
+			wantStatus:  http.StatusTooManyRequests,
+			wantHeaders: map[string]string{"Retry-After": "60"},
+		},
+		{
+			name: "Authenticated client by address", limiter: byAddress,
+			method: "POST", path: "/api/links", userID: "user-1", remoteAddr: "203.0.113.8:1234",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Remaining": "0"},
+		},
+		{
+			name: "Other users from the same address share the bucket", limiter: byAddress,
+			method: "POST", path: "/api/links", userID: "user-2", remoteAddr: "203.0.113.8:4321",
+			wantStatus:  http.StatusTooManyRequests,
+			wantHeaders: map[string]string{"Retry-After": "60"},
+		},
+		{
+			name: "Route without limits", limiter: limiter,
+			method: "GET", path: "/healthcheck", userID: "user-1",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": ""},
+		},
+		{
+			name: "Unavailable store", limiter: New(failingStore{}, rules),
+			method: "POST", path: "/api/links", userID: "user-1",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"RateLimit-Limit": ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "https://example.com"+tc.path, nil)
+			if tc.userID != "" {
+				req = middleware.CtxSetUserID(req.Context(), req, tc.userID)
+			}
+			if tc.remoteAddr != "" {
+				req.RemoteAddr = tc.remoteAddr
+			}
+			recorder := httptest.NewRecorder()
+
+			tc.limiter.ServeHTTP(recorder, req, func(w http.ResponseWriter, r *http.Request) {})
+
+			if got := recorder.Code; got != tc.wantStatus {
+				t.Errorf("got status %d, want %d", got, tc.wantStatus)
+			}
+
+			for k, want := range tc.wantHeaders {
+				if got := recorder.Header().Get(k); got != want {
+					t.Errorf("got header %s '%s', want '%s'", k, got, want)
+				}
+			}
+		})
+	}
+}
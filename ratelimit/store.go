@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops buckets which are full again
+const sweepInterval = time.Minute
+
+// Limit allows Requests requests per period, refilled continuously, with bursts of up to Requests
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time left until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time left until a token is available, zero if the request is allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets. Stores shared by several servers implement Take atomically,
+// for instance loading and saving a Bucket within a transaction.
+type Store interface {
+	Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error)
+}
+
+// Bucket is the state of a token bucket
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// NewBucket returns a full bucket for the limit
+func NewBucket(l Limit, now time.Time) Bucket {
+	return Bucket{Tokens: float64(l.Requests), Updated: now}
+}
+
+// Take refills the bucket up to now and takes a token from it if available
+func (b *Bucket) Take(l Limit, now time.Time) Result {
+	if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(l.Requests), b.Tokens+elapsed*l.rate())
+		b.Updated = now
+	}
+
+	res := Result{Limit: l.Requests}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.Tokens) / l.rate())
+	}
+
+	res.Remaining = int(b.Tokens)
+	res.Reset = seconds((float64(l.Requests) - b.Tokens) / l.rate())
+
+	return res
+}
+
+func (b Bucket) full(l Limit, now time.Time) bool {
+	return b.Tokens+now.Sub(b.Updated).Seconds()*l.rate() >= float64(l.Requests)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type memoryBucket struct {
+	Bucket
+	limit Limit
+}
+
+// MemoryStore keeps the buckets in memory, limits only apply to a single server
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take implements the Store interface
+func (s *MemoryStore) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{Bucket: NewBucket(l, now), limit: l}
+		s.buckets[key] = b
+	}
+	b.limit = l
+
+	return b.Take(l, now), nil
+}
+
+// sweep drops the buckets which refilled, as they are the same as new ones
+func (s *MemoryStore) sweep(now time.Time) {
+	for k, b := range s.buckets {
+		if b.full(b.limit, now) {
+			delete(s.buckets, k)
+		}
+	}
+	s.lastSweep = now
+}
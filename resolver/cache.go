@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+)
+
+// maxCacheEntries is the number of entries kept, the oldest ones are evicted first
+const maxCacheEntries = 1000
+
+type cacheEntry struct {
+	trackURL  string
+	platforms []models.Platform
+	expireAt  time.Time
+}
+
+// Cache wraps a Resolver and keeps its successful results in memory for the given ttl,
+// up to maxCacheEntries results
+type Cache struct {
+	resolver Resolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds the entries from the oldest, which is the first to expire as all share the ttl
+	order *list.List
+}
+
+// NewCache returns a new Cache around the given resolver
+func NewCache(r Resolver, ttl time.Duration) *Cache {
+	return &Cache{
+		resolver: r,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Resolve implements the Resolver interface
+func (c *Cache) Resolve(ctx context.Context, trackURL string) ([]models.Platform, error) {
+	if platforms, ok := c.get(trackURL); ok {
+		return platforms, nil
+	}
+
+	platforms, err := c.resolver.Resolve(ctx, trackURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c.set(trackURL, platforms)
+
+	return copyPlatforms(platforms), nil
+}
+
+func (c *Cache) get(trackURL string) ([]models.Platform, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[trackURL]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	if !c.now().Before(entry.expireAt) {
+		return nil, false
+	}
+
+	return copyPlatforms(entry.platforms), true
+}
+
+func (c *Cache) set(trackURL string, platforms []models.Platform) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if el, ok := c.entries[trackURL]; ok {
+		c.order.Remove(el)
+		delete(c.entries, trackURL)
+	}
+
+	// Expired entries are swept, and the oldest evicted to make room
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		entry := front.Value.(*cacheEntry)
+		if c.order.Len() < maxCacheEntries && now.Before(entry.expireAt) {
+			break
+		}
+
+		c.order.Remove(front)
+		delete(c.entries, entry.trackURL)
+	}
+
+	c.entries[trackURL] = c.order.PushBack(&cacheEntry{
+		trackURL:  trackURL,
+		platforms: copyPlatforms(platforms),
+		expireAt:  now.Add(c.ttl),
+	})
+}
+
+// copyPlatforms prevents callers from altering the cached slice
+func copyPlatforms(platforms []models.Platform) []models.Platform {
+	return append([]models.Platform(nil), platforms...)
+}
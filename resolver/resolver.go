@@ -0,0 +1,118 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+)
+
+// ErrNotFound is returned when the service does not recognise the given track url
+var ErrNotFound = errors.New("track url could not be resolved")
+
+// platformNames maps the platform keys returned by the service to display names.
+// Unknown keys are returned as they are.
+var platformNames = map[string]string{
+	"amazonMusic":  "Amazon Music",
+	"appleMusic":   "Apple Music",
+	"deezer":       "Deezer",
+	"itunes":       "iTunes",
+	"napster":      "Napster",
+	"pandora":      "Pandora",
+	"soundcloud":   "SoundCloud",
+	"spotify":      "Spotify",
+	"tidal":        "Tidal",
+	"youtube":      "YouTube",
+	"youtubeMusic": "YouTube Music",
+}
+
+// Resolver returns the platforms a track is available on given the url of the track
+// on any one of them
+type Resolver interface {
+	Resolve(ctx context.Context, trackURL string) ([]models.Platform, error)
+}
+
+// HTTPResolver resolves track urls through an external http service.
+// The service is queried with `GET <baseURL>?url=<trackURL>` and is expected to reply with
+// a `linksByPlatform` object keyed by platform, as in the song.link api.
+type HTTPResolver struct {
+	baseURL string
+	client  *http.Client
+}
+
+type serviceResponse struct {
+	LinksByPlatform map[string]struct {
+		URL string `json:"url"`
+	} `json:"linksByPlatform"`
+}
+
+// NewHTTP returns a new HTTPResolver querying the service at baseURL.
+// If client is nil http.DefaultClient is used.
+func NewHTTP(baseURL string, client *http.Client) *HTTPResolver {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPResolver{baseURL: baseURL, client: client}
+}
+
+// Resolve implements the Resolver interface
+func (r *HTTPResolver) Resolve(ctx context.Context, trackURL string) ([]models.Platform, error) {
+	u, err := url.Parse(r.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("url", trackURL)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("resolver service replied with status %d", resp.StatusCode)
+	}
+
+	var sr serviceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return nil, err
+	}
+
+	platforms := make([]models.Platform, 0, len(sr.LinksByPlatform))
+	for key, link := range sr.LinksByPlatform {
+		if link.URL == "" {
+			continue
+		}
+
+		name, ok := platformNames[key]
+		if !ok {
+			name = key
+		}
+
+		platforms = append(platforms, models.Platform{Name: name, URL: link.URL})
+	}
+
+	sort.Slice(platforms, func(i, j int) bool {
+		return platforms[i].Name < platforms[j].Name
+	})
+
+	return platforms, nil
+}
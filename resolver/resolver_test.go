@@ -0,0 +1,151 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/alessio-palumbo/linktree-challenge/handlers/models"
+)
+
+var spotifyURL = "https://open.spotify.com/track/3U4isOIWM3VvDubwSI3y7a"
+
+func TestHTTPResolver_Resolve(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("url") {
+		case spotifyURL:
+			fmt.Fprint(w, `{"linksByPlatform":{`+
+				`"spotify":{"url":"https://open.spotify.com/track/3U4isOIWM3VvDubwSI3y7a"},`+
+				`"appleMusic":{"url":"https://music.apple.com/au/album/all-of-me/1440841219"},`+
+				`"napster":{"url":""},`+
+				`"audiomack":{"url":"https://audiomack.com/johnlegend/song/all-of-me"}}}`)
+		case "https://unknown.com":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	var testCases = []struct {
+		name     string
+		trackURL string
+		want     []models.Platform
+		wantErr  error
+	}{
+		{
+			name:     "Known track",
+			trackURL: spotifyURL,
+			want: []models.Platform{
+				{Name: "Apple Music", URL: "https://music.apple.com/au/album/all-of-me/1440841219"},
+				{Name: "Spotify", URL: spotifyURL},
+				{Name: "audiomack", URL: "https://audiomack.com/johnlegend/song/all-of-me"},
+			},
+		},
+		{
+			name:     "Unknown track",
+			trackURL: "https://unknown.com",
+			wantErr:  ErrNotFound,
+		},
+		{
+			name:     "Service failure",
+			trackURL: "https://failure.com",
+			wantErr:  fmt.Errorf("resolver service replied with status 500"),
+		},
+	}
+
+	r := NewHTTP(ts.URL, nil)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := r.Resolve(context.Background(), tc.trackURL)
+			if fmt.Sprint(err) != fmt.Sprint(tc.wantErr) {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+
+			if diff := cmp.Diff(got, tc.want); tc.wantErr == nil && diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+type countingResolver struct {
+	calls int
+}
+
+func (r *countingResolver) Resolve(ctx context.Context, trackURL string) ([]models.Platform, error) {
+	r.calls++
+	if trackURL == "" {
+		return nil, ErrNotFound
+	}
+	return []models.Platform{{Name: "Spotify", URL: trackURL}}, nil
+}
+
+func TestCache_Resolve(t *testing.T) {
+	now := time.Now()
+
+	var testCases = []struct {
+		name      string
+		trackURL  string
+		elapsed   time.Duration
+		wantCalls int
+	}{
+		{"First lookup", spotifyURL, 0, 1},
+		{"Cached lookup", spotifyURL, time.Minute, 1},
+		{"Expired lookup", spotifyURL, time.Hour, 2},
+		{"Failed lookup", "", 0, 3},
+		{"Failed lookups are not cached", "", 0, 4},
+	}
+
+	r := &countingResolver{}
+	c := NewCache(r, 10*time.Minute)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.elapsed)
+			c.now = func() time.Time { return now }
+
+			c.Resolve(context.Background(), tc.trackURL)
+
+			if got := r.calls; got != tc.wantCalls {
+				t.Errorf("got %d calls, want %d", got, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	now := time.Now()
+
+	r := &countingResolver{}
+	c := NewCache(r, 10*time.Minute)
+	c.now = func() time.Time { return now }
+
+	// The oldest entry is evicted once the cache is full
+	for i := 0; i <= maxCacheEntries; i++ {
+		c.Resolve(context.Background(), fmt.Sprintf("%s?i=%d", spotifyURL, i))
+	}
+
+	if got := len(c.entries); got != maxCacheEntries {
+		t.Errorf("got %d entries, want %d", got, maxCacheEntries)
+	}
+
+	c.Resolve(context.Background(), spotifyURL+"?i=1")
+	c.Resolve(context.Background(), spotifyURL+"?i=0")
+	if got, want := r.calls, maxCacheEntries+2; got != want {
+		t.Errorf("got %d calls, want %d", got, want)
+	}
+
+	// Expired entries are swept
+	now = now.Add(time.Hour)
+	c.Resolve(context.Background(), spotifyURL)
+	if got := len(c.entries); got != 1 {
+		t.Errorf("got %d entries, want 1", got)
+	}
+}
@@ -0,0 +1,122 @@
+package screening
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Action is the outcome of screening a url
+type Action string
+
+// Actions ordered by severity
+const (
+	ActionAllow      Action = "allow"
+	ActionQuarantine Action = "quarantine"
+	ActionReject     Action = "reject"
+)
+
+var severity = map[Action]int{
+	ActionAllow:      0,
+	ActionQuarantine: 1,
+	ActionReject:     2,
+}
+
+type ruleKind string
+
+const (
+	kindDomain  ruleKind = "domain"
+	kindPattern ruleKind = "pattern"
+)
+
+// Rule matches urls either by domain, including its subdomains, or by a regular expression
+// applied to the whole url
+type Rule struct {
+	Action Action
+	Kind   ruleKind
+	Value  string
+
+	re *regexp.Regexp
+}
+
+// match reports whether the rule matches the given url and host
+func (r Rule) match(u, host string) bool {
+	switch r.Kind {
+	case kindDomain:
+		return host == r.Value || strings.HasSuffix(host, "."+r.Value)
+	case kindPattern:
+		return r.re.MatchString(u)
+	}
+
+	return false
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s %s", r.Action, r.Kind, r.Value)
+}
+
+// LoadRules reads the rules from the blocklist file at path
+func LoadRules(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRules(f)
+}
+
+// ParseRules reads a blocklist with one rule per line in the format `<action> <kind> <value>`,
+// e.g. `quarantine pattern (?i)free-gift`. A line with a single value is a domain to reject.
+// Empty lines and lines starting with `#` are ignored.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		var rule Rule
+		switch len(fields) {
+		case 1:
+			rule = Rule{Action: ActionReject, Kind: kindDomain, Value: fields[0]}
+		case 2:
+			return nil, fmt.Errorf("blocklist line %d: missing rule value", n)
+		default:
+			rule = Rule{
+				Action: Action(fields[0]),
+				Kind:   ruleKind(fields[1]),
+				Value:  strings.Join(fields[2:], " "),
+			}
+		}
+
+		if rule.Action != ActionReject && rule.Action != ActionQuarantine {
+			return nil, fmt.Errorf("blocklist line %d: unknown action %q", n, rule.Action)
+		}
+
+		switch rule.Kind {
+		case kindDomain:
+			rule.Value = strings.TrimSuffix(strings.ToLower(rule.Value), ".")
+		case kindPattern:
+			re, err := regexp.Compile(rule.Value)
+			if err != nil {
+				return nil, fmt.Errorf("blocklist line %d: %v", n, err)
+			}
+			rule.re = re
+		default:
+			return nil, fmt.Errorf("blocklist line %d: unknown rule kind %q", n, rule.Kind)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
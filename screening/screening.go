@@ -0,0 +1,250 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var errPrivateAddress = errors.New("screening: refusing to connect to a private address")
+
+var privateBlocks = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	blocks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		blocks[i] = block
+	}
+
+	return blocks
+}
+
+// Verdict is the result of screening a set of urls
+type Verdict struct {
+	Action Action
+	// URL is the screened url which triggered the verdict
+	URL string
+	// MatchedURL is the url matching the rule, either URL or one of its redirects
+	MatchedURL string
+	Rule       string
+	Reason     string
+}
+
+// Screener checks urls submitted by users against the moderation rules
+type Screener interface {
+	Screen(ctx context.Context, urls []string) (Verdict, error)
+}
+
+// maxConcurrent is the number of urls whose redirects are followed at the same time
+const maxConcurrent = 8
+
+// RuleScreener screens urls against a list of rules, following their redirects up to
+// a maximum depth so that shortened or masked urls are caught too.
+type RuleScreener struct {
+	rules        []Rule
+	client       *http.Client
+	maxRedirects int
+	timeout      time.Duration
+}
+
+// New returns a RuleScreener which follows at most maxRedirects redirects per url, within
+// timeout for all the urls of a screen. If client is nil a client refusing to connect to
+// private addresses is used.
+func New(rules []Rule, maxRedirects int, timeout time.Duration, client *http.Client) *RuleScreener {
+	if client == nil {
+		client = NewClient(timeout)
+	}
+
+	// Redirects are followed one by one by the screener
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &RuleScreener{rules: rules, client: &c, maxRedirects: maxRedirects, timeout: timeout}
+}
+
+// NewClient returns an http client with the given timeout which refuses to connect to
+// loopback, private and link-local addresses.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || isPrivate(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+func isPrivate(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, block := range privateBlocks {
+		if block.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Screen implements the Screener interface. It returns the most severe verdict
+// among the given urls, the first one in their order if several are as severe.
+// Urls are screened concurrently within the timeout of the screener, and those whose
+// redirects cannot be followed in time are screened on the redirects reached.
+func (s *RuleScreener) Screen(ctx context.Context, urls []string) (Verdict, error) {
+	hopCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var (
+		verdicts = make([]Verdict, len(urls))
+		indexes  = make(chan int)
+		wg       sync.WaitGroup
+	)
+
+	workers := maxConcurrent
+	if len(urls) < workers {
+		workers = len(urls)
+	}
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				verdicts[i] = s.screenURL(hopCtx, urls[i])
+
+				// No redirect needs following once a url is rejected
+				if verdicts[i].Action == ActionReject {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := range urls {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	// A request cancelled by its client is not screened
+	if err := ctx.Err(); err != nil {
+		return Verdict{Action: ActionAllow}, err
+	}
+
+	verdict := Verdict{Action: ActionAllow}
+	for _, v := range verdicts {
+		if severity[v.Action] > severity[verdict.Action] {
+			verdict = v
+		}
+	}
+
+	return verdict, nil
+}
+
+// screenURL matches the url and its redirect chain against the rules
+func (s *RuleScreener) screenURL(ctx context.Context, rawURL string) Verdict {
+	verdict := Verdict{Action: ActionAllow}
+	current := rawURL
+
+	for hop := 0; ; hop++ {
+		if v, ok := s.match(current); ok && severity[v.Action] > severity[verdict.Action] {
+			v.URL = rawURL
+			if hop > 0 {
+				v.Reason += fmt.Sprintf(" after %d redirect(s)", hop)
+			}
+			verdict = v
+		}
+
+		if verdict.Action == ActionReject || hop >= s.maxRedirects {
+			return verdict
+		}
+
+		next, err := s.redirect(ctx, current)
+		if err != nil || next == "" {
+			// Unreachable urls are screened on the redirects reached only
+			return verdict
+		}
+		current = next
+	}
+}
+
+// match returns the verdict of the most severe rule matching the url
+func (s *RuleScreener) match(rawURL string) (Verdict, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Verdict{}, false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	var (
+		verdict Verdict
+		matched bool
+	)
+	for _, r := range s.rules {
+		if !r.match(rawURL, host) || (matched && severity[r.Action] <= severity[verdict.Action]) {
+			continue
+		}
+
+		matched = true
+		verdict = Verdict{
+			Action:     r.Action,
+			MatchedURL: rawURL,
+			Rule:       r.String(),
+			Reason:     fmt.Sprintf("url matches blocklisted %s %s", r.Kind, r.Value),
+		}
+	}
+
+	return verdict, matched
+}
+
+// redirect returns the location the url redirects to, if any
+func (s *RuleScreener) redirect(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequest("HEAD", rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return "", nil
+	}
+
+	loc, err := resp.Location()
+	if err != nil {
+		return "", nil
+	}
+
+	return loc.String(), nil
+}
@@ -0,0 +1,192 @@
+package screening
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const blocklist = `
+# Known phishing domains
+phishing.example
+reject domain malware.example
+
+quarantine domain short.example
+quarantine pattern (?i)free-?gift
+`
+
+func TestParseRules(t *testing.T) {
+
+	testCases := []struct {
+		name      string
+		blocklist string
+		wantRules int
+		wantErr   string
+	}{
+		{
+			name:      "Valid blocklist",
+			blocklist: blocklist,
+			wantRules: 4,
+		},
+		{
+			name:      "Unknown action",
+			blocklist: "allow domain linktr.ee",
+			wantErr:   `blocklist line 1: unknown action "allow"`,
+		},
+		{
+			name:      "Unknown kind",
+			blocklist: "\nreject host linktr.ee",
+			wantErr:   `blocklist line 2: unknown rule kind "host"`,
+		},
+		{
+			name:      "Missing value",
+			blocklist: "reject domain",
+			wantErr:   "blocklist line 1: missing rule value",
+		},
+		{
+			name:      "Invalid pattern",
+			blocklist: "reject pattern (free",
+			wantErr:   "blocklist line 1: error parsing regexp: missing closing ): `(free`",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := ParseRules(strings.NewReader(tc.blocklist))
+			if err != nil && err.Error() != tc.wantErr || err == nil && tc.wantErr != "" {
+				t.Errorf("got error %v, want %s", err, tc.wantErr)
+			}
+
+			if got := len(rules); got != tc.wantRules {
+				t.Errorf("got %d rules, want %d", got, tc.wantRules)
+			}
+		})
+	}
+}
+
+func TestRuleScreener_Screen(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(blocklist))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/phishing":
+			http.Redirect(w, r, "https://login.phishing.example/account", http.StatusFound)
+		case "/gift":
+			http.Redirect(w, r, "/free-gift", http.StatusMovedPermanently)
+		case "/deep":
+			http.Redirect(w, r, ts.URL+"/deeper", http.StatusFound)
+		case "/deeper":
+			http.Redirect(w, r, ts.URL+"/phishing", http.StatusFound)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			http.Redirect(w, r, "https://phishing.example", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name       string
+		urls       []string
+		want       Action
+		wantReason string
+	}{
+		{
+			name: "Clean urls",
+			urls: []string{"https://linktr.ee", ts.URL + "/clean"},
+			want: ActionAllow,
+		},
+		{
+			name:       "Blocklisted subdomain",
+			urls:       []string{"https://linktr.ee", "https://www.Malware.example/download"},
+			want:       ActionReject,
+			wantReason: "url matches blocklisted domain malware.example",
+		},
+		{
+			name:       "Quarantined pattern",
+			urls:       []string{"https://shop.example/FreeGift"},
+			want:       ActionQuarantine,
+			wantReason: "url matches blocklisted pattern (?i)free-?gift",
+		},
+		{
+			name:       "Reject takes precedence over quarantine",
+			urls:       []string{"https://short.example/x", "https://phishing.example"},
+			want:       ActionReject,
+			wantReason: "url matches blocklisted domain phishing.example",
+		},
+		{
+			name:       "Blocklisted redirect",
+			urls:       []string{ts.URL + "/phishing"},
+			want:       ActionReject,
+			wantReason: "url matches blocklisted domain phishing.example after 1 redirect(s)",
+		},
+		{
+			name:       "Relative redirect",
+			urls:       []string{ts.URL + "/gift"},
+			want:       ActionQuarantine,
+			wantReason: "url matches blocklisted pattern (?i)free-?gift after 1 redirect(s)",
+		},
+		{
+			name: "Redirects beyond max depth",
+			urls: []string{ts.URL + "/deep"},
+			want: ActionAllow,
+		},
+		{
+			name:       "Redirects past the timeout",
+			urls:       []string{ts.URL + "/slow", ts.URL + "/slow", "https://shop.example/free-gift"},
+			want:       ActionQuarantine,
+			wantReason: "url matches blocklisted pattern (?i)free-?gift",
+		},
+		{
+			name:       "First of the most severe verdicts",
+			urls:       []string{ts.URL + "/slow", "https://short.example", "https://malware.example", "https://phishing.example"},
+			want:       ActionReject,
+			wantReason: "url matches blocklisted domain malware.example",
+		},
+	}
+
+	s := New(rules, 2, 200*time.Millisecond, ts.Client())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := s.Screen(context.Background(), tc.urls)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if v.Action != tc.want {
+				t.Errorf("got action %s, want %s", v.Action, tc.want)
+			}
+
+			if v.Reason != tc.wantReason {
+				t.Errorf("got reason %s, want %s", v.Reason, tc.wantReason)
+			}
+		})
+	}
+
+	// Requests cancelled by their client are not screened
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Screen(ctx, []string{ts.URL + "/slow"}); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	_, err := NewClient(0).Get(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), errPrivateAddress.Error()) {
+		t.Errorf("got error %v, want %v", err, errPrivateAddress)
+	}
+}
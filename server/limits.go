@@ -0,0 +1,23 @@
+package server
+
+import (
+	"time"
+
+	"github.com/alessio-palumbo/linktree-challenge/ratelimit"
+)
+
+// rateLimits are the limits of each route per user, or per address for anonymous requests.
+// The first rule matching a request applies, so specific rules come first.
+var rateLimits = []ratelimit.Rule{
+	{Name: "auth", Method: "POST", Path: "/auth/", Limit: ratelimit.Limit{Requests: 10, Per: time.Minute}},
+	{Name: "links-create", Method: "POST", Path: "/api/links", Limit: ratelimit.Limit{Requests: 30, Per: time.Minute}},
+	{Name: "redirect", Path: "/r/", Limit: ratelimit.Limit{Requests: 600, Per: time.Minute}},
+	{Name: "public", Path: "/public/", Limit: ratelimit.Limit{Requests: 600, Per: time.Minute}},
+	{Name: "api", Path: "/api/", Limit: ratelimit.Limit{Requests: 300, Per: time.Minute}},
+}
+
+// addressLimits are the limits of each route per client address, applied before authentication
+// so that requests with invalid tokens are limited too. They allow several users behind one address.
+var addressLimits = []ratelimit.Rule{
+	{Name: "api-address", Path: "/api/", Limit: ratelimit.Limit{Requests: 1200, Per: time.Minute}},
+}
@@ -5,11 +5,20 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"github.com/urfave/negroni"
+	"github.com/urfave/negroni/v3"
 
 	e "github.com/alessio-palumbo/linktree-challenge/errors"
 	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/analytics"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/apikeys"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/auditlog"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/auth"
 	"github.com/alessio-palumbo/linktree-challenge/handlers/links"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/profiles"
+	"github.com/alessio-palumbo/linktree-challenge/handlers/teams"
+	"github.com/alessio-palumbo/linktree-challenge/idempotency"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/ratelimit"
 )
 
 // New returns a handler to serve the links api.
@@ -34,23 +43,62 @@ func New(g handlers.Group) http.Handler {
 		next(w, r)
 	})
 
-	// Add authentication to middleware chain
-	n.Use(g.Auth)
-
 	// Add multiplexer and register routes
 	router := mux.NewRouter()
 
-	linksSB := router.
+	// Requests are rate limited per route, with buckets kept in memory unless a shared store is set
+	store := g.RateStore
+	if store == nil {
+		store = ratelimit.NewMemoryStore()
+	}
+	limiter := ratelimit.New(store, rateLimits)
+	addressLimiter := ratelimit.NewByAddress(store, addressLimits)
+
+	// Authentication routes, reachable without a token
+	authRouter := mux.NewRouter()
+
+	authSB := authRouter.
+		PathPrefix("/auth").
+		Subrouter()
+
+	authSB.Handle("/token", auth.TokenHandler(g)).Methods("POST")
+	authSB.Handle("/refresh", auth.RefreshHandler(g)).Methods("POST")
+
+	// Api routes, behind the authentication middleware
+	api := mux.NewRouter()
+
+	linksSB := api.
 		PathPrefix("/api/links").
 		Subrouter()
 
-	linksSB.Handle("", links.IndexHandler(g)).Methods("GET")
-	linksSB.Handle("/{link_id}", nil).Methods("GET")
-	linksSB.Handle("", links.PostHandler(g)).Methods("POST")
-	linksSB.Handle("/{link_id}", nil).Methods("PUT")
-	linksSB.Handle("/{link_id}", nil).Methods("DELETE")
+	linksSB.Handle("", middleware.RequireScope(middleware.ScopeLinksRead, links.IndexHandler(g))).Methods("GET")
+	linksSB.Handle("/analytics", middleware.RequireScope(middleware.ScopeAnalyticsRead, analytics.IndexHandler(g))).Methods("GET")
+	linksSB.Handle("/analytics/export", middleware.RequireScope(middleware.ScopeAnalyticsRead,
+		analytics.ExportHandler(g))).Methods("GET")
+	linksSB.Handle("/trash", middleware.RequireScope(middleware.ScopeLinksRead, links.TrashHandler(g))).Methods("GET")
+	linksSB.Handle("/{link_id}", middleware.RequireScope(middleware.ScopeLinksRead, links.ShowHandler(g))).Methods("GET")
+	linksSB.Handle("", middleware.RequireScope(middleware.ScopeLinksWrite,
+		idempotency.Handler(g.DB, links.PostHandler(g)))).Methods("POST")
+	linksSB.Handle("/{link_id}", middleware.RequireScope(middleware.ScopeLinksWrite, links.PutHandler(g))).Methods("PUT")
+	linksSB.Handle("/{link_id}", middleware.RequireScope(middleware.ScopeLinksWrite, links.PatchHandler(g))).Methods("PATCH")
+	linksSB.Handle("/{link_id}", middleware.RequireScope(middleware.ScopeLinksWrite, links.DeleteHandler(g))).Methods("DELETE")
+	linksSB.Handle("/{link_id}/restore", middleware.RequireScope(middleware.ScopeLinksWrite, links.RestoreHandler(g))).Methods("POST")
+	linksSB.Handle("/{link_id}/qr.{format:png|svg}", middleware.RequireScope(middleware.ScopeLinksRead, links.QRHandler(g))).Methods("GET")
+	linksSB.Handle("/{link_id}/revisions", middleware.RequireScope(middleware.ScopeLinksRead, links.RevisionsHandler(g))).Methods("GET")
+	linksSB.Handle("/{link_id}/revisions/{revision_id}/restore",
+		middleware.RequireScope(middleware.ScopeLinksWrite, links.RevisionRestoreHandler(g))).Methods("POST")
 
-	sublinksSB := router.
+	profileSB := api.
+		PathPrefix("/api/profile").
+		Subrouter()
+
+	profileSB.Handle("", middleware.RequireScope(middleware.ScopeLinksRead, profiles.DetailsHandler(g))).Methods("GET")
+	profileSB.Handle("", middleware.RequireScope(middleware.ScopeLinksWrite, profiles.DetailsPutHandler(g))).Methods("PUT")
+	profileSB.Handle("/appearance", middleware.RequireScope(middleware.ScopeLinksRead, profiles.AppearanceHandler(g))).Methods("GET")
+	profileSB.Handle("/appearance", middleware.RequireScope(middleware.ScopeLinksWrite,
+		profiles.AppearancePutHandler(g))).Methods("PUT")
+
+	sublinksSB := api.
 		PathPrefix("/api/sublinks").
 		Subrouter()
 
@@ -58,6 +106,60 @@ func New(g handlers.Group) http.Handler {
 	sublinksSB.Handle("/{link_id}", nil).Methods("PUT")
 	sublinksSB.Handle("/{link_id}", nil).Methods("DELETE")
 
+	// Credentials are managed with session tokens only
+	sessionsSB := api.
+		PathPrefix("/api/sessions").
+		Subrouter()
+
+	sessionsSB.Handle("", middleware.RequireSession(auth.SessionsHandler(g))).Methods("GET")
+	sessionsSB.Handle("", middleware.RequireSession(auth.RevokeHandler(g))).Methods("DELETE")
+	sessionsSB.Handle("/{session_id}", middleware.RequireSession(auth.RevokeHandler(g))).Methods("DELETE")
+
+	keysSB := api.
+		PathPrefix("/api/keys").
+		Subrouter()
+
+	keysSB.Handle("", middleware.RequireSession(apikeys.IndexHandler(g))).Methods("GET")
+	keysSB.Handle("", middleware.RequireSession(apikeys.PostHandler(g))).Methods("POST")
+	keysSB.Handle("/{key_id}", middleware.RequireSession(apikeys.DeleteHandler(g))).Methods("DELETE")
+
+	teamsSB := api.
+		PathPrefix("/api/teams").
+		Subrouter()
+
+	teamsSB.Handle("", middleware.RequireSession(teams.IndexHandler(g))).Methods("GET")
+	teamsSB.Handle("", middleware.RequireSession(teams.PostHandler(g))).Methods("POST")
+	teamsSB.Handle("/{team_id}/members/{user_id}", middleware.RequireSession(teams.MemberPutHandler(g))).Methods("PUT")
+	teamsSB.Handle("/{team_id}/members/{user_id}", middleware.RequireSession(teams.MemberDeleteHandler(g))).Methods("DELETE")
+	teamsSB.Handle("/{team_id}/accept", middleware.RequireSession(teams.AcceptHandler(g))).Methods("POST")
+
+	// The audit log is read with session tokens only
+	auditSB := api.
+		PathPrefix("/api/audit").
+		Subrouter()
+
+	auditSB.Handle("", middleware.RequireSession(auditlog.IndexHandler(g))).Methods("GET")
+	auditSB.Handle("/export", middleware.RequireSession(auditlog.ExportHandler(g))).Methods("GET")
+
+	// Public redirects recording clicks on links, and public profiles
+	publicRouter := mux.NewRouter()
+
+	publicRouter.Handle("/r/{link_id}", links.RedirectHandler(g)).Methods("GET")
+	publicRouter.Handle("/r/{link_id}/{sublink_id}", links.RedirectHandler(g)).Methods("GET")
+	publicRouter.Handle("/public/{username}", links.PublicHandler(g)).Methods("GET")
+	publicRouter.Handle("/public/{username}/qr.{format:png|svg}", links.PublicQRHandler(g)).Methods("GET")
+
+	router.PathPrefix("/auth").Handler(negroni.New(limiter, negroni.Wrap(authRouter)))
+
+	router.PathPrefix("/r/").Handler(negroni.New(limiter, negroni.Wrap(publicRouter)))
+	router.PathPrefix("/public/").Handler(negroni.New(limiter, negroni.Wrap(publicRouter)))
+
+	// Requests act on the profile selected by the Linktree-Profile header, if allowed,
+	// and are recorded in the audit log if they change data.
+	// Clients are limited by address before the token is checked, then per user.
+	router.PathPrefix("/api").Handler(negroni.New(addressLimiter, g.Auth, limiter, middleware.NewProfile(g.DB),
+		middleware.NewAudit(g.Audit), negroni.Wrap(api)))
+
 	n.UseHandler(router)
 
 	return n
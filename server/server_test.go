@@ -8,6 +8,9 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/alessio-palumbo/linktree-challenge/handlers"
+	"github.com/alessio-palumbo/linktree-challenge/middleware"
+	"github.com/alessio-palumbo/linktree-challenge/tokens"
+	"github.com/alessio-palumbo/linktree-challenge/validator"
 )
 
 func TestNew(t *testing.T) {
@@ -27,6 +30,8 @@ func TestNew(t *testing.T) {
 		want         int
 	}{
 		{"status internal", "__TOKEN__", "GET", "/healthcheck", http.StatusOK},
+		{"api requires a token", "", "GET", "/api/links", http.StatusUnauthorized},
+		{"auth routes do not require a token", "", "POST", "/auth/token", http.StatusBadRequest},
 	}
 
 	for _, tc := range testCases {
@@ -36,7 +41,8 @@ func TestNew(t *testing.T) {
 			req.Header.Set("Authorization", "Bearer "+tc.token)
 			recorder := httptest.NewRecorder()
 
-			New(handlers.Group{DB: db}).ServeHTTP(recorder, req)
+			g := handlers.Group{DB: db, Auth: middleware.NewAuth(db, tokens.Hasher{}), Validator: validator.New()}
+			New(g).ServeHTTP(recorder, req)
 
 			if got := recorder.Code; got != tc.want {
 				t.Errorf("got status %d, want %d", got, tc.want)
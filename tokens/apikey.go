@@ -0,0 +1,32 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	// APIKeyPrefix marks personal API keys, telling them apart from session tokens
+	APIKeyPrefix = "lk_"
+
+	apiKeySize = 32
+	// apiKeyDisplayLen is the number of characters of a key shown when listing keys
+	apiKeyDisplayLen = len(APIKeyPrefix) + 8
+)
+
+// NewAPIKey returns a new random API key and its non secret display prefix
+func NewAPIKey() (key, prefix string, err error) {
+	b := make([]byte, apiKeySize)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:apiKeyDisplayLen], nil
+}
+
+// IsAPIKey reports whether the token has the shape of an API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -0,0 +1,52 @@
+package tokens
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+)
+
+// MinHashKeySize is the minimum size in bytes of the key used to hash stored tokens
+const MinHashKeySize = 32
+
+// ErrHashKeySize is returned when the hash key is too short
+var ErrHashKeySize = errors.New("token hash key must be at least 32 bytes")
+
+// Hasher computes the keyed hash (HMAC-SHA256) stored in place of opaque tokens,
+// so that a leaked database does not leak usable tokens.
+type Hasher struct {
+	key []byte
+}
+
+// NewHasher returns a Hasher with the given key
+func NewHasher(key []byte) (Hasher, error) {
+	if len(key) < MinHashKeySize {
+		return Hasher{}, ErrHashKeySize
+	}
+
+	return Hasher{key: key}, nil
+}
+
+// LoadHasher returns a Hasher with the key read from the file at path, ignoring surrounding whitespace
+func LoadHasher(path string) (Hasher, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Hasher{}, err
+	}
+
+	return NewHasher(bytes.TrimSpace(key))
+}
+
+// Hash returns the keyed hash of token
+func (h Hasher) Hash(token string) []byte {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+// Equal reports whether hash is the hash of token, in constant time
+func (h Hasher) Equal(token string, hash []byte) bool {
+	return hmac.Equal(h.Hash(token), hash)
+}
@@ -0,0 +1,38 @@
+package tokens
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestHasher(t *testing.T) {
+	if _, err := NewHasher([]byte("short")); err != ErrHashKeySize {
+		t.Errorf("got error %v, want %v", err, ErrHashKeySize)
+	}
+
+	h, err := NewHasher([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := "15fe817c-72d7-49c1-bffc-0257dbd263e3"
+
+	// Matches hmac(token, key, 'sha256') computed by pgcrypto when migrating existing tokens
+	want := "c1c003533e9cb8a9bd093454554c4f6d8506412b3f42a81448692de8959d00f5"
+	if got := hex.EncodeToString(h.Hash(token)); got != want {
+		t.Errorf("got hash %s, want %s", got, want)
+	}
+
+	if !h.Equal(token, h.Hash(token)) {
+		t.Errorf("got token not matching its own hash, want a match")
+	}
+
+	if h.Equal("05de14de-537a-4819-814a-85ec6c66dd35", h.Hash(token)) {
+		t.Errorf("got a different token matching the hash, want no match")
+	}
+
+	other, _ := NewHasher([]byte("fedcba9876543210fedcba9876543210"))
+	if other.Equal(token, h.Hash(token)) {
+		t.Errorf("got hash matching with a different key, want no match")
+	}
+}
@@ -0,0 +1,174 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// JWT validation errors
+var (
+	ErrMalformed  = errors.New("token is malformed")
+	ErrUnknownKey = errors.New("token key is unknown")
+	ErrAlgorithm  = errors.New("token algorithm does not match its key")
+	ErrSignature  = errors.New("token signature is invalid")
+	ErrExpired    = errors.New("token is expired")
+	ErrNotYet     = errors.New("token is not valid yet")
+	ErrNoSigner   = errors.New("no signing key configured")
+)
+
+var b64 = base64.RawURLEncoding
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid"`
+}
+
+// Claims are the JWT claims carried by access tokens
+type Claims struct {
+	// Subject is the ID of the authenticated user
+	Subject string `json:"sub"`
+	// ID is the token id, matching a row of user_tokens when revocation is checked
+	ID        string `json:"jti,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// ClockSkew is the difference tolerated between the clocks of the issuer and of the verifier
+// when checking the time a token was issued at or becomes valid
+const ClockSkew = time.Minute
+
+// IsJWT reports whether the token has the shape of a JWT
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Sign returns a JWT carrying the claims, signed with the signing key of the set
+func (ks *KeySet) Sign(c Claims) (string, error) {
+	k, ok := ks.keys[ks.signing]
+	if !ok {
+		return "", ErrNoSigner
+	}
+
+	h, err := json.Marshal(header{Alg: k.Alg, Typ: "JWT", Kid: k.ID})
+	if err != nil {
+		return "", err
+	}
+
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(p)
+
+	sig, err := k.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + b64.EncodeToString(sig), nil
+}
+
+// Verify checks the signature and validity period of the JWT and returns its claims
+func (ks *KeySet) Verify(token string, now time.Time) (Claims, error) {
+	var c Claims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return c, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return c, ErrMalformed
+	}
+
+	k, ok := ks.keys[h.Kid]
+	if !ok {
+		return c, ErrUnknownKey
+	}
+
+	// The algorithm is bound to the key, never chosen by the token
+	if h.Alg != k.Alg {
+		return c, ErrAlgorithm
+	}
+
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return c, ErrMalformed
+	}
+
+	if !k.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return c, ErrSignature
+	}
+
+	if err := decodeSegment(parts[1], &c); err != nil || c.Subject == "" {
+		return c, ErrMalformed
+	}
+
+	if c.ExpiresAt == 0 || !now.Before(time.Unix(c.ExpiresAt, 0)) {
+		return c, ErrExpired
+	}
+
+	// Tokens issued in the future or not valid yet are rejected, past the tolerated clock skew
+	latest := now.Add(ClockSkew)
+	if c.IssuedAt != 0 && latest.Before(time.Unix(c.IssuedAt, 0)) ||
+		c.NotBefore != 0 && latest.Before(time.Unix(c.NotBefore, 0)) {
+		return c, ErrNotYet
+	}
+
+	return c, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := b64.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func (k Key) sign(input []byte) ([]byte, error) {
+	switch k.Alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case AlgRS256:
+		digest := sha256.Sum256(input)
+		return rsa.SignPKCS1v15(rand.Reader, k.rsaPrivate, crypto.SHA256, digest[:])
+	case AlgEdDSA:
+		return ed25519.Sign(k.edPrivate, input), nil
+	}
+
+	return nil, ErrAlgorithm
+}
+
+func (k Key) verify(input, sig []byte) bool {
+	switch k.Alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case AlgRS256:
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(k.rsaPublic, crypto.SHA256, digest[:], sig) == nil
+	case AlgEdDSA:
+		return len(k.edPublic) == ed25519.PublicKeySize && ed25519.Verify(k.edPublic, input, sig)
+	}
+
+	return false
+}
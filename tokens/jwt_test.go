@@ -0,0 +1,152 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestKeySet_Verify(t *testing.T) {
+	now := time.Now()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacKey := func(id, secret string) Key {
+		k, err := NewHMACKey(id, []byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	hsKey := hmacKey("hs-2020-03", "previous-secret-0123456789abcdef")
+	hsRotated := hmacKey("hs-2020-04", "current-secret-0123456789abcdefg")
+	rsKey := NewRSAKey("rs-2020-04", nil, rsaKey)
+	edKey := NewEdDSAKey("ed-2020-04", nil, edPrivate)
+
+	// Verifier only knows the public keys of asymmetric algorithms
+	verifier, err := NewKeySet("", hsKey, hsRotated,
+		NewRSAKey(rsKey.ID, &rsaKey.PublicKey, nil), NewEdDSAKey(edKey.ID, edPublic, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := Claims{Subject: "fac90185-d243-46f5-8797-e57ac9c2c293", ExpiresAt: now.Add(time.Hour).Unix()}
+	expired := Claims{Subject: valid.Subject, ExpiresAt: now.Add(-time.Minute).Unix()}
+	skewed := Claims{Subject: valid.Subject, IssuedAt: now.Add(ClockSkew / 2).Unix(), ExpiresAt: valid.ExpiresAt}
+	issuedLater := Claims{Subject: valid.Subject, IssuedAt: now.Add(time.Hour).Unix(), ExpiresAt: valid.ExpiresAt}
+	notBefore := Claims{Subject: valid.Subject, NotBefore: now.Add(10 * time.Minute).Unix(), ExpiresAt: valid.ExpiresAt}
+
+	sign := func(k Key, c Claims) string {
+		ks, err := NewKeySet(k.ID, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tok, err := ks.Sign(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+
+	tamper := func(tok string) string {
+		parts := strings.Split(tok, ".")
+		parts[1] = b64.EncodeToString([]byte(`{"sub":"someone-else","exp":9999999999}`))
+		return strings.Join(parts, ".")
+	}
+
+	var testCases = []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"HS256 token", sign(hsRotated, valid), nil},
+		{"HS256 token signed with previous key", sign(hsKey, valid), nil},
+		{"RS256 token", sign(rsKey, valid), nil},
+		{"EdDSA token", sign(edKey, valid), nil},
+		{"Expired token", sign(edKey, expired), ErrExpired},
+		{"Issued within the clock skew", sign(edKey, skewed), nil},
+		{"Issued in the future", sign(edKey, issuedLater), ErrNotYet},
+		{"Not valid yet", sign(edKey, notBefore), ErrNotYet},
+		{"Tampered claims", tamper(sign(rsKey, valid)), ErrSignature},
+		{"Unknown key", sign(hmacKey("hs-unknown", "unknown-secret-0123456789abcdefg"), valid), ErrUnknownKey},
+		{"Algorithm mismatch", sign(hmacKey(rsKey.ID, "rsa-kid-secret-0123456789abcdefg"), valid), ErrAlgorithm},
+		{"Forged key secret", sign(hmacKey(hsKey.ID, "guessed-secret-0123456789abcdefg"), valid), ErrSignature},
+		{"Malformed token", "header.claims", ErrMalformed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := verifier.Verify(tc.token, now)
+			if err != tc.wantErr {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+
+			if tc.wantErr == nil && c.Subject != valid.Subject {
+				t.Errorf("got subject %s, want %s", c.Subject, valid.Subject)
+			}
+		})
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+
+	var testCases = []struct {
+		name    string
+		keys    string
+		wantErr string
+	}{
+		{
+			name: "Valid keys",
+			keys: `{"signing_kid":"ed","keys":[{"kid":"hs","alg":"HS256","secret":"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},` +
+				`{"kid":"ed","alg":"EdDSA","private_key":"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}]}`,
+		},
+		{
+			name:    "Short HS256 secret",
+			keys:    `{"keys":[{"kid":"hs","alg":"HS256","secret":"c2VjcmV0"}]}`,
+			wantErr: `key "hs": HS256 secret must be at least 32 bytes`,
+		},
+		{
+			name:    "Missing HS256 secret",
+			keys:    `{"keys":[{"kid":"hs","alg":"HS256"}]}`,
+			wantErr: `key "hs": HS256 secret must be at least 32 bytes`,
+		},
+		{
+			name:    "Unsupported algorithm",
+			keys:    `{"keys":[{"kid":"none","alg":"none"}]}`,
+			wantErr: `key "none": unsupported algorithm "none"`,
+		},
+		{
+			name:    "Signing key without private key",
+			keys:    `{"signing_kid":"ed","keys":[{"kid":"ed","alg":"EdDSA","public_key":"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}]}`,
+			wantErr: `signing key "ed" not found or missing its private key`,
+		},
+		{
+			name:    "Duplicate key id",
+			keys:    `{"keys":[{"kid":"hs","alg":"HS256","secret":"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},{"kid":"hs","alg":"HS256","secret":"MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}]}`,
+			wantErr: `duplicate key id "hs"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseKeySet(strings.NewReader(tc.keys))
+			if err != nil && err.Error() != tc.wantErr || err == nil && tc.wantErr != "" {
+				t.Errorf("got error %v, want %s", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package tokens
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is a key used to sign and verify tokens, identified by its kid.
+// Keys holding only a public key can verify but not sign tokens.
+type Key struct {
+	ID  string
+	Alg string
+
+	secret     []byte
+	rsaPublic  *rsa.PublicKey
+	rsaPrivate *rsa.PrivateKey
+	edPublic   ed25519.PublicKey
+	edPrivate  ed25519.PrivateKey
+}
+
+// MinHMACSecretSize is the minimum size in bytes of HS256 secrets, the size of the SHA-256 output
+const MinHMACSecretSize = 32
+
+// ErrHMACSecretSize is returned when a HS256 secret is too short
+var ErrHMACSecretSize = errors.New("HS256 secret must be at least 32 bytes")
+
+// NewHMACKey returns a HS256 key with the given shared secret
+func NewHMACKey(id string, secret []byte) (Key, error) {
+	if len(secret) < MinHMACSecretSize {
+		return Key{}, ErrHMACSecretSize
+	}
+
+	return Key{ID: id, Alg: AlgHS256, secret: secret}, nil
+}
+
+// NewRSAKey returns a RS256 key. If private is nil the key can only verify tokens.
+func NewRSAKey(id string, public *rsa.PublicKey, private *rsa.PrivateKey) Key {
+	if private != nil {
+		public = &private.PublicKey
+	}
+
+	return Key{ID: id, Alg: AlgRS256, rsaPublic: public, rsaPrivate: private}
+}
+
+// NewEdDSAKey returns an Ed25519 key. If private is nil the key can only verify tokens.
+func NewEdDSAKey(id string, public ed25519.PublicKey, private ed25519.PrivateKey) Key {
+	if private != nil {
+		public = private.Public().(ed25519.PublicKey)
+	}
+
+	return Key{ID: id, Alg: AlgEdDSA, edPublic: public, edPrivate: private}
+}
+
+func (k Key) canSign() bool {
+	return len(k.secret) > 0 || k.rsaPrivate != nil || k.edPrivate != nil
+}
+
+// KeySet holds the keys accepted when verifying tokens and the one used to sign new tokens.
+// Keys are rotated by adding a new signing key while keeping the previous ones for verification
+// until the tokens they signed expire.
+type KeySet struct {
+	keys    map[string]Key
+	signing string
+}
+
+// NewKeySet returns a KeySet with the given keys, signing new tokens with signingKID if not empty
+func NewKeySet(signingKID string, keys ...Key) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]Key, len(keys)), signing: signingKID}
+
+	for _, k := range keys {
+		if _, ok := ks.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", k.ID)
+		}
+		ks.keys[k.ID] = k
+	}
+
+	if signingKID != "" {
+		k, ok := ks.keys[signingKID]
+		if !ok || !k.canSign() {
+			return nil, fmt.Errorf("signing key %q not found or missing its private key", signingKID)
+		}
+	}
+
+	return ks, nil
+}
+
+// CanSign reports whether the set has a key to sign new tokens
+func (ks *KeySet) CanSign() bool {
+	return ks.signing != ""
+}
+
+type keyFile struct {
+	SigningKID string `json:"signing_kid"`
+	Keys       []struct {
+		KID        string `json:"kid"`
+		Alg        string `json:"alg"`
+		Secret     string `json:"secret"`      // HS256, base64
+		PublicKey  string `json:"public_key"`  // RS256 PEM, EdDSA base64
+		PrivateKey string `json:"private_key"` // RS256 PEM, EdDSA base64
+	} `json:"keys"`
+}
+
+// LoadKeySet reads a KeySet from the JSON key file at path
+func LoadKeySet(path string) (*KeySet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseKeySet(f)
+}
+
+// ParseKeySet reads a KeySet in the format
+//
+//	{"signing_kid": "2020-04", "keys": [{"kid": "2020-04", "alg": "HS256", "secret": "<base64>"}]}
+//
+// RS256 keys are PEM encoded, EdDSA keys are base64 encoded.
+func ParseKeySet(r io.Reader) (*KeySet, error) {
+	var kf keyFile
+	if err := json.NewDecoder(r).Decode(&kf); err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(kf.Keys))
+	for _, k := range kf.Keys {
+		var (
+			key Key
+			err error
+		)
+
+		switch k.Alg {
+		case AlgHS256:
+			var secret []byte
+			if secret, err = base64.StdEncoding.DecodeString(k.Secret); err == nil {
+				key, err = NewHMACKey(k.KID, secret)
+			}
+		case AlgRS256:
+			key, err = parseRSAKey(k.KID, k.PublicKey, k.PrivateKey)
+		case AlgEdDSA:
+			key, err = parseEdDSAKey(k.KID, k.PublicKey, k.PrivateKey)
+		default:
+			err = fmt.Errorf("unsupported algorithm %q", k.Alg)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", k.KID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(kf.SigningKID, keys...)
+}
+
+func parseRSAKey(id, public, private string) (Key, error) {
+	if private != "" {
+		block, _ := pem.Decode([]byte(private))
+		if block == nil {
+			return Key{}, errors.New("invalid private key pem")
+		}
+
+		if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			return NewRSAKey(id, nil, k), nil
+		}
+
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, err
+		}
+
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return Key{}, errors.New("private key is not an rsa key")
+		}
+		return NewRSAKey(id, nil, rsaKey), nil
+	}
+
+	block, _ := pem.Decode([]byte(public))
+	if block == nil {
+		return Key{}, errors.New("invalid public key pem")
+	}
+
+	if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return NewRSAKey(id, k, nil), nil
+	}
+
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return Key{}, err
+	}
+
+	rsaKey, ok := k.(*rsa.PublicKey)
+	if !ok {
+		return Key{}, errors.New("public key is not an rsa key")
+	}
+	return NewRSAKey(id, rsaKey, nil), nil
+}
+
+func parseEdDSAKey(id, public, private string) (Key, error) {
+	if private != "" {
+		b, err := base64.StdEncoding.DecodeString(private)
+		if err != nil {
+			return Key{}, err
+		}
+
+		switch len(b) {
+		case ed25519.SeedSize:
+			return NewEdDSAKey(id, nil, ed25519.NewKeyFromSeed(b)), nil
+		case ed25519.PrivateKeySize:
+			return NewEdDSAKey(id, nil, ed25519.PrivateKey(b)), nil
+		}
+		return Key{}, errors.New("invalid ed25519 private key size")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(public)
+	if err != nil {
+		return Key{}, err
+	}
+
+	if len(b) != ed25519.PublicKeySize {
+		return Key{}, errors.New("invalid ed25519 public key size")
+	}
+	return NewEdDSAKey(id, ed25519.PublicKey(b), nil), nil
+}
@@ -0,0 +1,179 @@
+package validator
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/it"
+	ut "github.com/go-playground/universal-translator"
+)
+
+// Translation keys of the validation messages
+const (
+	transRequired        = "required"
+	transRequiredWithout = "required_without"
+	transMaxLength       = "max_length"
+	transMaxSize         = "max_size"
+	transURL             = "url"
+	transURLUserInfo     = "url_userinfo"
+	transURLHost         = "url_host"
+	transURLMalformed    = "url_malformed"
+	transOneOf           = "oneof"
+	transDate            = "date"
+	transCharacters      = "characters"
+	transInvalid         = "invalid"
+)
+
+type catalogue struct {
+	messages   map[string]string
+	characters map[locales.PluralRule]string
+}
+
+// catalogues holds the validation messages for every supported locale.
+// Tags without a specific message are reported as invalid.
+var catalogues = map[string]catalogue{
+	"en": {
+		messages: map[string]string{
+			transRequired:        "{0} " + validationRequiredField,
+			transRequiredWithout: "{0} " + validationRequiredWithout + " {1}",
+			transMaxLength:       "{0} " + validationMaxLength + " {1}",
+			transMaxSize:         "{0} " + validationMaxSize + " {1}",
+			transURL:             "{0} must be a valid url with scheme {1}",
+			transURLUserInfo:     "{0} must not contain credentials",
+			transURLHost:         "{0} has an invalid host",
+			transURLMalformed:    "{0} is malformed",
+			transOneOf:           "{0} must be one of {1}",
+			transDate:            "{0} must be a date in the format {1}",
+			transInvalid:         "{0} " + validationInvalidField,
+		},
+		characters: map[locales.PluralRule]string{
+			locales.PluralRuleOne:   "{0} character",
+			locales.PluralRuleOther: "{0} characters",
+		},
+	},
+	"it": {
+		messages: map[string]string{
+			transRequired:        "{0} è obbligatorio",
+			transRequiredWithout: "{0} è obbligatorio in assenza di {1}",
+			transMaxLength:       "{0} supera {1}",
+			transMaxSize:         "{0} è maggiore di {1}",
+			transURL:             "{0} deve essere un url valido con schema {1}",
+			transURLUserInfo:     "{0} non deve contenere credenziali",
+			transURLHost:         "{0} ha un host non valido",
+			transURLMalformed:    "{0} non è un url ben formato",
+			transOneOf:           "{0} deve essere uno tra {1}",
+			transDate:            "{0} deve essere una data nel formato {1}",
+			transInvalid:         "{0} non è valido",
+		},
+		characters: map[locales.PluralRule]string{
+			locales.PluralRuleOne:   "{0} carattere",
+			locales.PluralRuleOther: "{0} caratteri",
+		},
+	},
+	"es": {
+		messages: map[string]string{
+			transRequired:        "{0} es obligatorio",
+			transRequiredWithout: "{0} es obligatorio en ausencia de {1}",
+			transMaxLength:       "{0} supera {1}",
+			transMaxSize:         "{0} es mayor que {1}",
+			transURL:             "{0} debe ser una url válida con esquema {1}",
+			transURLUserInfo:     "{0} no debe contener credenciales",
+			transURLHost:         "{0} tiene un host no válido",
+			transURLMalformed:    "{0} no es una url bien formada",
+			transOneOf:           "{0} debe ser uno de {1}",
+			transDate:            "{0} debe ser una fecha con el formato {1}",
+			transInvalid:         "{0} no es válido",
+		},
+		characters: map[locales.PluralRule]string{
+			locales.PluralRuleOne:   "{0} carácter",
+			locales.PluralRuleOther: "{0} caracteres",
+		},
+	},
+}
+
+var uni = newUniversalTranslator()
+
+// newUniversalTranslator loads the catalogues of the supported locales, falling back to English
+func newUniversalTranslator() *ut.UniversalTranslator {
+	fallback := en.New()
+	uni := ut.New(fallback, fallback, it.New(), es.New())
+
+	for locale, c := range catalogues {
+		trans, _ := uni.GetTranslator(locale)
+
+		for key, text := range c.messages {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(err)
+			}
+		}
+
+		for rule, text := range c.characters {
+			if err := trans.AddCardinal(transCharacters, text, rule, false); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	if err := uni.VerifyTranslations(); err != nil {
+		panic(err)
+	}
+
+	return uni
+}
+
+// findTranslator returns the translator of the first supported locale
+func findTranslator(locales []string) ut.Translator {
+	trans, _ := uni.FindTranslator(locales...)
+	return trans
+}
+
+// Locales parses an Accept-Language header and returns the requested locales by preference.
+// Regional locales are followed by their base language, e.g. `it-CH` yields `it_ch, it`.
+func Locales(acceptLanguage string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if fields[0] == "" || fields[0] == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > 0 {
+			tags = append(tags, weighted{strings.ToLower(fields[0]), q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	var locales []string
+	for _, t := range tags {
+		locale := strings.Replace(t.locale, "-", "_", -1)
+		locales = append(locales, locale)
+
+		if i := strings.Index(locale, "_"); i > 0 {
+			locales = append(locales, locale[:i])
+		}
+	}
+
+	return locales
+}
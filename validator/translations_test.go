@@ -0,0 +1,103 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLocales(t *testing.T) {
+
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		want           []string
+	}{
+		{
+			name: "Empty header",
+		},
+		{
+			name:           "Single language",
+			acceptLanguage: "it",
+			want:           []string{"it"},
+		},
+		{
+			name:           "Regional language",
+			acceptLanguage: "es-AR",
+			want:           []string{"es_ar", "es"},
+		},
+		{
+			name:           "Weighted languages",
+			acceptLanguage: "en;q=0.5, it-IT, fr;q=0.8, *;q=0.1",
+			want:           []string{"it_it", "it", "fr", "en"},
+		},
+		{
+			name:           "Excluded language",
+			acceptLanguage: "es;q=0, en",
+			want:           []string{"en"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(Locales(tc.acceptLanguage), tc.want); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCustomValidator_ValidateLocales(t *testing.T) {
+
+	payload := struct {
+		ID    string `validate:"required"`
+		Title string `validate:"max=1"`
+		Date  string `validate:"lkDate"`
+		Theme string `validate:"oneof=light dark"`
+	}{
+		Title: "hello world",
+		Date:  "Apr 31 2020",
+		Theme: "blue",
+	}
+
+	testCases := []struct {
+		name            string
+		locales         []string
+		wantTranslation string
+	}{
+		{
+			name: "Default locale",
+			wantTranslation: "validation errors: ID is required, Title is longer than 1 character, " +
+				"Date must be a date in the format Jan 02 2006, Theme must be one of light, dark",
+		},
+		{
+			name:    "Italian locale",
+			locales: []string{"it_it", "it"},
+			wantTranslation: "validation errors: ID è obbligatorio, Title supera 1 carattere, " +
+				"Date deve essere una data nel formato Jan 02 2006, Theme deve essere uno tra light, dark",
+		},
+		{
+			name:    "Spanish locale",
+			locales: []string{"es"},
+			wantTranslation: "validation errors: ID es obligatorio, Title supera 1 carácter, " +
+				"Date debe ser una fecha con el formato Jan 02 2006, Theme debe ser uno de light, dark",
+		},
+		{
+			name:    "Unsupported locale falls back to English",
+			locales: []string{"fr"},
+			wantTranslation: "validation errors: ID is required, Title is longer than 1 character, " +
+				"Date must be a date in the format Jan 02 2006, Theme must be one of light, dark",
+		},
+	}
+
+	cv := New()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cv.Validate(payload, tc.locales...)
+			if err == nil || err.Error() != tc.wantTranslation {
+				t.Errorf("got translation %v, want %v", err, tc.wantTranslation)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// URLSchemes is the allow-list of schemes accepted by the lkURL rule
+var URLSchemes = []string{"http", "https"}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// URL validation errors
+var (
+	ErrURLInvalid   = errors.New("url is invalid")
+	ErrURLScheme    = errors.New("url scheme is not allowed")
+	ErrURLHost      = errors.New("url host is invalid")
+	ErrURLUserInfo  = errors.New("url must not contain credentials")
+	ErrURLMalformed = errors.New("url is malformed")
+	ErrURLTooLong   = errors.New("url is too long")
+)
+
+// NormalizeURL checks that raw is an absolute url with an allowed scheme and returns its
+// canonical form: lowercase scheme and host, IDN hosts converted to punycode and default
+// ports removed.
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", ErrURLMalformed
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if !schemeAllowed(u.Scheme) {
+		return "", ErrURLScheme
+	}
+
+	if u.Opaque != "" || u.Host == "" {
+		return "", ErrURLInvalid
+	}
+
+	if u.User != nil {
+		return "", ErrURLUserInfo
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if net.ParseIP(host) == nil {
+		host, err = idna.Lookup.ToASCII(host)
+		if err != nil || host == "" {
+			return "", ErrURLHost
+		}
+	}
+
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		// IPv6 literal
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	return u.String(), nil
+}
+
+func schemeAllowed(scheme string) bool {
+	for _, s := range URLSchemes {
+		if s == scheme {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkLkURL returns why raw breaks the lkURL rule, nil if it does not. The optional param is
+// the maximum length in characters of the normalized url, as IDN hosts get longer in punycode.
+func checkLkURL(raw, param string) error {
+	n, err := NormalizeURL(raw)
+	if err != nil {
+		return err
+	}
+
+	if param == "" {
+		return nil
+	}
+
+	max, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("invalid lkURL parameter %q", param))
+	}
+	if utf8.RuneCountInString(n) > max {
+		return ErrURLTooLong
+	}
+
+	return nil
+}
+
+func validateLkURL(fl validator.FieldLevel) bool {
+	return checkLkURL(fl.Field().String(), fl.Param()) == nil
+}
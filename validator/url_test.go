@@ -0,0 +1,97 @@
+package validator
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+
+	testCases := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Canonical url",
+			raw:  "https://linktr.ee/alessio?ref=home#top",
+			want: "https://linktr.ee/alessio?ref=home#top",
+		},
+		{
+			name: "Uppercase scheme and host",
+			raw:  "HTTPS://LinkTr.EE/Alessio",
+			want: "https://linktr.ee/Alessio",
+		},
+		{
+			name: "Default port",
+			raw:  "http://linktr.ee:80/alessio",
+			want: "http://linktr.ee/alessio",
+		},
+		{
+			name: "Custom port",
+			raw:  "https://linktr.ee:8443/alessio",
+			want: "https://linktr.ee:8443/alessio",
+		},
+		{
+			name: "IDN host",
+			raw:  "https://Bücher.example/libri",
+			want: "https://xn--bcher-kva.example/libri",
+		},
+		{
+			name: "IPv6 host",
+			raw:  "http://[::1]:80/",
+			want: "http://[::1]/",
+		},
+		{
+			name: "Surrounding spaces",
+			raw:  " https://linktr.ee/ ",
+			want: "https://linktr.ee/",
+		},
+		{
+			name:    "Javascript scheme",
+			raw:     "javascript:alert(1)",
+			wantErr: ErrURLScheme,
+		},
+		{
+			name:    "Data scheme",
+			raw:     "data:text/html,<script>alert(1)</script>",
+			wantErr: ErrURLScheme,
+		},
+		{
+			name:    "Relative url",
+			raw:     "/alessio",
+			wantErr: ErrURLScheme,
+		},
+		{
+			name:    "Missing host",
+			raw:     "https:///alessio",
+			wantErr: ErrURLInvalid,
+		},
+		{
+			name:    "Credentials",
+			raw:     "https://paypal.com@evil.example/login",
+			wantErr: ErrURLUserInfo,
+		},
+		{
+			name:    "Invalid host",
+			raw:     "https://evil_host!.example/",
+			wantErr: ErrURLHost,
+		},
+		{
+			name:    "Malformed url",
+			raw:     "https://linktr.ee/%zz",
+			wantErr: ErrURLMalformed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NormalizeURL(tc.raw)
+			if err != tc.wantErr {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+
+			if got != tc.want {
+				t.Errorf("got url %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package validator
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
+	ut "github.com/go-playground/universal-translator"
 	validator "gopkg.in/go-playground/validator.v9"
 )
 
@@ -19,64 +21,176 @@ const (
 	lkDateFormat = "Jan 02 2006"
 )
 
+// FieldError describes a single validation rule broken by a payload
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. `sublinks[0].url`
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors is returned by Validate with all the failed validations of a payload
+type Errors []FieldError
+
+func (ve Errors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, fe := range ve {
+		msgs[i] = fe.Message
+	}
+
+	return fmt.Sprintf("validation errors: %s", strings.Join(msgs, ", "))
+}
+
+// WithPrefix returns a copy of the errors with their fields nested under the given path,
+// useful when a payload is validated as part of a larger one.
+func (ve Errors) WithPrefix(prefix string) Errors {
+	prefixed := make(Errors, len(ve))
+	for i, fe := range ve {
+		fe.Field = prefix + "." + fe.Field
+		prefixed[i] = fe
+	}
+
+	return prefixed
+}
+
 // CustomValidator is a custom payload validator
 type CustomValidator struct {
 	validator *validator.Validate
 }
 
-// New returns a new instance of CustomValidator
+// New returns a new instance of CustomValidator, safe for concurrent use
 func New() *CustomValidator {
-	return &CustomValidator{validator: validator.New()}
-}
-
-// Validate applies the validation rules specified in the payload `validate tag` and returns an error
-func (cv *CustomValidator) Validate(i interface{}) error {
+	cv := &CustomValidator{validator: validator.New()}
 	cv.registerCustomValidations()
 
+	return cv
+}
+
+// Validate applies the validation rules specified in the payload `validate tag` and returns an error.
+// Messages are translated in the first supported of the given locales, English otherwise.
+func (cv *CustomValidator) Validate(i interface{}, locales ...string) error {
 	err := cv.validator.Struct(i)
 	if err == nil {
 		return nil
 	}
 
-	trans := make([]string, len(err.(validator.ValidationErrors)))
-	for i, vErr := range err.(validator.ValidationErrors) {
-		trans[i] = formatTranslation(vErr)
+	vErrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	structName := reflect.Indirect(reflect.ValueOf(i)).Type().Name()
+	trans := findTranslator(locales)
+
+	errs := make(Errors, len(vErrs))
+	for i, vErr := range vErrs {
+		errs[i] = FieldError{
+			Field:   fieldPath(vErr, structName),
+			Tag:     vErr.Tag(),
+			Param:   vErr.Param(),
+			Message: formatTranslation(vErr, trans),
+		}
 	}
 
-	return fmt.Errorf("validation errors: %s", strings.Join(trans, ", "))
+	return errs
 }
 
+// registerCustomValidations sets up the validator, which must not be changed once in use
 func (cv *CustomValidator) registerCustomValidations() {
+	cv.validator.RegisterTagNameFunc(jsonTagName)
 	cv.validator.RegisterValidation("lkDate", validateLkDate)
+	cv.validator.RegisterValidation("lkURL", validateLkURL)
 }
 
-func formatTranslation(vErr validator.FieldError) string {
+// jsonTagName names fields after their json key so that errors can reference the payload
+func jsonTagName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+
+	return name
+}
+
+// fieldPath returns the namespace of the failed field without the top level struct name
+func fieldPath(vErr validator.FieldError, structName string) string {
+	if structName == "" {
+		return vErr.Namespace()
+	}
+
+	return strings.TrimPrefix(vErr.Namespace(), structName+".")
+}
+
+func formatTranslation(vErr validator.FieldError, trans ut.Translator) string {
 	var field = vErr.StructField()
 	var tag = vErr.Tag()
 
 	switch tag {
 	case "required":
-		return translate(field, validationRequiredField)
+		return translate(trans, transRequired, field)
 	case "required_without":
-		return translate(field, validationRequiredWithout, vErr.Param())
+		return translate(trans, transRequiredWithout, field, vErr.Param())
 	case "max":
 		if vErr.Kind() == reflect.String {
-			return translate(field, validationMaxLength, vErr.Param(), "characters")
+			return translate(trans, transMaxLength, field, characters(trans, vErr.Param()))
 		}
-		return translate(field, validationMaxSize, vErr.Param())
+		return translate(trans, transMaxSize, field, vErr.Param())
+	case "oneof":
+		return translate(trans, transOneOf, field, strings.Join(strings.Fields(vErr.Param()), ", "))
+	case "lkDate":
+		return translate(trans, transDate, field, lkDateFormat)
+	case "lkURL":
+		return urlTranslation(vErr, trans, field)
 	}
 
-	return translate(field, validationInvalidField)
+	return translate(trans, transInvalid, field)
 }
 
-func translate(field string, validationType string, params ...string) string {
+// urlTranslation returns the message of the reason a url breaks the lkURL rule
+func urlTranslation(vErr validator.FieldError, trans ut.Translator, field string) string {
+	raw := reflect.Indirect(reflect.ValueOf(vErr.Value())).String()
+
+	switch checkLkURL(raw, vErr.Param()) {
+	case ErrURLTooLong:
+		return translate(trans, transMaxLength, field, characters(trans, vErr.Param()))
+	case ErrURLUserInfo:
+		return translate(trans, transURLUserInfo, field)
+	case ErrURLHost:
+		return translate(trans, transURLHost, field)
+	case ErrURLMalformed:
+		return translate(trans, transURLMalformed, field)
+	}
+
+	return translate(trans, transURL, field, strings.Join(URLSchemes, ", "))
+}
+
+func translate(trans ut.Translator, key string, params ...string) string {
+	t, err := trans.T(key, params...)
+	if err != nil {
+		return strings.Join(params, " ")
+	}
+
+	return t
+}
+
+// characters pluralises the given number of characters
+func characters(trans ut.Translator, n string) string {
+	num, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return n
+	}
 
-	trans := fmt.Sprintf("%s %s", field, validationType)
-	for _, v := range params {
-		trans += " " + v
+	t, err := trans.C(transCharacters, num, 0, n)
+	if err != nil {
+		return n
 	}
 
-	return trans
+	return t
 }
 
 // TODO this could be a more general date format
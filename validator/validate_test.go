@@ -1,9 +1,8 @@
 package validator
 
 import (
+	"sync"
 	"testing"
-
-	validator "gopkg.in/go-playground/validator.v9"
 )
 
 func TestCustomValidator_Validate(t *testing.T) {
@@ -85,13 +84,40 @@ func TestCustomValidator_Validate(t *testing.T) {
 				Date: "Apr 31 2020",
 			},
 			wantErr:         true,
-			wantTranslation: "validation errors: Date is invalid",
+			wantTranslation: "validation errors: Date must be a date in the format Jan 02 2006",
+		},
+		{
+			name: "Url with invalid host",
+			payload: struct {
+				URL string `validate:"lkURL"`
+			}{
+				URL: "https://evil_host!.example/",
+			},
+			wantErr:         true,
+			wantTranslation: "validation errors: URL has an invalid host",
+		},
+		{
+			name: "Url longer than the max once normalized",
+			payload: struct {
+				URL *string `validate:"omitempty,lkURL=25"`
+			}{
+				URL: stringPtr("https://bücher.example/"),
+			},
+			wantErr:         true,
+			wantTranslation: "validation errors: URL is longer than 25 characters",
+		},
+		{
+			name: "Url within the max once normalized",
+			payload: struct {
+				URL *string `validate:"omitempty,lkURL=30"`
+			}{
+				URL: stringPtr("https://bücher.example/"),
+			},
+			wantErr: false,
 		},
 	}
 
-	cv := &CustomValidator{
-		validator: validator.New(),
-	}
+	cv := New()
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -106,3 +132,87 @@ func TestCustomValidator_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestCustomValidator_ValidateFieldPath(t *testing.T) {
+
+	type sublink struct {
+		URL string `json:"url" validate:"required"`
+	}
+
+	testCases := []struct {
+		name      string
+		payload   interface{}
+		prefix    string
+		wantField string
+	}{
+		{
+			name: "Field named after json key",
+			payload: struct {
+				Title string `json:"title" validate:"required"`
+			}{},
+			wantField: "title",
+		},
+		{
+			name: "Field without json key",
+			payload: struct {
+				Title string `validate:"required"`
+			}{},
+			wantField: "Title",
+		},
+		{
+			name: "Nested field",
+			payload: struct {
+				Sublink sublink `json:"sublink"`
+			}{},
+			wantField: "sublink.url",
+		},
+		{
+			name:      "Prefixed field",
+			payload:   sublink{},
+			prefix:    "sublinks[1]",
+			wantField: "sublinks[1].url",
+		},
+	}
+
+	cv := New()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs, ok := cv.Validate(tc.payload).(Errors)
+			if !ok || len(errs) != 1 {
+				t.Fatalf("got errors %v, want a single field error", errs)
+			}
+
+			if tc.prefix != "" {
+				errs = errs.WithPrefix(tc.prefix)
+			}
+
+			if got := errs[0].Field; got != tc.wantField {
+				t.Errorf("got field %s, want %s", got, tc.wantField)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestCustomValidator_ValidateConcurrently(t *testing.T) {
+	cv := New()
+	payload := struct {
+		URL string `json:"url" validate:"lkURL"`
+	}{URL: "https://linktr.ee"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cv.Validate(payload); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}